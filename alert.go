@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// alertOptions configure where failed runs are reported, so a scheduled run
+// that breaks doesn't go unnoticed until someone looks for the worklog.
+type alertOptions struct {
+	slackWebhook string
+	email        string
+	emailFrom    string
+	smtpServer   string
+	smtpUser     string
+	smtpPassword string
+}
+
+func registerAlertFlags(fs *flag.FlagSet) *alertOptions {
+	opts := &alertOptions{}
+	fs.StringVar(&opts.slackWebhook, "alert-slack-webhook", "", "Slack incoming webhook URL notified when a run fails")
+	fs.StringVar(&opts.email, "alert-email", "", "Comma-separated email addresses notified when a run fails")
+	fs.StringVar(&opts.emailFrom, "alert-email-from", "worklog-gen@localhost", "Sender address of failure emails")
+	fs.StringVar(&opts.smtpServer, "alert-smtp", "localhost:25", "SMTP server (host:port) for failure emails")
+	fs.StringVar(&opts.smtpUser, "alert-smtp-user", "", "SMTP user for failure emails")
+	fs.StringVar(&opts.smtpPassword, "alert-smtp-password", "", "SMTP password for failure emails (can also be set via WORKLOG_SMTP_PASSWORD env var)")
+	return opts
+}
+
+// notify reports a failed run to every configured channel.
+func (o *alertOptions) notify(ctx context.Context, report *runReport, runErr error) error {
+	subject := fmt.Sprintf("worklog-gen failed for %s (%s)", report.Inputs.Board, report.Inputs.Column)
+	host, _ := os.Hostname()
+	detail := fmt.Sprintf("Run started %s on %s failed:\n\n%v", report.StartedAt.Format(time.RFC1123), host, runErr)
+
+	return o.send(ctx, subject, fmt.Sprintf(":rotating_light: *%s*\n```%v```", subject, runErr), detail)
+}
+
+// send posts slackText to Slack and emails body with subject, to the
+// channels that are configured.
+func (o *alertOptions) send(ctx context.Context, subject string, slackText string, body string) error {
+	var errs []error
+	if o.slackWebhook != "" {
+		payload, err := json.Marshal(map[string]string{"text": slackText})
+		if err == nil {
+			err = postJSON(ctx, http.MethodPost, o.slackWebhook, payload, nil)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("slack alert: %w", err))
+		}
+	}
+	if o.email != "" {
+		if err := o.sendEmail(subject, body); err != nil {
+			errs = append(errs, fmt.Errorf("email alert: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o *alertOptions) sendEmail(subject string, body string) error {
+	var recipients []string
+	for _, address := range strings.Split(o.email, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		o.emailFrom, strings.Join(recipients, ", "), subject, time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if o.smtpUser != "" {
+		password := o.smtpPassword
+		if password == "" {
+			password = os.Getenv("WORKLOG_SMTP_PASSWORD")
+		}
+		host, _, err := net.SplitHostPort(o.smtpServer)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP server '%s': %w", o.smtpServer, err)
+		}
+		auth = smtp.PlainAuth("", o.smtpUser, password, host)
+	}
+	return smtp.SendMail(o.smtpServer, auth, o.emailFrom, recipients, []byte(message))
+}
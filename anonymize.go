@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// anonymizer replaces sensitive terms with placeholders. Terms come from the
+// config; @mentions without a configured placeholder are replaced with
+// numbered ones, consistently across the whole report.
+type anonymizer struct {
+	terms    []*regexp.Regexp
+	replace  []string
+	mentions map[string]string
+	// configured are the names of the @mentions among the configured
+	// placeholders, such as teammate for "@teammate", which are kept.
+	configured map[string]bool
+}
+
+func newAnonymizer(placeholders map[string]string) *anonymizer {
+	a := &anonymizer{mentions: make(map[string]string), configured: make(map[string]bool)}
+
+	// Replace longer terms first so "Acme Payments" wins over "Acme".
+	terms := make([]string, 0, len(placeholders))
+	for term := range placeholders {
+		if strings.TrimSpace(term) != "" {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+
+	for _, term := range terms {
+		pattern := regexp.QuoteMeta(term)
+		if isWordByte(term[0]) {
+			pattern = `\b` + pattern
+		}
+		if isWordByte(term[len(term)-1]) {
+			pattern += `\b`
+		}
+		a.terms = append(a.terms, regexp.MustCompile("(?i)"+pattern))
+		a.replace = append(a.replace, placeholders[term])
+		for _, mention := range worklog.MentionPattern.FindAllString(placeholders[term], -1) {
+			a.configured[mentionName(mention)] = true
+		}
+	}
+
+	return a
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// mentionName returns the name of an @mention matched by
+// worklog.MentionPattern, in lower case.
+func mentionName(match string) string {
+	at := strings.Index(match, "@")
+	return strings.ToLower(strings.TrimRight(match[at+1:], ".-_"))
+}
+
+func (a *anonymizer) apply(s string) string {
+	return worklog.MentionPattern.ReplaceAllStringFunc(a.replaceTerms(s), func(match string) string {
+		at := strings.Index(match, "@")
+		name := mentionName(match)
+		if a.configured[name] {
+			// A placeholder of the config, such as "@alice": "@teammate".
+			return match
+		}
+		placeholder, ok := a.mentions[name]
+		if !ok {
+			placeholder = fmt.Sprintf("person%d", len(a.mentions)+1)
+			a.mentions[name] = placeholder
+		}
+		// Punctuation ending a sentence after the mention stays.
+		return match[:at+1] + placeholder + match[len(strings.TrimRight(match, ".-_")):]
+	})
+}
+
+// replaceTerms replaces only the configured terms, leaving @mentions alone.
+func (a *anonymizer) replaceTerms(s string) string {
+	for i, term := range a.terms {
+		s = term.ReplaceAllLiteralString(s, a.replace[i])
+	}
+	return s
+}
+
+func (a *anonymizer) applyAll(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = a.apply(value)
+	}
+	return result
+}
+
+// anonymizeReport returns an anonymized copy of report, leaving the original
+// (and with it the local worklog file) untouched.
+func anonymizeReport(report *Report, placeholders map[string]string) *Report {
+	a := newAnonymizer(placeholders)
+
+	doc := *report.Doc
+	doc.Sections = make([]output.Section, len(report.Doc.Sections))
+	for i, section := range report.Doc.Sections {
+		doc.Sections[i] = output.Section{
+			Category:     section.Category,
+			Column:       section.Column,
+			Summary:      a.apply(section.Summary),
+			KeyPoints:    a.applyAll(section.KeyPoints),
+			Items:        a.applyAll(section.Items),
+			PlainSummary: a.apply(section.PlainSummary),
+			Manual:       a.apply(section.Manual),
+			ItemCount:    section.ItemCount,
+		}
+		if section.Citations != nil {
+			// Citations are keyed by bullet, which changed.
+			doc.Sections[i].Citations = make(map[string][]int, len(section.Citations))
+			for bullet, numbers := range section.Citations {
+				doc.Sections[i].Citations[a.apply(bullet)] = numbers
+			}
+		}
+	}
+	if report.Doc.Citations != nil {
+		// Citations are the titles of the cards, with their real names.
+		doc.Citations = make([]output.Citation, len(report.Doc.Citations))
+		for i, citation := range report.Doc.Citations {
+			doc.Citations[i] = output.Citation{Text: a.apply(citation.Text), Link: a.apply(citation.Link)}
+		}
+	}
+
+	doc.Collaboration = make([]output.Collaborator, len(report.Doc.Collaboration))
+	for i, collaborator := range report.Doc.Collaboration {
+		doc.Collaboration[i] = output.Collaborator{
+			Name:   strings.TrimPrefix(a.apply("@"+collaborator.Name), "@"),
+			Counts: collaborator.Counts,
+			Items:  a.applyAll(collaborator.Items),
+		}
+	}
+	doc.Notes = a.apply(report.Doc.Notes)
+	doc.Digest = a.apply(report.Doc.Digest)
+	doc.Comparison = a.apply(report.Doc.Comparison)
+	doc.SelfReview = a.apply(report.Doc.SelfReview)
+
+	return &Report{
+		Doc:     &doc,
+		Format:  report.Format,
+		Content: a.apply(report.Content),
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// anonymizePlaceholders is the anonymize section of a config file.
+var anonymizePlaceholders = map[string]string{
+	"Acme":           "Customer B",
+	"Acme Payments":  "Customer A",
+	"Project Falcon": "Project X",
+	"@alice":         "@teammate",
+	"C++":            "a language",
+}
+
+func TestAnonymizerTerms(t *testing.T) {
+	a := newAnonymizer(anonymizePlaceholders)
+	for title, want := range map[string]string{
+		// Longer terms win over the terms they contain.
+		"Migrate Acme Payments off Acme": "Migrate Customer A off Customer B",
+		"Demo of project falcon":         "Demo of Project X",
+		// Only whole words are replaced.
+		"Acmes and Acme-wide":  "Acmes and Customer B-wide",
+		"Port the C++ client":  "Port the a language client",
+		"Mail bob@example.com": "Mail bob@example.com",
+	} {
+		if got := a.replaceTerms(title); got != want {
+			t.Errorf("replaceTerms(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+// TestAnonymizeReport checks that a person keeps the same placeholder
+// across the sections and appendices of a report, that configured
+// placeholders aren't numbered, and that the original report is untouched.
+func TestAnonymizeReport(t *testing.T) {
+	report := &Report{
+		Format: "md",
+		Doc: &output.Document{Year: 2026, Week: 42, Sections: []output.Section{
+			{Category: "reviews", Items: []string{"Review with @bob", "Pair with @alice"}},
+			{Category: "meetings", Items: []string{"Sync with @carol and @Bob."}},
+		}, Notes: "Ask @carol about Acme"},
+	}
+
+	anonymized := anonymizeReport(report, anonymizePlaceholders)
+	doc := anonymized.Doc
+	if got, want := doc.Sections[0].Items, []string{"Review with @person1", "Pair with @teammate"}; !slices.Equal(got, want) {
+		t.Errorf("reviews = %q, want %q", got, want)
+	}
+	if got, want := doc.Sections[1].Items, []string{"Sync with @person2 and @person1."}; !slices.Equal(got, want) {
+		t.Errorf("meetings = %q, want %q", got, want)
+	}
+	if want := "Ask @person2 about Customer B"; doc.Notes != want {
+		t.Errorf("notes = %q, want %q", doc.Notes, want)
+	}
+	if report.Doc.Sections[0].Items[0] != "Review with @bob" {
+		t.Errorf("the original report was changed: %q", report.Doc.Sections[0].Items)
+	}
+}
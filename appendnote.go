@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// noteHeadingPattern matches a markdown heading, capturing its hashes and
+// text.
+var noteHeadingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// noteHeading is a heading of a note: the index of its line, its level, and
+// its text.
+type noteHeading struct {
+	line  int
+	level int
+	text  string
+}
+
+// weekTitle is the heading text of the worklog of a week.
+func weekTitle(year int, week int) string {
+	return fmt.Sprintf("Week %d %d", week, year)
+}
+
+// appendToNote writes the markdown worklog of a week into the rolling note at
+// path instead of a file of its own, see insertWeek, creating the note if
+// it doesn't exist yet.
+func appendToNote(path string, heading string, year int, week int, content string) (string, error) {
+	note, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read note: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create the note's folder: %w", err)
+	}
+
+	updated, replaced := insertWeek(string(note), heading, weekTitle(year, week), content)
+	path, err = writeFileSafely(path, []byte(updated))
+	if err != nil {
+		return "", fmt.Errorf("failed to write note: %w", err)
+	}
+	if replaced {
+		log.Printf("INFO: Replaced week %d, %d in %s", week, year, path)
+	} else {
+		log.Printf("INFO: Added week %d, %d to %s", week, year, path)
+	}
+	return path, nil
+}
+
+// insertWeek inserts content, the worklog of the week titled title, into
+// note. A week already in the note is replaced where it is. Otherwise the
+// week goes first below heading, which is added at the end of the note if
+// missing, or without one before the first heading at the week's level, so
+// that the front matter, title, and introduction of the note stay on top.
+// The week's headings are shifted to nest below the heading it is under.
+func insertWeek(note string, heading string, title string, content string) (string, bool) {
+	week := strings.Split(strings.Trim(content, "\n"), "\n")
+	weekLevel := 2
+	if headings := noteHeadings(week); len(headings) > 0 {
+		weekLevel = headings[0].level
+	}
+
+	lines := strings.Split(strings.TrimRight(note, "\n"), "\n")
+	if note == "" {
+		lines = nil
+	}
+	headings := noteHeadings(lines)
+
+	if h, end, ok := weekSection(lines, headings, title); ok {
+		return spliceLines(lines[:h.line], shiftHeadings(week, h.level-weekLevel), lines[end:]), true
+	}
+
+	heading = strings.TrimSpace(strings.TrimLeft(heading, "#"))
+	if heading == "" {
+		at := len(lines)
+		for _, h := range headings {
+			if h.level >= weekLevel {
+				at = h.line
+				break
+			}
+		}
+		return spliceLines(lines[:at], week, lines[at:]), false
+	}
+	for _, h := range headings {
+		if h.text == heading {
+			return spliceLines(lines[:h.line+1], shiftHeadings(week, h.level+1-weekLevel), lines[h.line+1:]), false
+		}
+	}
+	lines = append(trimBlankLines(lines), "", "# "+heading)
+	return spliceLines(lines, shiftHeadings(week, 2-weekLevel), nil), false
+}
+
+// noteWeek returns the worklog of the week titled title in note with its
+// headings at the levels of a worklog file, or an empty string if the note
+// has none, e.g. to keep its manually edited sections.
+func noteWeek(note string, title string) string {
+	lines := strings.Split(note, "\n")
+	h, end, ok := weekSection(lines, noteHeadings(lines), title)
+	if !ok {
+		return ""
+	}
+	return strings.Join(shiftHeadings(lines[h.line:end], 2-h.level), "\n")
+}
+
+// weekSection finds the week titled title among the headings of lines,
+// returning its heading and the index of the line after its last one.
+func weekSection(lines []string, headings []noteHeading, title string) (noteHeading, int, bool) {
+	for i, h := range headings {
+		if h.text != title {
+			continue
+		}
+		for _, next := range headings[i+1:] {
+			if next.level <= h.level {
+				return h, next.line, true
+			}
+		}
+		return h, len(lines), true
+	}
+	return noteHeading{}, 0, false
+}
+
+// noteHeadings returns the headings of lines, skipping front matter and code
+// blocks.
+func noteHeadings(lines []string) []noteHeading {
+	var headings []noteHeading
+	inFence := false
+	for i := frontMatterEnd(lines); i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+		if m := noteHeadingPattern.FindStringSubmatch(lines[i]); m != nil {
+			headings = append(headings, noteHeading{line: i, level: len(m[1]), text: m[2]})
+		}
+	}
+	return headings
+}
+
+// shiftHeadings returns lines with the level of every heading outside code
+// blocks changed by delta, staying within the six levels of markdown.
+func shiftHeadings(lines []string, delta int) []string {
+	shifted := make([]string, len(lines))
+	copy(shifted, lines)
+	if delta == 0 {
+		return shifted
+	}
+	for _, h := range noteHeadings(lines) {
+		level := min(max(h.level+delta, 1), 6)
+		shifted[h.line] = strings.Repeat("#", level) + strings.TrimLeft(lines[h.line], "#")
+	}
+	return shifted
+}
+
+// frontMatterEnd returns the index of the first line after the YAML front
+// matter of a note, or 0 if it has none.
+func frontMatterEnd(lines []string) int {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// spliceLines joins the lines before, the lines of a week, and the lines
+// after into a note, separating them by one blank line.
+func spliceLines(before []string, week []string, after []string) string {
+	var parts []string
+	for _, block := range [][]string{trimBlankLines(before), week, trimBlankLines(after)} {
+		if len(block) > 0 {
+			parts = append(parts, strings.Join(block, "\n"))
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+// trimBlankLines removes the blank lines at the start and end of lines.
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+// TestInsertWeek checks where a week goes in a rolling note: first among
+// the weeks, below the configured heading, or where it already is.
+func TestInsertWeek(t *testing.T) {
+	week := "## Week 42 2026\n\n### Features\n\n- Add login\n"
+	tests := []struct {
+		name         string
+		note         string
+		heading      string
+		want         string
+		wantReplaced bool
+	}{
+		{
+			name: "new note",
+			want: "## Week 42 2026\n\n### Features\n\n- Add login\n",
+		},
+		{
+			name: "before the previous week",
+			note: "---\ntags: [worklog]\n---\n# Worklog\n\nIntro.\n\n## Week 41 2026\n\n- Old\n",
+			want: "---\ntags: [worklog]\n---\n# Worklog\n\nIntro.\n\n## Week 42 2026\n\n### Features\n\n- Add login\n\n## Week 41 2026\n\n- Old\n",
+		},
+		{
+			name:         "replaced",
+			note:         "# Worklog\n\n## Week 42 2026\n\n- Stale\n\n## Week 41 2026\n\n- Old\n",
+			want:         "# Worklog\n\n## Week 42 2026\n\n### Features\n\n- Add login\n\n## Week 41 2026\n\n- Old\n",
+			wantReplaced: true,
+		},
+		{
+			name:         "replaced at its own level",
+			note:         "# Worklog\n\n### Week 42 2026\n\n#### Features\n\n- Stale\n",
+			want:         "# Worklog\n\n### Week 42 2026\n\n#### Features\n\n- Add login\n",
+			wantReplaced: true,
+		},
+		{
+			name:    "below a heading",
+			note:    "# Notes\n\nSomething.\n\n## Weekly\n\n### Week 41 2026\n\n- Old\n",
+			heading: "## Weekly",
+			want:    "# Notes\n\nSomething.\n\n## Weekly\n\n### Week 42 2026\n\n#### Features\n\n- Add login\n\n### Week 41 2026\n\n- Old\n",
+		},
+		{
+			name:    "missing heading",
+			note:    "# Notes\n\nSomething.\n",
+			heading: "Weekly",
+			want:    "# Notes\n\nSomething.\n\n# Weekly\n\n## Week 42 2026\n\n### Features\n\n- Add login\n",
+		},
+		{
+			name: "heading in a code block",
+			note: "# Worklog\n\n```\n## Week 42 2026\n```\n",
+			want: "# Worklog\n\n```\n## Week 42 2026\n```\n\n## Week 42 2026\n\n### Features\n\n- Add login\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, replaced := insertWeek(tt.note, tt.heading, "Week 42 2026", week)
+			if got != tt.want {
+				t.Errorf("insertWeek() = %q, want %q", got, tt.want)
+			}
+			if replaced != tt.wantReplaced {
+				t.Errorf("insertWeek() replaced = %v, want %v", replaced, tt.wantReplaced)
+			}
+		})
+	}
+}
+
+// TestNoteWeek checks that a week read from a note, to keep its manual
+// sections, has the heading levels of a worklog file.
+func TestNoteWeek(t *testing.T) {
+	note := "# Worklog\n\n### Week 42 2026\n\n#### Features\n\n- Add login\n\n### Week 41 2026\n\n- Old"
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Week 42 2026", "## Week 42 2026\n\n### Features\n\n- Add login\n"},
+		{"Week 41 2026", "## Week 41 2026\n\n- Old"},
+		{"Week 40 2026", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if got := noteWeek(note, tt.title); got != tt.want {
+				t.Errorf("noteWeek() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
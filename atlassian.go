@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// atlassianAPI is a client of the REST API of Jira or Confluence, which
+// authenticate the same way: with a user, the token is an API token sent
+// with basic authentication, as the Cloud products expect; without one, it
+// is a personal access token of Server and Data Center.
+type atlassianAPI struct {
+	baseURL string
+	user    string
+	token   string
+}
+
+func newAtlassianAPI(baseURL string, user string, token string) atlassianAPI {
+	return atlassianAPI{baseURL: strings.TrimRight(baseURL, "/"), user: user, token: token}
+}
+
+// do sends a request with body encoded as JSON, if any, to path and decodes
+// the response into out, if any.
+func (a atlassianAPI) do(ctx context.Context, method string, path string, body any, out any) error {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", a.authorization())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// authorization returns the Authorization header of requests.
+func (a atlassianAPI) authorization() string {
+	if a.user != "" {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.user+":"+a.token))
+	}
+	return "Bearer " + a.token
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/categorize"
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// backfillWeek is one week to generate: the cards completed in it.
+type backfillWeek struct {
+	Year  int
+	Week  int
+	Items []worklog.Item
+}
+
+// backfillWeeks groups items by the ISO week of their completion date,
+// oldest first. Items without a completion date can't be placed in a week
+// and are returned separately.
+func backfillWeeks(items []worklog.Item, from time.Time, to time.Time) ([]backfillWeek, []worklog.Item) {
+	byWeek := make(map[[2]int][]worklog.Item)
+	var undated []worklog.Item
+	for _, item := range items {
+		if item.Date.IsZero() {
+			undated = append(undated, item)
+			continue
+		}
+		if (!from.IsZero() && item.Date.Before(from)) || (!to.IsZero() && !item.Date.Before(to.AddDate(0, 0, 1))) {
+			continue
+		}
+		year, week := item.Date.ISOWeek()
+		byWeek[[2]int{year, week}] = append(byWeek[[2]int{year, week}], item)
+	}
+
+	weeks := make([]backfillWeek, 0, len(byWeek))
+	for key, weekItems := range byWeek {
+		weeks = append(weeks, backfillWeek{Year: key[0], Week: key[1], Items: weekItems})
+	}
+	sort.Slice(weeks, func(i, j int) bool {
+		if weeks[i].Year != weeks[j].Year {
+			return weeks[i].Year < weeks[j].Year
+		}
+		return weeks[i].Week < weeks[j].Week
+	})
+	return weeks, undated
+}
+
+// completedInWeek splits items into those completed in an ISO week and the
+// others. Items without a completion date are kept unless skipUndated is set.
+func completedInWeek(items []worklog.Item, year int, week int, skipUndated bool) ([]worklog.Item, []worklog.Item) {
+	var kept, excluded []worklog.Item
+	for _, item := range items {
+		if item.Date.IsZero() {
+			if skipUndated {
+				excluded = append(excluded, item)
+			} else {
+				kept = append(kept, item)
+			}
+			continue
+		}
+		if itemYear, itemWeek := item.Date.ISOWeek(); itemYear != year || itemWeek != week {
+			excluded = append(excluded, item)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept, excluded
+}
+
+// backfillOptions are the settings shared by all weeks of a backfill.
+type backfillOptions struct {
+	outputFolder string
+	renderer     output.Renderer
+	columns      []string
+	summarize    summarize.Options
+	stateDir     string
+	categorizer  *categorize.Categorizer
+	weights      worklog.Weights
+	// translator translates the items of each week to the language of
+	// summarize, if set.
+	translator *summarize.Client
+}
+
+// generateWeek writes the worklog of a single past week and records it in
+// the run history. Sections locked in an existing worklog are kept.
+func generateWeek(week backfillWeek, opts backfillOptions, historyMu *sync.Mutex) (string, error) {
+	categories, _, err := opts.categorizer.Categorize(week.Items)
+	if err != nil {
+		return "", err
+	}
+	if opts.translator != nil {
+		if err := normalizeItemLanguage(opts.translator, opts.summarize.Model, categories, week.Items, opts.summarize.Language, opts.summarize.Raw); err != nil {
+			return "", err
+		}
+	}
+
+	var locked []output.Section
+	if opts.renderer.Format == "md" {
+		existing, err := os.ReadFile(worklogFilename(opts.outputFolder, week.Year, week.Week, opts.renderer.Extension))
+		if err == nil {
+			locked = lockedSections(string(existing))
+		}
+	}
+	sections, err := columnSummaries(categories, opts.columns, locked, opts.summarize, false)
+	if err != nil {
+		return "", err
+	}
+
+	doc := &output.Document{Year: week.Year, Week: week.Week, AIAssisted: opts.summarize.AIAssisted, Sections: sections}
+	applyLockedSections(doc, locked, opts.weights)
+	doc.Collaboration = output.DetectCollaboration(week.Items)
+	doc.Estimates = output.EstimateStats(categories, opts.weights)
+
+	path, err := saveWorklog(opts.outputFolder, week.Year, week.Week, opts.renderer.Extension, opts.renderer.Render(doc))
+	if err != nil {
+		return "", err
+	}
+
+	record := HistoryRecord{Year: week.Year, Week: week.Week, GeneratedAt: time.Now()}
+	for _, category := range worklog.OrderedCategories(categories, nil) {
+		for _, item := range categories[category] {
+			record.Items = append(record.Items, HistoryItem{ID: item.ID, Title: item.Title, Source: item.Source, Category: category})
+		}
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if err := appendHistory(opts.stateDir, record); err != nil {
+		log.Printf("WARNING: Failed to record run history for week %d %d: %v", week.Week, week.Year, err)
+	}
+	return path, nil
+}
+
+// runBackfill implements the backfill subcommand, which generates the
+// worklogs of past weeks from the completion dates of the cards on a board.
+// Weeks are generated concurrently; all LLM calls share one rate limit.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	boardPath := fs.String("board", "", "Path to the Kanban board markdown file")
+	boardGitRef := fs.String("board-git-ref", "", "Read the board as of this git revision of its repository, e.g. HEAD@{1 week ago}")
+	var columns listFlag
+	fs.Var(&columns, "column", "Column holding the completed cards; repeat it or give a comma-separated list for a section per column")
+	outputFolder := fs.String("output-folder", "", "Folder to write the worklogs to")
+	fromDate := fs.String("from", "", "Only include cards completed on or after this date (YYYY-MM-DD)")
+	toDate := fs.String("to", "", "Only include cards completed on or before this date (YYYY-MM-DD)")
+	format := fs.String("format", "md", "Output format: md, rst, adoc, text, or slack")
+	outputTemplate := fs.String("output-template", "", "Path to a text/template file laying out the whole worklog instead of the built-in layout (same fields as --webhook-template)")
+	apiKey := fs.String("api-key", "", "API key of the LLM provider, or a comma-separated list of keys to rotate across (can also be set via OPENAI_API_KEY, ANTHROPIC_API_KEY, or GEMINI_API_KEY env var)")
+	registerProviderFlags(fs)
+	aiAssisted := fs.Bool("ai-assisted", false, "Use AI to generate summaries (requires an API key)")
+	contextPath := fs.String("context", "", "Path to a markdown file injected into every AI prompt")
+	model := fs.String("model", "", "Model used for summaries (default "+summarize.DefaultModel+")")
+	maxTokens := fs.Int("max-tokens", summarize.DefaultMaxTokens, "Maximum length of each category summary in tokens; raise it if busy weeks get cut off")
+	bulletWords := fs.Int("bullet-words", 0, "Maximum number of words per key point; longer summaries are asked for again (default: no limit)")
+	itemsPerBullet := fs.Int("items-per-bullet", 0, "Maximum number of items each key point may cover; more compressed summaries are asked for again (default: no limit)")
+	language := fs.String("language", "", "Language of the worklogs, as a code (en, de) or name; items in other languages are translated to it before summarizing")
+	promptPath := fs.String("prompt", "", "Path to a text/template file replacing the summary prompt (fields: .Category, .Items)")
+	configPath := fs.String("config", "", "Path to a YAML config file (default: worklog.yaml in the vault root, the Obsidian plugin's settings in the vault, or ~/.config/worklog-gen/config.yaml)")
+	vault := fs.String("vault", "", "Obsidian vault whose worklog-gen plugin settings to use (default: the vault containing the board)")
+	concurrency := fs.Int("concurrency", 4, "Number of weeks generated at the same time")
+	rateLimit := fs.Int("rate-limit", 60, "Maximum LLM requests per minute across all weeks (0 for no limit)")
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory for the run history and other state")
+	force := fs.Bool("force", false, "Run even if the state directory is locked by another run, e.g. one that crashed")
+	recordingOpts := registerRecordingFlags(fs)
+	budgetOpts := registerBudgetFlags(fs)
+	enricherOpts := registerEnricherFlags(fs)
+	profileOpts := registerProfileFlags(fs)
+	fs.Parse(args)
+
+	stopProfiling, err := profileOpts.start()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	cfg, err := loadConfig(resolveConfigPath(*configPath, *vault, filepath.Dir(*boardPath)))
+	if err != nil {
+		return err
+	}
+	if err := cfg.applyFlags(fs, false); err != nil {
+		return err
+	}
+	llmAzure.Deployments = cfg.AzureDeployments
+	configSecrets = secretStore{values: cfg.Secrets, stateDir: *stateDir}
+
+	if err := recordingOpts.apply(); err != nil {
+		return err
+	}
+
+	if *boardPath == "" || len(columns) == 0 || *outputFolder == "" {
+		return fmt.Errorf("board, column, and output-folder flags are required")
+	}
+	if *maxTokens <= 0 {
+		return fmt.Errorf("--max-tokens must be a positive number of tokens")
+	}
+	if *bulletWords < 0 || *itemsPerBullet < 0 {
+		return fmt.Errorf("--bullet-words and --items-per-bullet must not be negative")
+	}
+
+	release, err := acquireLock(*stateDir, "backfill", *force)
+	if err != nil {
+		return err
+	}
+	defer release()
+	activeBackup = newRunBackup(*stateDir, "backfill")
+	activeLedger = budgetOpts.ledger(*stateDir, "backfill", cfg.ModelPrices)
+	defer activeLedger.record()
+
+	var from, to time.Time
+	if *fromDate != "" {
+		if from, err = time.ParseInLocation(worklog.DateLayout, *fromDate, time.Local); err != nil {
+			return fmt.Errorf("invalid from date: %w", err)
+		}
+	}
+	if *toDate != "" {
+		if to, err = time.ParseInLocation(worklog.DateLayout, *toDate, time.Local); err != nil {
+			return fmt.Errorf("invalid to date: %w", err)
+		}
+	}
+
+	llmLimiter = summarize.NewRateLimiter(*rateLimit)
+	var categorizeKey string
+	if usesLLM(cfg) {
+		if categorizeKey, err = resolveAPIKey(*apiKey); err != nil {
+			return err
+		}
+	}
+	itemCategorizer, err := newCategorizer(cfg, categorizeKey)
+	if err != nil {
+		return err
+	}
+	outputRenderer, err := output.LookupRenderer(*format)
+	if err != nil {
+		return err
+	}
+	if *outputTemplate != "" {
+		if outputRenderer, err = templatedRenderer(*outputTemplate, outputRenderer); err != nil {
+			return err
+		}
+	}
+
+	content, err := readBoard(*boardPath, *boardGitRef)
+	if err != nil {
+		return fmt.Errorf("failed to read board file: %w", err)
+	}
+	items, err := extractColumns(content, columns, nil)
+	if err != nil {
+		return err
+	}
+	enrichers, err := enricherOpts.build(cfg)
+	if err != nil {
+		return err
+	}
+	if items, err = enrichItems(context.Background(), enrichers, items); err != nil {
+		return fmt.Errorf("failed to enrich items: %w", err)
+	}
+	items = dedupItems(items)
+	weeks, undated := backfillWeeks(items, from, to)
+	if len(undated) > 0 {
+		log.Printf("WARNING: Skipping %d %s without a completion date", len(undated), worklog.Pluralize(len(undated), "card", "cards"))
+	}
+	if len(weeks) == 0 {
+		return fmt.Errorf("no cards with a completion date found in %s '%s'", worklog.Pluralize(len(columns), "column", "columns"), strings.Join(columns, "', '"))
+	}
+
+	opts := backfillOptions{
+		outputFolder: *outputFolder,
+		renderer:     outputRenderer,
+		columns:      columns,
+		stateDir:     *stateDir,
+		categorizer:  itemCategorizer,
+		weights:      cfg.CategoryWeights,
+		summarize: summarize.Options{
+			AIAssisted:     *aiAssisted,
+			CategoryModels: cfg.CategoryModels,
+			RawCategories:  cfg.RawCategories,
+			Model:          *model,
+			MaxTokens:      *maxTokens,
+			Budget:         summarize.Budget{Words: *bulletWords, ItemsPerBullet: *itemsPerBullet},
+			Language:       *language,
+		},
+	}
+	if *language != "" {
+		key, err := resolveAPIKey(*apiKey)
+		if err != nil {
+			return err
+		}
+		opts.translator = newLLMClient(key)
+	}
+	if *aiAssisted {
+		key, err := resolveAPIKey(*apiKey)
+		if err != nil {
+			return err
+		}
+		opts.summarize.Client = newLLMClient(key)
+		if opts.summarize.Context, err = loadContextFile(*contextPath); err != nil {
+			return err
+		}
+		if *promptPath != "" {
+			if opts.summarize.Prompt, err = summarize.LoadPromptTemplate(*promptPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("INFO: Backfilling %d weeks with up to %d at a time", len(weeks), max(*concurrency, 1))
+
+	errs := make([]error, len(weeks))
+	jobs := make(chan int)
+	var historyMu sync.Mutex
+	var wg sync.WaitGroup
+	for range max(*concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if _, err := generateWeek(weeks[i], opts, &historyMu); err != nil {
+					errs[i] = err
+					log.Printf("ERROR: Week %d %d failed: %v", weeks[i].Week, weeks[i].Year, err)
+				}
+			}
+		}()
+	}
+	for i := range weeks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for i, week := range weeks {
+		if errs[i] != nil {
+			failed++
+			fmt.Printf("Week %d %d: failed: %v\n", week.Week, week.Year, errs[i])
+		} else {
+			fmt.Printf("Week %d %d: %d items\n", week.Week, week.Year, len(week.Items))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d weeks failed", failed, len(weeks))
+	}
+	log.Printf("SUCCESS: Backfilled %d weeks to %s", len(weeks), *outputFolder)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ben/obsidian-worklog-gen/board"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// readBoard returns the content of the board file, or with a git ref, the
+// content it had at that point in the history of the git repository holding
+// it, e.g. HEAD@{1 week ago}. Boards with a byte order mark or in another
+// encoding than UTF-8 are transcoded, so their headings match the columns.
+func readBoard(path string, gitRef string) (string, error) {
+	var data []byte
+	if gitRef == "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return "", err
+		}
+	} else {
+		content, err := runGit(filepath.Dir(path), "show", gitRef+":./"+filepath.Base(path))
+		if err != nil {
+			return "", err
+		}
+		data = []byte(content)
+	}
+
+	content, encoding := board.Decode(data)
+	if encoding != board.EncodingUTF8 {
+		log.Printf("INFO: Read the board as %s", encoding)
+	}
+	return content, nil
+}
+
+// addBlockIDs adds block IDs to the cards of columns that have none and
+// writes the board back if any were added, returning its new content. Only
+// the IDs are added to the file, which keeps its encoding and the
+// normalization of its text.
+func addBlockIDs(path string, content string, columns []string) (string, error) {
+	suffixes, err := board.BlockIDSuffixes(content, columns)
+	if err != nil {
+		return content, err
+	}
+	if len(suffixes) == 0 {
+		return content, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return content, fmt.Errorf("failed to read board file: %w", err)
+	}
+	if current, _ := board.Decode(data); current != content {
+		return content, fmt.Errorf("the board file changed while it was read; try again")
+	}
+	data, err = board.AppendToLines(data, suffixes)
+	if err != nil {
+		return content, err
+	}
+	written, err := writeFileSafely(path, data)
+	if err != nil {
+		return content, fmt.Errorf("failed to write board file: %w", err)
+	}
+	if written != path {
+		log.Printf("WARNING: The block IDs went to %s; merge them into the board by hand", written)
+		return content, nil
+	}
+	log.Printf("INFO: Added block IDs to %d %s", len(suffixes), worklog.Pluralize(len(suffixes), "card", "cards"))
+	updated, _ := board.Decode(data)
+	return updated, nil
+}
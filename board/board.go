@@ -0,0 +1,495 @@
+// Package board extracts the cards of a column from an Obsidian Kanban board.
+package board
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	// checkboxPattern matches the task marker a card starts with. Obsidian
+	// themes use other characters than x for custom statuses, e.g. [/] or [-].
+	checkboxPattern = regexp.MustCompile(`^\[[^\]]\]`)
+	// footnoteReferencePattern matches footnote references such as [^1].
+	footnoteReferencePattern = regexp.MustCompile(`\[\^[^\]\s]+\]`)
+	// footnoteDefinitionPattern matches the first line of a footnote
+	// definition such as "[^1]: Some note".
+	footnoteDefinitionPattern = regexp.MustCompile(`^ {0,3}\[\^[^\]\s]+\]:`)
+	fencePattern              = regexp.MustCompile("^[ \t]*(`{3,}|~{3,})")
+)
+
+// Options configure the extraction of columns.
+type Options struct {
+	// Excluded, if set, is called with every list item of the extracted
+	// columns that is skipped because it isn't a card, and why.
+	Excluded func(text string, reason string)
+}
+
+// structuredBoardPattern matches the HTML comment in which newer Kanban
+// plugin versions embed the board as JSON.
+var structuredBoardPattern = regexp.MustCompile(`(?s)<!--\s*kanban:data\s*(.*?)-->`)
+
+// StructuredBoard is the JSON embedded in a kanban:data comment.
+type StructuredBoard struct {
+	Lanes []struct {
+		Title string `json:"title"`
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	} `json:"lanes"`
+}
+
+// ExtractColumnItems returns the cards in columnName. The structured board
+// data is preferred when the board has it, since it does not depend on how
+// the markdown is formatted; otherwise the cards listed under the level-2
+// heading columnName are used.
+func ExtractColumnItems(content string, columnName string) ([]worklog.Item, error) {
+	columns, err := ExtractColumns(content, Options{}, columnName)
+	if err != nil {
+		return nil, err
+	}
+	return columns[columnName], nil
+}
+
+// ExtractColumns returns the cards of the given columns, or of every column
+// of the board when none are given, by column name. The board is parsed only
+// once, however many columns are extracted. As with ExtractColumnItems, a
+// lane of the structured board data is preferred over a heading of the same
+// name. Names are compared in Unicode NFC, so the way an accent was typed
+// doesn't matter. A requested column the board lacks is an error.
+func ExtractColumns(content string, opts Options, columnNames ...string) (map[string][]worklog.Item, error) {
+	titles := structuredColumns(content)
+
+	var missing []string
+	for _, column := range columnNames {
+		if _, ok := titles[columnKey(column)]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(columnNames) == 0 || len(missing) > 0 {
+		headings, err := headingColumns(content, missing, opts)
+		if err != nil {
+			return nil, err
+		}
+		for column, columnTitles := range headings {
+			if _, ok := titles[column]; !ok {
+				titles[column] = columnTitles
+			}
+		}
+	}
+
+	columns := make(map[string][]worklog.Item, len(titles))
+	if len(columnNames) == 0 {
+		for column, columnTitles := range titles {
+			columns[column] = worklog.NewItems(worklog.SourceBoard, columnTitles)
+		}
+		return columns, nil
+	}
+	for _, column := range columnNames {
+		columns[column] = worklog.NewItems(worklog.SourceBoard, titles[columnKey(column)])
+	}
+	return columns, nil
+}
+
+// structuredColumns reads the cards of every lane from the board's
+// kanban:data comment, by the columnKey of the lane. It is empty when there
+// is no usable data.
+func structuredColumns(content string) map[string][]string {
+	columns := make(map[string][]string)
+	match := structuredBoardPattern.FindStringSubmatch(content)
+	if match == nil {
+		return columns
+	}
+
+	var board StructuredBoard
+	if err := json.Unmarshal([]byte(match[1]), &board); err != nil {
+		log.Printf("WARNING: Ignoring invalid kanban:data comment, falling back to headings: %v", err)
+		return columns
+	}
+	for _, lane := range board.Lanes {
+		column := columnKey(lane.Title)
+		if _, ok := columns[column]; !ok {
+			columns[column], _ = board.ColumnTitles(column)
+		}
+	}
+	return columns
+}
+
+// ColumnTitles returns the normalized titles of the cards in the lane
+// columnName, and whether the board has such a lane.
+func (board StructuredBoard) ColumnTitles(columnName string) ([]string, bool) {
+	for _, lane := range board.Lanes {
+		if columnKey(lane.Title) != columnKey(columnName) {
+			continue
+		}
+		items := []string{}
+		for _, item := range lane.Items {
+			title := strings.Join(strings.Fields(footnoteReferencePattern.ReplaceAllString(item.Title, "")), " ")
+			if title != "" {
+				items = append(items, title)
+			}
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// headingColumns returns the titles of the cards listed under the level-2
+// headings columnNames, or under every level-2 heading when none are given,
+// by the columnKey of the heading. List items that aren't cards are reported
+// to opts.Excluded.
+func headingColumns(content string, columnNames []string, opts Options) (map[string][]string, error) {
+	source := []byte(sanitizeBoard(content))
+
+	columns := make(map[string][]string)
+	found, err := walkColumns(source, columnNames, func(column string, node *ast.ListItem) {
+		if itemText, ok := cardTitle(node, source); ok {
+			columns[column] = append(columns[column], itemText)
+		} else if first := node.FirstChild(); first != nil && opts.Excluded != nil {
+			if text := strings.TrimSpace(string(first.Text(source))); text != "" {
+				opts.Excluded(text, fmt.Sprintf("list item in column '%s' without a checkbox", column))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range found {
+		if _, ok := columns[column]; !ok {
+			columns[column] = nil
+		}
+	}
+	return columns, nil
+}
+
+// walkColumns calls visit with every list item under the level-2 headings
+// columnNames of a sanitized board, or under every level-2 heading when none
+// are given, in a single pass. A column ends at the next heading of level 1
+// or 2; only the first heading of a name counts. Only blocks are walked:
+// headings and list items never occur within the inline content of a
+// paragraph or heading. Columns are passed to visit and returned by their
+// columnKey, in board order.
+func walkColumns(source []byte, columnNames []string, visit func(column string, item *ast.ListItem)) ([]string, error) {
+	doc := goldmark.DefaultParser().Parse(text.NewReader(source))
+
+	keys := make([]string, len(columnNames))
+	for i, column := range columnNames {
+		keys[i] = columnKey(column)
+	}
+
+	var order []string
+	found := make(map[string]bool)
+	current := ""
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			if node.Level > 2 {
+				return ast.WalkSkipChildren, nil
+			}
+			current = ""
+			if len(keys) > 0 && len(found) == len(keys) {
+				return ast.WalkStop, nil
+			}
+			if node.Level == 2 {
+				name := columnKey(headingText(node, source))
+				if !found[name] && (len(keys) == 0 || slices.Contains(keys, name)) {
+					found[name] = true
+					order = append(order, name)
+					current = name
+				}
+			}
+			return ast.WalkSkipChildren, nil
+
+		case *ast.ListItem:
+			if current != "" {
+				visit(current, node)
+			}
+
+		case *ast.Paragraph, *ast.TextBlock, *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, column := range columnNames {
+		if !found[keys[i]] {
+			return nil, fmt.Errorf("column '%s' not found", column)
+		}
+	}
+	return order, nil
+}
+
+// headingText returns the text of heading. The raw line is used when it has
+// no inline markup, so most headings aren't rendered to text.
+func headingText(heading *ast.Heading, source []byte) string {
+	if lines := heading.Lines(); lines.Len() == 1 {
+		line := lines.At(0)
+		raw := bytes.TrimSpace(line.Value(source))
+		if !bytes.ContainsAny(raw, "*_`[]<>\\&!~=") {
+			return string(raw)
+		}
+	}
+	return strings.TrimSpace(string(heading.Text(source)))
+}
+
+// AddBlockIDs appends an Obsidian block ID, such as ^3f9a1c, to every card in
+// columns that has none, so the card can be linked as [[Board#^3f9a1c]] for
+// good, however its text changes later. It returns the new content and the
+// number of IDs added. Boards with a kanban:data comment are not supported,
+// since their cards are read from the JSON rather than the list.
+func AddBlockIDs(content string, columns []string) (string, int, error) {
+	suffixes, err := BlockIDSuffixes(content, columns)
+	if err != nil || len(suffixes) == 0 {
+		return content, 0, err
+	}
+	return appendToLines(content, suffixes), len(suffixes), nil
+}
+
+// BlockIDSuffixes returns the block IDs AddBlockIDs would add, as the text to
+// append to a line by the index of the line, e.g. to add them to the board
+// file with AppendToLines.
+func BlockIDSuffixes(content string, columns []string) (map[int]string, error) {
+	if structuredBoardPattern.MatchString(content) {
+		return nil, fmt.Errorf("block IDs can't be added to boards with kanban:data")
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	// sanitizeBoard keeps the lines of the board where they are, so the
+	// lines of the parsed cards are those of the original content.
+	source := []byte(sanitizeBoard(content))
+	taken := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		if id, ok := worklog.BlockID(strings.TrimRight(line, " \t\r")); ok {
+			taken[id] = true
+		}
+	}
+
+	suffixes := make(map[int]string)
+	_, err := walkColumns(source, columns, func(_ string, node *ast.ListItem) {
+		title, ok := cardTitle(node, source)
+		if !ok {
+			return
+		}
+		if _, ok := worklog.BlockID(title); ok {
+			return
+		}
+		segments := node.FirstChild().Lines()
+		if segments.Len() == 0 {
+			return
+		}
+		last := bytes.Count(source[:segments.At(segments.Len()-1).Start], []byte("\n"))
+
+		id := worklog.NewBlockID(title)
+		for n := 2; taken[id]; n++ {
+			id = fmt.Sprintf("%s-%d", worklog.NewBlockID(title), n)
+		}
+		taken[id] = true
+		suffixes[last] = " ^" + id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suffixes, nil
+}
+
+// appendToLines appends suffixes to the lines of content by their index,
+// before any trailing whitespace.
+func appendToLines(content string, suffixes map[int]string) string {
+	lines := strings.Split(content, "\n")
+	for i, suffix := range suffixes {
+		if i < 0 || i >= len(lines) {
+			continue
+		}
+		end := len(strings.TrimRight(lines[i], " \t\r"))
+		lines[i] = lines[i][:end] + suffix + lines[i][end:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cardTitle returns the title of a task list item. Only the item's own text
+// counts: nested lists are cards of their own and inline HTML such as
+// comments is dropped.
+func cardTitle(item *ast.ListItem, source []byte) (string, bool) {
+	first := item.FirstChild()
+	if first == nil {
+		return "", false
+	}
+	if _, ok := first.(*ast.List); ok {
+		return "", false
+	}
+
+	var sb strings.Builder
+	_ = ast.Walk(first, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			sb.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(node.Value)
+		case *ast.AutoLink:
+			sb.Write(node.URL(source))
+		}
+		return ast.WalkContinue, nil
+	})
+
+	title := strings.TrimSpace(sb.String())
+	marker := checkboxPattern.FindString(title)
+	if marker == "" {
+		return "", false
+	}
+	title = footnoteReferencePattern.ReplaceAllString(title[len(marker):], "")
+	title = strings.Join(strings.Fields(title), " ")
+	return title, title != ""
+}
+
+// sanitizeBoard removes the parts of a board that are not meant to be read as
+// cards before it is parsed: HTML and Obsidian (%% ... %%) comments and
+// footnote definitions, which would otherwise be folded into the preceding
+// card as lazy continuation lines. Fenced code blocks are left untouched,
+// except that an unclosed fence is turned into plain text so it does not
+// swallow the rest of the board.
+func sanitizeBoard(content string) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var out []string
+	var prose []string
+	flushProse := func() {
+		if prose != nil {
+			out = append(out, strings.Split(stripComments(strings.Join(prose, "\n")), "\n")...)
+			prose = nil
+		}
+	}
+
+	fence := ""
+	fenceStart := 0
+	inFootnote := false
+	for _, line := range lines {
+		if fence != "" {
+			if marker := fencePattern.FindStringSubmatch(line); marker != nil &&
+				marker[1][0] == fence[0] && len(marker[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(marker[0]):]) == "" {
+				fence = ""
+			}
+			out = append(out, line)
+			continue
+		}
+
+		if marker := fencePattern.FindStringSubmatch(line); marker != nil &&
+			!(marker[1][0] == '`' && strings.Contains(line[len(marker[0]):], "`")) {
+			flushProse()
+			fence = marker[1]
+			fenceStart = len(out)
+			out = append(out, line)
+			continue
+		}
+
+		if footnoteDefinitionPattern.MatchString(line) {
+			inFootnote = true
+			prose = append(prose, "")
+			continue
+		}
+		if inFootnote {
+			if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+				prose = append(prose, "")
+				continue
+			}
+			inFootnote = false
+		}
+		prose = append(prose, line)
+	}
+	flushProse()
+
+	if fence != "" {
+		line := out[fenceStart]
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		out[fenceStart] = line[:indent] + `\` + line[indent:]
+		rest := strings.Join(out[fenceStart+1:], "\n")
+		out = append(out[:fenceStart+1], strings.Split(stripComments(rest), "\n")...)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// stripComments removes HTML and Obsidian comments, keeping their line breaks
+// so the surrounding structure is unchanged. An unclosed comment only hides
+// the rest of its line.
+func stripComments(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	// The next opening of either kind is only searched for again once it is
+	// passed, so a board with thousands of HTML comments isn't rescanned for
+	// an Obsidian comment after each of them.
+	nextHTML, nextObsidian := indexFrom(s, "<!--", 0), indexFrom(s, "%%", 0)
+	pos := 0
+	for {
+		if nextHTML >= 0 && nextHTML < pos {
+			nextHTML = indexFrom(s, "<!--", pos)
+		}
+		if nextObsidian >= 0 && nextObsidian < pos {
+			nextObsidian = indexFrom(s, "%%", pos)
+		}
+		start, open, closing := -1, "", ""
+		if nextHTML >= 0 {
+			start, open, closing = nextHTML, "<!--", "-->"
+		}
+		if nextObsidian >= 0 && (start < 0 || nextObsidian < start) {
+			start, open, closing = nextObsidian, "%%", "%%"
+		}
+		if start < 0 {
+			sb.WriteString(s[pos:])
+			return sb.String()
+		}
+
+		sb.WriteString(s[pos:start])
+		rest := start + len(open)
+		end := indexFrom(s, closing, rest)
+		if end < 0 {
+			// Unclosed: hide the rest of the line only.
+			if newline := indexFrom(s, "\n", rest); newline >= 0 {
+				pos = newline
+				continue
+			}
+			return sb.String()
+		}
+		sb.WriteString(strings.Repeat("\n", strings.Count(s[rest:end], "\n")))
+		pos = end + len(closing)
+	}
+}
+
+// indexFrom returns the index of the first substr in s at or after from, or
+// -1 if there is none.
+func indexFrom(s string, substr string, from int) int {
+	i := strings.Index(s[from:], substr)
+	if i < 0 {
+		return -1
+	}
+	return from + i
+}
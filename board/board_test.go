@@ -0,0 +1,89 @@
+package board
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// FuzzExtractColumnItems checks that arbitrary boards never crash the parser
+// and always yield clean, single-line card titles. The boards in
+// testdata/boards seed the corpus.
+func FuzzExtractColumnItems(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "boards", "*.md"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(content), "Done")
+	}
+
+	f.Fuzz(func(t *testing.T, content string, column string) {
+		items, err := ExtractColumnItems(content, column)
+		if err != nil {
+			return
+		}
+		for _, item := range items {
+			if item.Title == "" || item.Title != strings.Join(strings.Fields(item.Title), " ") {
+				t.Errorf("card title %q is not normalized", item.Title)
+			}
+			if item.ID != worklog.NewItem(worklog.SourceBoard, item.Title).ID {
+				t.Errorf("card %q has an unstable ID", item.Title)
+			}
+		}
+	})
+}
+
+// giantBoard builds a board like those kept for years: a few active columns
+// and an archive of cards cards, with dates, tags, links, and block IDs.
+func giantBoard(cards int) string {
+	var sb strings.Builder
+	sb.WriteString("---\n\nkanban-plugin: basic\n\n---\n\n## Todo\n\n")
+	for i := range 50 {
+		fmt.Fprintf(&sb, "- [ ] Plan task %d #plan\n", i)
+	}
+	sb.WriteString("\n## Done\n\n")
+	for i := range 200 {
+		fmt.Fprintf(&sb, "- [x] Implement **feature** %d for [[Project %d]] #feat @alice ✅ 2024-05-%02d ^card%d\n", i, i%7, i%28+1, i)
+	}
+	sb.WriteString("\n***\n\n## Archive\n\n")
+	for i := range cards {
+		fmt.Fprintf(&sb, "- [x] Fix bug %d in https://example.com/issues/%d #bug <!-- note --> @{2023-%02d-%02d}\n", i, i, i%12+1, i%28+1)
+	}
+	sb.WriteString("\n%% kanban:settings\n```\n{\"kanban-plugin\":\"basic\"}\n```\n%%\n")
+	return sb.String()
+}
+
+func BenchmarkExtractColumnItems(b *testing.B) {
+	content := giantBoard(10000)
+	for _, column := range []string{"Done", "Archive"} {
+		b.Run(column, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for range b.N {
+				if _, err := ExtractColumnItems(content, column); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExtractColumns(b *testing.B) {
+	content := giantBoard(10000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+	for range b.N {
+		if _, err := ExtractColumns(content, Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
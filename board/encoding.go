@@ -0,0 +1,95 @@
+package board
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Encodings that Decode reports.
+const (
+	EncodingUTF8        = "UTF-8"
+	EncodingUTF8BOM     = "UTF-8 with BOM"
+	EncodingUTF16LE     = "UTF-16LE"
+	EncodingUTF16BE     = "UTF-16BE"
+	EncodingWindows1252 = "Windows-1252"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// Decode returns the content of a board file as NFC-normalized UTF-8, and
+// the encoding it was read from. Boards exported from other tools may start
+// with a byte order mark, which would hide the first heading, or be in
+// UTF-16 or Windows-1252. Content that is neither marked nor valid UTF-8 is
+// taken as Windows-1252, the most common legacy encoding of such exports.
+func Decode(data []byte) (string, string) {
+	content, encoding := decode(data)
+	return norm.NFC.String(content), encoding
+}
+
+// decode returns the content of a board file as UTF-8 without normalizing
+// it, and the encoding it was read from.
+func decode(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return string(data[len(utf8BOM):]), EncodingUTF8BOM
+	case bytes.HasPrefix(data, utf16LEBOM):
+		if decoded, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(data); err == nil {
+			return string(decoded), EncodingUTF16LE
+		}
+	case bytes.HasPrefix(data, utf16BEBOM):
+		if decoded, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data); err == nil {
+			return string(decoded), EncodingUTF16BE
+		}
+	case !utf8.Valid(data):
+		if decoded, err := charmap.Windows1252.NewDecoder().Bytes(data); err == nil {
+			return string(decoded), EncodingWindows1252
+		}
+	}
+	return string(data), EncodingUTF8
+}
+
+// encode returns content in encoding, undoing decode.
+func encode(content string, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingUTF8BOM:
+		return append(slices.Clone(utf8BOM), content...), nil
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(content))
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(content))
+	case EncodingWindows1252:
+		return charmap.Windows1252.NewEncoder().Bytes([]byte(content))
+	}
+	return []byte(content), nil
+}
+
+// AppendToLines appends suffixes, such as those of BlockIDSuffixes, to the
+// lines of a board file by their index. The rest of the file stays as it
+// is: its encoding, byte order mark, and the normalization of its text,
+// which Decode changes, so links and diffs of synced vaults don't break.
+func AppendToLines(data []byte, suffixes map[int]string) ([]byte, error) {
+	content, encoding := decode(data)
+	updated, err := encode(appendToLines(content, suffixes), encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the board as %s: %w", encoding, err)
+	}
+	return updated, nil
+}
+
+// columnKey is the form in which column names are compared, so a heading
+// typed with combining accents matches a column name typed with precomposed
+// ones, as in "Terminé".
+func columnKey(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
@@ -0,0 +1,91 @@
+package board
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func utf16(t *testing.T, order unicode.Endianness, content string) []byte {
+	t.Helper()
+	data, err := unicode.UTF16(order, unicode.UseBOM).NewEncoder().Bytes([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func windows1252(t *testing.T, content string) []byte {
+	t.Helper()
+	data, err := charmap.Windows1252.NewEncoder().Bytes([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestDecode checks that boards exported by other tools read as the same
+// text as a plain UTF-8 board.
+func TestDecode(t *testing.T) {
+	const board = "## Terminé\n\n- [x] Café\n"
+	tests := []struct {
+		name         string
+		data         []byte
+		wantEncoding string
+	}{
+		{"utf-8", []byte(board), EncodingUTF8},
+		{"byte order mark", append([]byte{0xEF, 0xBB, 0xBF}, board...), EncodingUTF8BOM},
+		{"utf-16le", utf16(t, unicode.LittleEndian, board), EncodingUTF16LE},
+		{"utf-16be", utf16(t, unicode.BigEndian, board), EncodingUTF16BE},
+		{"windows-1252", windows1252(t, board), EncodingWindows1252},
+		// Combining accents, as macOS writes file names and some editors
+		// text, are composed.
+		{"decomposed", []byte("## Terminé\n\n- [x] Café\n"), EncodingUTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, encoding := Decode(tt.data)
+			if content != board {
+				t.Errorf("Decode() content = %q, want %q", content, board)
+			}
+			if encoding != tt.wantEncoding {
+				t.Errorf("Decode() encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+		})
+	}
+}
+
+// TestAppendToLines checks that adding block IDs to a board keeps its
+// encoding, byte order mark, and normalization, so synced vaults see no
+// change besides the IDs.
+func TestAppendToLines(t *testing.T) {
+	const board = "## Terminé\n\n- [x] Café\n- [x] Thé\n"
+	const want = "## Terminé\n\n- [x] Café ^a1\n- [x] Thé\n"
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"utf-8", []byte(board), []byte(want)},
+		{"byte order mark", append([]byte{0xEF, 0xBB, 0xBF}, board...), append([]byte{0xEF, 0xBB, 0xBF}, want...)},
+		{"utf-16le", utf16(t, unicode.LittleEndian, board), utf16(t, unicode.LittleEndian, want)},
+		{"utf-16be", utf16(t, unicode.BigEndian, board), utf16(t, unicode.BigEndian, want)},
+		{"windows-1252", windows1252(t, board), windows1252(t, want)},
+		{"decomposed", []byte("## Terminé\n\n- [x] Café\n- [x] Thé\n"), []byte("## Terminé\n\n- [x] Café ^a1\n- [x] Thé\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendToLines(tt.data, map[int]string{2: " ^a1"})
+			if err != nil {
+				t.Fatalf("AppendToLines: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("AppendToLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
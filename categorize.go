@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/categorize"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+)
+
+// categorizeConfig returns the categorization settings of the config.
+func (c *Config) categorizeConfig() categorize.Config {
+	return categorize.Config{
+		Tags:       c.Tags,
+		Keywords:   c.CategoryKeywords,
+		Strategies: c.Categorization.Strategies,
+		Override:   c.Categorization.Override,
+	}
+}
+
+// usesLLM reports whether categorizing calls the LLM API.
+func usesLLM(cfg *Config) bool {
+	return categorize.UsesLLM(cfg.categorizeConfig())
+}
+
+// dropLLMStrategy removes the llm strategy from the categorization, for runs
+// that must not call the LLM. Without any other strategy, the default ones
+// are used.
+func (c *Config) dropLLMStrategy() {
+	c.Categorization.Strategies = slices.DeleteFunc(slices.Clone(c.Categorization.Strategies), func(strategy string) bool {
+		return strings.EqualFold(strings.TrimSpace(strategy), categorize.StrategyLLM)
+	})
+}
+
+// newCategorizer builds the categorizer configured in cfg. apiKey is only
+// needed for the llm strategy.
+func newCategorizer(cfg *Config, apiKey string) (*categorize.Categorizer, error) {
+	var client *summarize.Client
+	if apiKey != "" {
+		client = newLLMClient(apiKey)
+	}
+	return categorize.New(cfg.categorizeConfig(), client)
+}
+
+// logDecisions explains the category of every item, for
+// --explain-categorization.
+func logDecisions(decisions []categorize.Decision) {
+	for _, decision := range decisions {
+		log.Printf("EXPLAIN: %q -> %s (%s: %s)", decision.Item.Title, decision.Category, decision.Strategy, decision.Rule)
+	}
+}
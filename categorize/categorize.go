@@ -0,0 +1,346 @@
+// Package categorize assigns the items of a worklog to categories, such as
+// features or bugs, by their tags, keywords, or with a language model.
+package categorize
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// TagCategories maps the hashtags that categorize a card to their category.
+var TagCategories = map[string]string{
+	"build":   "features",
+	"feat":    "features",
+	"feature": "features",
+	"bug":     "bugs",
+	"plan":    "planning/design",
+	"design":  "planning/design",
+	"doc":     "documentation",
+	"docs":    "documentation",
+	"review":  "reviews",
+	"meet":    "meetings",
+	"meeting": "meetings",
+	"learn":   "learning",
+}
+
+// Names of the categorization strategies, as used in the config.
+const (
+	StrategyTag     = "tag"
+	StrategyKeyword = "keyword"
+	StrategyLLM     = "llm"
+)
+
+// DefaultStrategies are used when no strategies are configured.
+var DefaultStrategies = []string{StrategyTag, StrategyKeyword}
+
+// Config configures the categorization.
+type Config struct {
+	// Tags maps additional hashtags to their category, on top of
+	// TagCategories.
+	Tags map[string]string
+	// Keywords categorizes items by words in their titles, e.g.
+	// bugs: [fix, crash, regression].
+	Keywords map[string][]string
+	// Strategies orders the strategies (tag, keyword, and llm), by default
+	// DefaultStrategies.
+	Strategies []string
+	// Override lets later strategies replace the category an earlier one
+	// assigned, instead of only categorizing what is left.
+	Override bool
+}
+
+// Strategy assigns categories to items. Items it has no category for are
+// left to the next strategy.
+type Strategy interface {
+	Name() string
+	// Categorize returns a decision for each item; decisions without a
+	// category leave the item undecided.
+	Categorize(items []worklog.Item) ([]Decision, error)
+}
+
+// Decision records which category an item got and why.
+type Decision struct {
+	Item     worklog.Item
+	Category string
+	Strategy string
+	// Rule describes what matched, e.g. the tag or keyword.
+	Rule string
+}
+
+// Categorizer runs the configured strategies in order. Normally the first
+// strategy that categorizes an item decides; with override, later strategies
+// that categorize it replace the earlier decision.
+type Categorizer struct {
+	strategies []Strategy
+	override   bool
+}
+
+// New builds the configured strategies, by default tags followed by
+// keywords. client is only needed for the llm strategy.
+func New(cfg Config, client *summarize.Client) (*Categorizer, error) {
+	names := cfg.Strategies
+	if len(names) == 0 {
+		names = DefaultStrategies
+	}
+
+	c := &Categorizer{override: cfg.Override}
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case StrategyTag:
+			c.strategies = append(c.strategies, tagStrategy{tags: normalizeTags(cfg.Tags)})
+		case StrategyKeyword:
+			c.strategies = append(c.strategies, keywordStrategy{rules: newKeywordRules(cfg.Keywords)})
+		case StrategyLLM:
+			if client == nil {
+				return nil, fmt.Errorf("the llm categorization strategy requires an API key")
+			}
+			c.strategies = append(c.strategies, llmStrategy{client: client, categories: KnownCategories(cfg)})
+		default:
+			return nil, fmt.Errorf("unknown categorization strategy '%s' (expected tag, keyword, or llm)", name)
+		}
+	}
+	return c, nil
+}
+
+// UsesLLM reports whether categorizing calls the LLM API.
+func UsesLLM(cfg Config) bool {
+	return slices.ContainsFunc(cfg.Strategies, func(name string) bool {
+		return strings.ToLower(strings.TrimSpace(name)) == StrategyLLM
+	})
+}
+
+// KnownCategories returns the built-in categories and those of the keyword
+// rules and configured tags, in the canonical order.
+func KnownCategories(cfg Config) []string {
+	categories := make(map[string]bool)
+	for _, category := range worklog.CategoryOrder {
+		categories[category] = true
+	}
+	for category := range cfg.Keywords {
+		categories[strings.ToLower(category)] = true
+	}
+	for _, category := range cfg.Tags {
+		categories[strings.ToLower(category)] = true
+	}
+	return worklog.OrderedCategories(categories, nil)
+}
+
+// normalizeTags normalizes the configured tags like worklog.ExtractTags
+// does, so "#OnCall" in the config matches #oncall on a card.
+func normalizeTags(tags map[string]string) map[string]string {
+	normalized := make(map[string]string, len(tags))
+	for tag, category := range tags {
+		normalized[strings.ToLower(strings.TrimPrefix(tag, "#"))] = strings.ToLower(category)
+	}
+	return normalized
+}
+
+// Categorize groups items by category and returns the decision behind each
+// item's category, in the order of the items. Items no strategy categorizes
+// end up in "other".
+func (c *Categorizer) Categorize(items []worklog.Item) (map[string][]worklog.Item, []Decision, error) {
+	decisions := make([]Decision, len(items))
+	for i, item := range items {
+		decisions[i] = Decision{Item: item}
+	}
+
+	for _, strategy := range c.strategies {
+		// Without override, only undecided items are left to categorize.
+		var pending []int
+		for i, decision := range decisions {
+			if c.override || decision.Category == "" {
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		pendingItems := make([]worklog.Item, len(pending))
+		for j, i := range pending {
+			pendingItems[j] = items[i]
+		}
+		results, err := strategy.Categorize(pendingItems)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s categorization: %w", strategy.Name(), err)
+		}
+		for j, i := range pending {
+			if results[j].Category != "" {
+				decisions[i] = results[j]
+			}
+		}
+	}
+
+	categories := make(map[string][]worklog.Item, len(worklog.CategoryOrder))
+	for _, category := range worklog.CategoryOrder {
+		categories[category] = []worklog.Item{}
+	}
+	for i := range decisions {
+		if decisions[i].Category == "" {
+			decisions[i].Category = "other"
+			decisions[i].Strategy = "default"
+			decisions[i].Rule = "no strategy matched"
+		}
+		categories[decisions[i].Category] = append(categories[decisions[i].Category], items[i])
+	}
+	return categories, decisions, nil
+}
+
+// tagStrategy categorizes items by their first hashtag with a category.
+type tagStrategy struct {
+	// tags are the configured tags, checked before the built-in ones.
+	tags map[string]string
+}
+
+func (tagStrategy) Name() string {
+	return StrategyTag
+}
+
+func (s tagStrategy) Categorize(items []worklog.Item) ([]Decision, error) {
+	decisions := make([]Decision, len(items))
+	for i, item := range items {
+		decisions[i] = Decision{Item: item}
+		for _, tag := range worklog.ExtractTags(item.Title) {
+			category, ok := s.tags[tag]
+			if !ok {
+				category, ok = TagCategories[tag]
+			}
+			if ok {
+				decisions[i] = Decision{Item: item, Category: category, Strategy: StrategyTag, Rule: "#" + tag}
+				break
+			}
+		}
+	}
+	return decisions, nil
+}
+
+// keywordRule assigns a category to titles containing one of its keywords.
+type keywordRule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// newKeywordRules compiles the keywords of the config. Keywords
+// match whole words or phrases, ignoring case. Rules are tried in the
+// canonical category order, so a title matching keywords of several
+// categories gets the first of them.
+func newKeywordRules(keywords map[string][]string) []keywordRule {
+	var rules []keywordRule
+	for _, category := range worklog.OrderedCategories(keywords, nil) {
+		var alternatives []string
+		for _, keyword := range keywords[category] {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				alternatives = append(alternatives, regexp.QuoteMeta(keyword))
+			}
+		}
+		if len(alternatives) == 0 {
+			continue
+		}
+		// Longer keywords first, so phrases win over the words they contain.
+		sort.Slice(alternatives, func(i, j int) bool { return len(alternatives[i]) > len(alternatives[j]) })
+		rules = append(rules, keywordRule{
+			category: strings.ToLower(category),
+			pattern:  regexp.MustCompile(`(?i)(?:^|\W)(` + strings.Join(alternatives, "|") + `)(?:\W|$)`),
+		})
+	}
+	return rules
+}
+
+// keywordStrategy categorizes items by the first keyword rule matching their
+// title. Hashtags and mentions don't count as keywords.
+type keywordStrategy struct {
+	rules []keywordRule
+}
+
+func (keywordStrategy) Name() string {
+	return StrategyKeyword
+}
+
+func (s keywordStrategy) Categorize(items []worklog.Item) ([]Decision, error) {
+	decisions := make([]Decision, len(items))
+	for i, item := range items {
+		decisions[i] = Decision{Item: item}
+
+		var words []string
+		for _, field := range strings.Fields(item.Title) {
+			if !strings.HasPrefix(field, "#") && !strings.HasPrefix(field, "@") {
+				words = append(words, field)
+			}
+		}
+		text := strings.Join(words, " ")
+		for _, rule := range s.rules {
+			if match := rule.pattern.FindStringSubmatch(text); match != nil {
+				decisions[i] = Decision{Item: item, Category: rule.category, Strategy: StrategyKeyword, Rule: strconv.Quote(match[1])}
+				break
+			}
+		}
+	}
+	return decisions, nil
+}
+
+// llmStrategy has the model pick a category for each item from the known
+// categories, for cards that neither tags nor keywords explain.
+type llmStrategy struct {
+	client     *summarize.Client
+	categories []string
+}
+
+func (llmStrategy) Name() string {
+	return StrategyLLM
+}
+
+// categorizePrompt asks the model to pick a category for numbered card
+// titles.
+const categorizePrompt = `Assign each of the following completed work items of a software engineer to exactly one of these categories: %s.
+Use "other" only if no other category fits.
+
+Items:
+%s
+Respond with one line per item in the form "<number>: <category>", and nothing else.`
+
+var categoryAnswerPattern = regexp.MustCompile(`^\s*(\d+)\s*[:.)-]\s*(.+?)\s*$`)
+
+func (s llmStrategy) Categorize(items []worklog.Item) ([]Decision, error) {
+	decisions := make([]Decision, len(items))
+	for i, item := range items {
+		decisions[i] = Decision{Item: item}
+	}
+	if len(items) == 0 {
+		return decisions, nil
+	}
+
+	var list strings.Builder
+	for i, item := range items {
+		list.WriteString(fmt.Sprintf("%d: %s\n", i+1, item.Title))
+	}
+	prompt := fmt.Sprintf(categorizePrompt, strings.Join(s.categories, ", "), list.String())
+	response, err := s.client.Complete(context.Background(), summarize.DefaultModel, prompt, 20*len(items)+50)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(response, "\n") {
+		match := categoryAnswerPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > len(items) {
+			continue
+		}
+		category := strings.ToLower(strings.Trim(match[2], "`*\"' "))
+		if !slices.Contains(s.categories, category) {
+			continue
+		}
+		decisions[n-1] = Decision{Item: items[n-1], Category: category, Strategy: StrategyLLM, Rule: "chosen by " + summarize.DefaultModel}
+	}
+	return decisions, nil
+}
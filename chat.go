@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/yuin/goldmark"
+)
+
+// Chat sinks always post the markdown version of the report, regardless of
+// the file format, since both Matrix and Mattermost display markdown natively.
+// Slack gets it converted to its own mrkdwn.
+
+type matrixSink struct {
+	homeserver string
+	roomID     string
+	token      string
+}
+
+func newMatrixSink(homeserver string, roomID string, token string) (*matrixSink, error) {
+	if roomID == "" || token == "" {
+		return nil, fmt.Errorf("matrix delivery requires a room ID and an access token")
+	}
+	return &matrixSink{
+		homeserver: strings.TrimRight(homeserver, "/"),
+		roomID:     roomID,
+		token:      token,
+	}, nil
+}
+
+func (s *matrixSink) Name() string {
+	return "matrix"
+}
+
+func (s *matrixSink) Send(ctx context.Context, report *Report) error {
+	markdown := report.Markdown()
+
+	var html bytes.Buffer
+	if err := goldmark.Convert([]byte(markdown), &html); err != nil {
+		return fmt.Errorf("failed to convert worklog to HTML: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           markdown,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": html.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	txnID := fmt.Sprintf("worklog-%d-%d-%d", report.Doc.Year, report.Doc.Week, time.Now().UnixNano())
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		s.homeserver, url.PathEscape(s.roomID), url.PathEscape(txnID))
+
+	return postJSON(ctx, http.MethodPut, endpoint, body, map[string]string{
+		"Authorization": "Bearer " + s.token,
+	})
+}
+
+type mattermostSink struct {
+	webhookURL string
+	channel    string
+}
+
+func (s *mattermostSink) Name() string {
+	return "mattermost"
+}
+
+func (s *mattermostSink) Send(ctx context.Context, report *Report) error {
+	payload := map[string]string{
+		"text":     report.Markdown(),
+		"username": "worklog-gen",
+	}
+	if s.channel != "" {
+		payload["channel"] = s.channel
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return postJSON(ctx, http.MethodPost, s.webhookURL, body, nil)
+}
+
+// Slack limits the text of a section block and the blocks of a message.
+const (
+	slackSectionLimit = 3000
+	slackBlockLimit   = 50
+)
+
+type slackSink struct {
+	webhookURL string
+}
+
+func (s *slackSink) Name() string {
+	return "slack"
+}
+
+// Send posts the worklog with a section block per category, so long
+// worklogs stay readable and within Slack's limits. A worklog needing more
+// blocks than a message allows is posted as text only.
+func (s *slackSink) Send(ctx context.Context, report *Report) error {
+	sections := output.SlackSections(report.Markdown())
+	var blocks []map[string]any
+	for _, section := range sections {
+		for _, chunk := range splitText(section, slackSectionLimit) {
+			blocks = append(blocks, map[string]any{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": chunk},
+			})
+		}
+	}
+
+	payload := map[string]any{"text": strings.Join(sections, "\n\n")}
+	if len(blocks) <= slackBlockLimit {
+		payload["blocks"] = blocks
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	return postJSON(ctx, http.MethodPost, s.webhookURL, body, nil)
+}
+
+// splitText splits text at line breaks into chunks of at most limit bytes;
+// longer lines are cut.
+func splitText(text string, limit int) []string {
+	var chunks []string
+	for len(text) > limit {
+		cut := strings.LastIndex(text[:limit], "\n")
+		if cut <= 0 {
+			cut = limit
+			for !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	return append(chunks, text)
+}
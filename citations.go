@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// citeItems adds footnotes to the bullets of doc citing the cards they were
+// written from. Raw items cite the card they contain, summarized key points
+// the cards they share most words with. Each card gets one footnote, however
+// many bullets cite it. With a vault, board cards link to the board file, and
+// to the card itself if it has a block ID.
+func citeItems(doc *output.Document, categories map[string][]worklog.Item, boardPath string, vault string) {
+	boardLink := ""
+	if vault != "" {
+		vaultDir, vaultErr := filepath.Abs(vault)
+		boardFile, boardErr := filepath.Abs(boardPath)
+		if vaultErr == nil && boardErr == nil {
+			if rel, err := filepath.Rel(vaultDir, boardFile); err == nil && !strings.HasPrefix(rel, "..") {
+				boardLink = strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+			}
+		}
+	}
+
+	numbers := make(map[string]int)
+	cite := func(item worklog.Item) int {
+		if n, ok := numbers[item.ID]; ok {
+			return n
+		}
+		citation := output.Citation{Text: item.Title}
+		if boardLink != "" && item.Source == worklog.SourceBoard {
+			citation.Link = "[[" + boardLink + "]]"
+			if item.BlockID != "" {
+				citation.Link = "[[" + boardLink + "#^" + item.BlockID + "]]"
+			}
+		}
+		doc.Citations = append(doc.Citations, citation)
+		numbers[item.ID] = len(doc.Citations)
+		return len(doc.Citations)
+	}
+
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		if section.Manual != "" {
+			continue
+		}
+
+		var candidates []worklog.Item
+		for _, item := range categories[section.Category] {
+			if item.Column == section.Column {
+				candidates = append(candidates, item)
+			}
+		}
+
+		section.Citations = make(map[string][]int)
+		for _, bullet := range append(append([]string(nil), section.Items...), section.KeyPoints...) {
+			for _, item := range citedItems(bullet, candidates) {
+				section.Citations[bullet] = append(section.Citations[bullet], cite(item))
+			}
+		}
+	}
+}
+
+// citedItems returns the items a bullet was written from: the items whose
+// title appears in the bullet, or else those matching its words.
+func citedItems(bullet string, items []worklog.Item) []worklog.Item {
+	var cited []worklog.Item
+	for _, item := range items {
+		if strings.Contains(bullet, item.Title) {
+			cited = append(cited, item)
+		}
+	}
+	if len(cited) > 0 {
+		return cited
+	}
+	return matchItems(bullet, items)
+}
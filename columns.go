@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/board"
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// listFlag is a flag that can be repeated or given a comma-separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// extractColumns takes the cards of every column from the board, parsing it
+// once. With several columns, each item records its column, so the worklog
+// gets a section per column. List items that aren't cards are reported to
+// excluded, if set.
+func extractColumns(content string, columns []string, excluded func(text string, reason string)) ([]worklog.Item, error) {
+	byColumn, err := board.ExtractColumns(content, board.Options{Excluded: excluded}, columns...)
+	if err != nil {
+		return nil, err
+	}
+	var items []worklog.Item
+	for _, column := range columns {
+		columnItems := byColumn[column]
+		if len(columns) > 1 {
+			for i := range columnItems {
+				columnItems[i].Column = column
+			}
+		}
+		items = append(items, columnItems...)
+	}
+	return items, nil
+}
+
+// assignColumn puts items without a column, such as those from other
+// sources, into the first column of a worklog covering several columns.
+func assignColumn(items []worklog.Item, columns []string) {
+	if len(columns) < 2 {
+		return
+	}
+	for i := range items {
+		if items[i].Column == "" {
+			items[i].Column = columns[0]
+		}
+	}
+}
+
+// columnCategories splits categorized items by column, in the order of
+// columns. Without several columns there is a single group with an empty
+// column.
+func columnCategories(categories map[string][]worklog.Item, columns []string) ([]string, map[string]map[string][]worklog.Item) {
+	if len(columns) < 2 {
+		return []string{""}, map[string]map[string][]worklog.Item{"": categories}
+	}
+	byColumn := make(map[string]map[string][]worklog.Item, len(columns))
+	for _, column := range columns {
+		byColumn[column] = make(map[string][]worklog.Item)
+	}
+	for category, items := range categories {
+		for _, item := range items {
+			byColumn[item.Column][category] = append(byColumn[item.Column][category], item)
+		}
+	}
+	return columns, byColumn
+}
+
+// columnSummaries summarizes the categories of every column and builds the
+// sections of the document, skipping the sections locked by the user.
+// Plain-language summaries are added when plain is set. A category whose
+// summary failed lists its raw items with a warning; only when every
+// category failed is it an error.
+func columnSummaries(categories map[string][]worklog.Item, columns []string, locked []output.Section, opts summarize.Options, plain bool) ([]output.Section, error) {
+	order, byColumn := columnCategories(categories, columns)
+
+	var sections []output.Section
+	for _, column := range order {
+		pending := make(map[string][]worklog.Item, len(byColumn[column]))
+		for category, items := range byColumn[column] {
+			pending[category] = items
+		}
+		for _, section := range locked {
+			if section.Column == column {
+				delete(pending, section.Category)
+			}
+		}
+
+		summaries, err := summarize.ByCategory(pending, opts)
+		var failed summarize.CategoryErrors
+		if errors.As(err, &failed) && len(failed) < summarizedCategories(pending, opts) {
+			for _, category := range slices.Sorted(maps.Keys(failed)) {
+				log.Printf("WARNING: Listing the raw items of the category instead of a summary: %v", columnError(column, failed[category]))
+			}
+		} else if err != nil {
+			return nil, columnError(column, err)
+		}
+		var plainSummaries map[string]string
+		if plain {
+			if plainSummaries, err = summarize.PlainLanguage(pending, opts); err != nil {
+				return nil, columnError(column, fmt.Errorf("plain-language summaries: %w", err))
+			}
+		}
+
+		part := output.BuildDocument(summaries, 0, 0, opts.AIAssisted)
+		for _, section := range part.Sections {
+			if opts.Raw(section.Category) || failed[section.Category] != nil {
+				section.Summary, section.KeyPoints = "", nil
+				section.Items = summaries[section.Category]
+			}
+			section.Column = column
+			section.PlainSummary = plainSummaries[section.Category]
+			section.ItemCount = len(pending[section.Category])
+			sections = append(sections, section)
+		}
+	}
+	return sections, nil
+}
+
+// summarizedCategories counts the categories ByCategory asks the model to
+// summarize.
+func summarizedCategories(categories map[string][]worklog.Item, opts summarize.Options) int {
+	count := 0
+	for category, items := range categories {
+		if len(items) > 0 && !opts.Raw(category) {
+			count++
+		}
+	}
+	return count
+}
+
+func columnError(column string, err error) error {
+	if column == "" {
+		return err
+	}
+	return fmt.Errorf("column '%s': %w", column, err)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// previousRecord returns the latest history record of a week before the
+// given one.
+func previousRecord(records []HistoryRecord, year int, week int) (HistoryRecord, bool) {
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Year < year || record.Year == year && record.Week < week {
+			return record, true
+		}
+	}
+	return HistoryRecord{}, false
+}
+
+// weekStats describes the items of a week per category for the comparison
+// prompt, with counts first so the model can quote them.
+func weekStats(categories map[string][]string) string {
+	var sb strings.Builder
+	total := 0
+	for _, titles := range categories {
+		total += len(titles)
+	}
+	sb.WriteString(fmt.Sprintf("%d items in total\n", total))
+	for _, category := range worklog.OrderedCategories(categories, nil) {
+		titles := categories[category]
+		if len(titles) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s (%d):\n", output.Section{Category: category}.Title(), len(titles)))
+		for _, title := range titles {
+			sb.WriteString("- " + title + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// weekComparison has the model compare this week's items to those of a
+// previous week from the run history and returns one paragraph of
+// commentary on the trends.
+func weekComparison(current map[string][]worklog.Item, previous HistoryRecord, opts summarize.Options) (string, error) {
+	if opts.Client == nil {
+		return "", fmt.Errorf("an API key is required for the comparison")
+	}
+
+	currentTitles := make(map[string][]string, len(current))
+	for category, items := range current {
+		if !opts.Raw(category) {
+			currentTitles[category] = worklog.Titles(items)
+		}
+	}
+	previousTitles := make(map[string][]string)
+	for _, item := range previous.Items {
+		if opts.Raw(item.Category) {
+			continue
+		}
+		previousTitles[item.Category] = append(previousTitles[item.Category], item.Title)
+	}
+
+	prompt := fmt.Sprintf(comparisonPrompt, previous.Week, previous.Year, weekStats(previousTitles), weekStats(currentTitles))
+	responseText, err := opts.Client.Complete(context.Background(), opts.ModelFor(""), summarize.WithContext(opts.Context, summarize.InLanguage(opts.Language, prompt)), 300)
+	if err != nil {
+		return "", fmt.Errorf("error calling LLM API: %w", err)
+	}
+	return strings.Join(strings.Fields(responseText), " "), nil
+}
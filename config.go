@@ -0,0 +1,286 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings read from the YAML file passed via --config.
+type Config struct {
+	// Version is the version of the config file format; files without one
+	// are version 1 and migrated to configVersion when read.
+	Version int `yaml:"version"`
+	// Tags maps additional hashtags to the category they assign, e.g.
+	// oncall: bugs, on top of the built-in tags.
+	Tags map[string]string `yaml:"tags"`
+	// Anonymize maps names, customer identifiers, and project codenames to
+	// the placeholders used in published output when --anonymize is set.
+	Anonymize map[string]string `yaml:"anonymize"`
+	// CategoryModels selects the model used to summarize a category, e.g. a
+	// stronger model for features and a cheaper one for "other".
+	CategoryModels map[string]string `yaml:"category_models"`
+	// CategoryWeights orders the sections of the worklog, heavier
+	// categories first, e.g. features: 10 and learning: -10; categories
+	// without a weight weigh 0 and keep the built-in order.
+	CategoryWeights map[string]int `yaml:"category_weights"`
+	// AzureDeployments maps models to the Azure OpenAI deployments serving
+	// them with --provider azure, e.g. gpt-4o: prod-gpt4o.
+	AzureDeployments map[string]string `yaml:"azure_deployments"`
+	// ModelPrices sets the price of models in US dollars per million tokens
+	// for the cost ledger, e.g. gpt-4o: {prompt: 2.5, completion: 10}, for
+	// models without a built-in price or with negotiated rates.
+	ModelPrices map[string]modelPrice `yaml:"model_prices"`
+	// CategoryKeywords categorizes cards without a category hashtag by words
+	// in their titles, e.g. bugs: [fix, crash, regression].
+	CategoryKeywords map[string][]string `yaml:"category_keywords"`
+	// Categorization configures how items are categorized.
+	Categorization CategorizationConfig `yaml:"categorization"`
+	// Overload sets the thresholds of --overload-warnings.
+	Overload OverloadConfig `yaml:"overload"`
+	// HistoryDomains are the domains whose pages in the browser history
+	// count as research, used when --history-domains is not set.
+	HistoryDomains []string `yaml:"history_domains"`
+	// RawCategories are listed as raw items even in AI-assisted worklogs and
+	// never sent to the LLM, e.g. reviews whose cards need no paraphrasing.
+	RawCategories []string `yaml:"raw_categories"`
+	// Redact maps terms that must not leave the machine, such as customer
+	// names, to their replacements in item titles.
+	Redact map[string]string `yaml:"redact"`
+	// Copies are further folders receiving a copy of every worklog, each
+	// with its own file name, on top of those of --copy-to.
+	Copies []CopyConfig `yaml:"copies"`
+	// Secrets holds API keys and tokens by name, e.g. openai or jira,
+	// encrypted by config set-secret.
+	Secrets map[string]string `yaml:"secrets"`
+	// Flags holds every other top-level key. Each one sets the command-line
+	// flag of the same name, with underscores for dashes, unless the flag is
+	// given on the command line, e.g. output_folder: Worklogs.
+	Flags map[string]any `yaml:",inline"`
+
+	// lines holds the line of every key in the config file by its path,
+	// e.g. overload.items_ratio, for errors pointing at the setting.
+	lines map[string]int
+	// migration is set when the file was of an older version.
+	migration *configMigrationResult
+}
+
+// CategorizationConfig orders the categorization strategies (tag, keyword,
+// and llm). Override lets later strategies replace the category an earlier
+// one assigned, instead of only categorizing what is left.
+type CategorizationConfig struct {
+	Strategies []string `yaml:"strategies"`
+	Override   bool     `yaml:"override"`
+}
+
+// pluginDataPath is where the companion Obsidian plugin keeps its settings,
+// relative to the vault root. It holds the same settings as the config file,
+// as JSON.
+var pluginDataPath = filepath.Join(".obsidian", "plugins", "worklog-gen", "data.json")
+
+// findVault returns the Obsidian vault containing path, i.e. the closest
+// directory at or above it with a .obsidian folder.
+func findVault(path string) (string, bool) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".obsidian")); err == nil && info.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// vaultConfigName is the config file looked up in the vault root.
+const vaultConfigName = "worklog.yaml"
+
+// userConfigPath returns the config file in the user's config directory,
+// e.g. ~/.config/worklog-gen/config.yaml.
+func userConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "worklog-gen", "config.yaml")
+}
+
+// resolveConfigPath returns the config file to read: configPath if given,
+// otherwise the first that exists of worklog.yaml in the vault root, the
+// companion plugin's settings in the vault, and the config file in the
+// user's config directory. Without --vault, the vault is the one containing
+// near, e.g. the board, or else the working directory.
+func resolveConfigPath(configPath string, vault string, near string) string {
+	if configPath != "" {
+		return configPath
+	}
+	if vault == "" {
+		if near == "" {
+			near = "."
+		}
+		vault, _ = findVault(near)
+	}
+
+	var candidates []string
+	if vault != "" {
+		candidates = append(candidates, filepath.Join(vault, vaultConfigName), filepath.Join(vault, pluginDataPath))
+	}
+	if path := userConfigPath(); path != "" {
+		candidates = append(candidates, path)
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			log.Printf("INFO: Using settings from %s", path)
+			return path
+		}
+	}
+	return ""
+}
+
+// applyFlags sets the flags of fs named by the config's other keys, except
+// those given on the command line, which take precedence. Lists become
+// comma-separated values. Keys naming no flag of fs are ignored, since the
+// config is shared by all subcommands; strict rejects those close to the
+// name of a flag as typos, such as colum, and logs the others, as they may
+// be keys only the plugin or another subcommand uses.
+func (c *Config) applyFlags(fs *flag.FlagSet, strict bool) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(c.Flags))
+	for key := range c.Flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if name == "config" || name == "vault" {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			if !strict {
+				continue
+			}
+			if hint := suggestion(key, settingNames(fs)); hint != "" {
+				return fmt.Errorf("unknown setting '%s' on line %d of the config file%s", key, c.lines[key], hint)
+			}
+			log.Printf("WARNING: Ignoring unknown setting '%s' on line %d of the config file", key, c.lines[key])
+			continue
+		}
+		if given[name] {
+			continue
+		}
+		value, err := flagValue(c.Flags[key])
+		if err != nil {
+			return fmt.Errorf("invalid setting '%s' on line %d of the config file: %w", key, c.lines[key], err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid setting '%s' on line %d of the config file: %w", key, c.lines[key], err)
+		}
+	}
+	return nil
+}
+
+// settingNames returns the keys the config file accepts for the flags of fs
+// and its structured settings.
+func settingNames(fs *flag.FlagSet) []string {
+	names := slices.Collect(maps.Keys(configSchema.Properties))
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, strings.ReplaceAll(f.Name, "-", "_"))
+	})
+	return names
+}
+
+// flagValue formats a config value as the value of a command-line flag.
+func flagValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, part := range v {
+			s, err := flagValue(part)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("expected a value or a list, got a mapping")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// loadConfig reads the config file at path. An empty path yields an empty
+// config, so every setting is optional. The file is checked against the
+// config schema first, and every mistake found is reported with its line.
+// Files of an older version are migrated in memory first; see
+// configMigrationResult.save.
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseConfig(path, data)
+}
+
+// parseConfig parses and checks data, the text of the config file at path.
+func parseConfig(path string, data []byte) (*Config, error) {
+	cfg := &Config{}
+	var err error
+
+	// JSON, as in the plugin's data.json, is valid YAML.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if cfg.migration, err = migrateConfig(path, data, &doc); err != nil {
+		return nil, err
+	}
+	if cfg.migration != nil {
+		log.Printf("INFO: Migrated config file %s from version %d to %d:\n%s", path, cfg.migration.from, configVersion, cfg.migration.describe())
+		doc = yaml.Node{}
+		if err := yaml.Unmarshal(cfg.migration.data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse migrated config file %s: %w", path, err)
+		}
+		cfg.migration.originalLines(&doc)
+	}
+	problems := &configProblems{path: path}
+	cfg.lines = make(map[string]int)
+	checkConfigNode(&doc, strings.HasSuffix(path, pluginDataPath), problems, cfg.lines)
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	if err := doc.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	cfg.checkCategoryReferences(problems)
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
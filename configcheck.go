@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/categorize"
+	"gopkg.in/yaml.v3"
+)
+
+// configSchema is the JSON schema of the config file, as exported by the
+// templates subcommand. Only the parts of JSON schema it uses are supported.
+var configSchema = mustParseSchema(bundledTemplate("config.schema.json"))
+
+// schema is a JSON schema, or a part of one.
+type schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *additional        `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+}
+
+// schemaTypes is the type of a schema, which is a single type or a list.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// additional is the additionalProperties of an object schema: false, true,
+// or the schema of the other properties.
+type additional struct {
+	forbidden bool
+	schema    *schema
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if json.Unmarshal(data, &allowed) == nil {
+		a.forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(data, &a.schema)
+}
+
+func mustParseSchema(text string) *schema {
+	var s schema
+	if err := json.Unmarshal([]byte(text), &s); err != nil {
+		panic(fmt.Sprintf("invalid bundled config schema: %v", err))
+	}
+	return &s
+}
+
+// configProblem is a mistake in the config file, at the line it was found.
+type configProblem struct {
+	line    int
+	message string
+}
+
+// configProblems collects the mistakes in a config file, so they can all be
+// fixed at once.
+type configProblems struct {
+	path     string
+	problems []configProblem
+}
+
+func (p *configProblems) add(line int, format string, args ...any) {
+	p.problems = append(p.problems, configProblem{line: line, message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems as an error, or nil if there are none.
+func (p *configProblems) err() error {
+	if len(p.problems) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(p.problems, func(a, b configProblem) int {
+		return a.line - b.line
+	})
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invalid config file %s:", p.path)
+	for _, problem := range p.problems {
+		fmt.Fprintf(&sb, "\n  line %d: %s", problem.line, problem.message)
+	}
+	return errors.New(sb.String())
+}
+
+// checkConfigNode validates a parsed config file against configSchema and
+// records the line of every key and list element by its path, such as
+// raw_categories[1], for later checks. With lenient, top-level keys the
+// schema doesn't describe are not checked, as in the plugin's settings,
+// which hold keys only the plugin uses.
+func checkConfigNode(doc *yaml.Node, lenient bool, problems *configProblems, lines map[string]int) {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		if doc.Kind != 0 && doc.Tag != "!!null" {
+			problems.add(doc.Line, "the config file should be a mapping of settings, not %s", nodeType(doc))
+		}
+		return
+	}
+
+	root := *configSchema
+	if lenient {
+		root.AdditionalProperties = nil
+	}
+	checkNode(doc, &root, "", problems, lines)
+}
+
+// checkNode validates node against s, reporting problems under path.
+func checkNode(node *yaml.Node, s *schema, path string, problems *configProblems, lines map[string]int) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	lines[path] = node.Line
+
+	actual := nodeType(node)
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(expected string) bool { return typeMatches(expected, actual) }) {
+		problems.add(node.Line, "'%s' should be %s, not %s", path, strings.Join(withArticles(s.Type), " or "), withArticle(actual))
+		return
+	}
+
+	if len(s.Enum) > 0 && node.Kind == yaml.ScalarNode {
+		var allowed []string
+		for _, value := range s.Enum {
+			allowed = append(allowed, fmt.Sprint(value))
+		}
+		if !slices.ContainsFunc(allowed, func(value string) bool { return strings.EqualFold(value, strings.TrimSpace(node.Value)) }) {
+			problems.add(node.Line, "'%s' is '%s'%s, expected one of %s", path, node.Value, suggestion(node.Value, allowed), strings.Join(allowed, ", "))
+		}
+	}
+	if number, err := strconv.ParseFloat(node.Value, 64); err == nil && (actual == "integer" || actual == "number") {
+		if s.Minimum != nil && number < *s.Minimum {
+			problems.add(node.Line, "'%s' should be at least %g", path, *s.Minimum)
+		}
+		if s.ExclusiveMinimum != nil && number <= *s.ExclusiveMinimum {
+			problems.add(node.Line, "'%s' should be more than %g", path, *s.ExclusiveMinimum)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			lines[keyPath] = node.Content[i].Line
+
+			if property, ok := s.Properties[key]; ok {
+				checkNode(value, property, keyPath, problems, lines)
+				continue
+			}
+			switch {
+			case s.AdditionalProperties == nil:
+			case s.AdditionalProperties.forbidden:
+				problems.add(node.Content[i].Line, "unknown setting '%s'%s", keyPath, suggestion(key, slices.Collect(maps.Keys(s.Properties))))
+			case s.AdditionalProperties.schema != nil:
+				checkNode(value, s.AdditionalProperties.schema, keyPath, problems, lines)
+			}
+		}
+	case yaml.SequenceNode:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range node.Content {
+			checkNode(item, s.Items, fmt.Sprintf("%s[%d]", path, i), problems, lines)
+		}
+	}
+}
+
+// nodeType returns the JSON schema type of a YAML node.
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.Tag {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// typeMatches reports whether a value of type actual is valid where expected
+// is. YAML values are rarely quoted, so any scalar but null can be a string.
+func typeMatches(expected string, actual string) bool {
+	switch expected {
+	case actual:
+		return true
+	case "number":
+		return actual == "integer"
+	case "string":
+		return actual == "integer" || actual == "number" || actual == "boolean"
+	}
+	return false
+}
+
+var typeNames = map[string]string{
+	"object":  "a mapping",
+	"array":   "a list",
+	"string":  "a text",
+	"integer": "a whole number",
+	"number":  "a number",
+	"boolean": "true or false",
+	"null":    "empty",
+}
+
+func withArticle(schemaType string) string {
+	if name, ok := typeNames[schemaType]; ok {
+		return name
+	}
+	return schemaType
+}
+
+func withArticles(schemaTypes []string) []string {
+	names := make([]string, len(schemaTypes))
+	for i, schemaType := range schemaTypes {
+		names[i] = withArticle(schemaType)
+	}
+	return names
+}
+
+// suggestion returns a hint at the candidate closest to a misspelled name,
+// such as " (did you mean 'column'?)", or nothing if none is close.
+func suggestion(name string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best == "" || bestDistance >= len(name) {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean '%s'?)", best)
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// checkCategoryReferences reports settings naming a category that neither is
+// built in nor assigned by a tag or keyword rule, which would silently have
+// no effect, such as a model for "bgus".
+func (c *Config) checkCategoryReferences(problems *configProblems) {
+	known := categorize.KnownCategories(c.categorizeConfig())
+	for _, category := range slices.Sorted(maps.Keys(c.CategoryModels)) {
+		if !slices.Contains(known, category) {
+			problems.add(c.lines["category_models."+category], "'category_models' names the unknown category '%s'%s", category, suggestion(category, known))
+		}
+	}
+	for _, category := range slices.Sorted(maps.Keys(c.CategoryWeights)) {
+		if !slices.Contains(known, category) {
+			problems.add(c.lines["category_weights."+category], "'category_weights' names the unknown category '%s'%s", category, suggestion(category, known))
+		}
+	}
+	for i, category := range c.RawCategories {
+		if !slices.ContainsFunc(known, func(name string) bool { return strings.EqualFold(name, category) }) {
+			problems.add(c.lines[fmt.Sprintf("raw_categories[%d]", i)], "'raw_categories' names the unknown category '%s'%s", category, suggestion(category, known))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+// TestParseConfigProblems checks the errors of config files with mistakes:
+// every problem is listed by line, and the category references are only
+// checked once the file matches the schema.
+func TestParseConfigProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "valid",
+			config: "version: 2\nmodel: gpt-4o\ncategory_weights:\n  bugs: 3\n",
+		},
+		{
+			name:   "empty",
+			config: "",
+		},
+		{
+			name:   "not a mapping",
+			config: "- board.md\n",
+			want:   "invalid config file worklog.yaml:\n  line 1: the config file should be a mapping of settings, not array",
+		},
+		{
+			name:   "wrong type",
+			config: "version: 2\ncopies: 5\n",
+			want:   "invalid config file worklog.yaml:\n  line 2: 'copies' should be a list, not a whole number",
+		},
+		{
+			name:   "unknown category weight",
+			config: "version: 2\ncategory_weights:\n  bgus: 3\n",
+			want:   "invalid config file worklog.yaml:\n  line 3: 'category_weights' names the unknown category 'bgus' (did you mean 'bugs'?)",
+		},
+		{
+			name:   "unknown raw category",
+			config: "version: 2\nraw_categories:\n  - features\n  - reveiws\n",
+			want:   "invalid config file worklog.yaml:\n  line 4: 'raw_categories' names the unknown category 'reveiws' (did you mean 'reviews'?)",
+		},
+		{
+			name:   "unknown nested setting",
+			config: "version: 2\noverload:\n  itmes_ratio: 2\n",
+			want:   "invalid config file worklog.yaml:\n  line 3: unknown setting 'overload.itmes_ratio' (did you mean 'items_ratio'?)",
+		},
+		{
+			name:   "below the minimum",
+			config: "version: 2\noverload:\n  items_ratio: -1\n  baseline_weeks: 0\n",
+			want:   "invalid config file worklog.yaml:\n  line 3: 'overload.items_ratio' should be more than 0\n  line 4: 'overload.baseline_weeks' should be at least 1",
+		},
+		{
+			name:   "schema problems first",
+			config: "version: 2\ncopies: 5\nraw_categories: [reveiws]\n",
+			want:   "invalid config file worklog.yaml:\n  line 2: 'copies' should be a list, not a whole number",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig("worklog.yaml", []byte(tt.config))
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("parseConfig() error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestion(t *testing.T) {
+	candidates := []string{"bugs", "features", "reviews"}
+	if got := suggestion("bgus", candidates); got != " (did you mean 'bugs'?)" {
+		t.Errorf("suggestion for a typo = %q", got)
+	}
+	if got := suggestion("Features", candidates); got != " (did you mean 'features'?)" {
+		t.Errorf("suggestion for another case = %q", got)
+	}
+	if got := suggestion("meetings", candidates); got != "" {
+		t.Errorf("suggestion for an unrelated name = %q, want none", got)
+	}
+}
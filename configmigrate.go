@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configVersion is the version of the config file format. Files without a
+// version key are version 1; older files are migrated when they are read.
+const configVersion = 2
+
+// configMigration updates a config file from the previous version to the
+// next, by edits of its text, so comments and formatting survive.
+type configMigration struct {
+	// to is the version the migration produces.
+	to          int
+	description string
+	edits       func(root *yaml.Node) []configEdit
+}
+
+// configMigrations are applied in order to files older than their version.
+var configMigrations = []configMigration{
+	{to: 2, description: "flags are set with underscores, e.g. output_folder instead of output-folder", edits: underscoreFlagKeys},
+}
+
+// configEdit replaces old, found at a line and column of the file, by new.
+type configEdit struct {
+	line   int
+	column int
+	old    string
+	new    string
+}
+
+// configMigrationResult is an older config file migrated to configVersion.
+type configMigrationResult struct {
+	path     string
+	from     int
+	original []byte
+	data     []byte
+	// changes describes the migrations applied.
+	changes []string
+	// writable is false for files written back as is would break, such as
+	// the plugin's settings, which the plugin owns.
+	writable bool
+	// versionLine is the line the version key was inserted at, or 0 if the
+	// file's lines are where they were in the original.
+	versionLine int
+}
+
+// underscoreFlagKeys spells the top-level keys setting flags with
+// underscores, the spelling of the config schema and of the keys editors
+// complete.
+func underscoreFlagKeys(root *yaml.Node) []configEdit {
+	var edits []configEdit
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if _, ok := configSchema.Properties[key.Value]; ok || !strings.Contains(key.Value, "-") {
+			continue
+		}
+		edits = append(edits, configEdit{line: key.Line, column: key.Column, old: key.Value, new: strings.ReplaceAll(key.Value, "-", "_")})
+	}
+	return edits
+}
+
+// migrateConfig brings the config file read from path to configVersion. It
+// returns nil for files at the current version, and an error for files of
+// a newer version, which this build can't read correctly.
+func migrateConfig(path string, data []byte, doc *yaml.Node) (*configMigrationResult, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	version := 1
+	var versionNode *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "version" {
+			versionNode = root.Content[i+1]
+		}
+	}
+	if versionNode != nil {
+		var err error
+		if version, err = strconv.Atoi(versionNode.Value); err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid config file %s:\n  line %d: 'version' should be a whole number from 1 to %d, not '%s'", path, versionNode.Line, configVersion, versionNode.Value)
+		}
+	}
+	if version > configVersion {
+		return nil, fmt.Errorf("config file %s is version %d, but this build of worklog-gen only reads up to version %d; upgrade worklog-gen", path, version, configVersion)
+	}
+	if version == configVersion {
+		return nil, nil
+	}
+
+	text := string(data)
+	var changes []string
+	for _, migration := range configMigrations {
+		if migration.to <= version {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("version %d: %s", migration.to, migration.description))
+		var current yaml.Node
+		if err := yaml.Unmarshal([]byte(text), &current); err != nil {
+			return nil, fmt.Errorf("failed to migrate config file %s to version %d: %w", path, migration.to, err)
+		}
+		if len(current.Content) == 0 || current.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		text = applyConfigEdits(text, migration.edits(current.Content[0]))
+	}
+
+	// JSON, and YAML in flow style, get the version only in memory.
+	writable := !strings.HasPrefix(strings.TrimSpace(text), "{") && !strings.HasSuffix(path, pluginDataPath)
+	versionLine := 0
+	if versionNode != nil {
+		text = applyConfigEdits(text, []configEdit{{line: versionNode.Line, column: versionNode.Column, old: versionNode.Value, new: strconv.Itoa(configVersion)}})
+	} else if writable {
+		text, versionLine = insertConfigVersion(text)
+	}
+
+	return &configMigrationResult{path: path, from: version, original: data, data: []byte(text), changes: changes, writable: writable, versionLine: versionLine}, nil
+}
+
+// originalLines moves the nodes of the migrated file below an inserted
+// version key back to their lines in the original, which problems are
+// reported at.
+func (m *configMigrationResult) originalLines(node *yaml.Node) {
+	if m.versionLine > 0 && node.Line > m.versionLine {
+		node.Line--
+	}
+	for _, child := range node.Content {
+		m.originalLines(child)
+	}
+}
+
+// applyConfigEdits applies edits to text, skipping those whose old text
+// isn't where the edit expects it, e.g. behind quotes.
+func applyConfigEdits(text string, edits []configEdit) string {
+	lines := strings.Split(text, "\n")
+	slices.SortFunc(edits, func(a, b configEdit) int {
+		if a.line != b.line {
+			return b.line - a.line
+		}
+		return b.column - a.column
+	})
+	for _, edit := range edits {
+		if edit.line < 1 || edit.line > len(lines) {
+			continue
+		}
+		line := []rune(lines[edit.line-1])
+		start := edit.column - 1
+		if start < 0 || start > len(line) {
+			continue
+		}
+		rest := string(line[start:])
+		for _, quote := range []string{"", `"`, "'"} {
+			if old := quote + edit.old + quote; strings.HasPrefix(rest, old) {
+				lines[edit.line-1] = string(line[:start]) + quote + edit.new + quote + strings.TrimPrefix(rest, old)
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// insertConfigVersion adds the version key to a config file after its
+// leading comments, such as the schema reference of the example config,
+// returning the text and the line of the key.
+func insertConfigVersion(text string) (string, int) {
+	lines := strings.Split(text, "\n")
+	at := 0
+	for at < len(lines) && (strings.HasPrefix(strings.TrimSpace(lines[at]), "#") || strings.TrimSpace(lines[at]) == "---") {
+		at++
+	}
+	lines = slices.Insert(lines, at, fmt.Sprintf("version: %d", configVersion))
+	return strings.Join(lines, "\n"), at + 1
+}
+
+// describe explains the migration and lists the lines it changed.
+func (m *configMigrationResult) describe() string {
+	return "  " + strings.Join(m.changes, "\n  ") + "\n" + lineDiff(strings.Split(string(m.original), "\n"), strings.Split(string(m.data), "\n"))
+}
+
+// save writes the migrated config file back, keeping the original next to
+// it, so later runs read it without migrating. Files that aren't writable
+// stay as they are and are migrated on every run.
+func (m *configMigrationResult) save() {
+	if m == nil || !m.writable {
+		return
+	}
+	info, err := os.Stat(m.path)
+	if err != nil {
+		log.Printf("WARNING: Failed to save the migrated config file: %v", err)
+		return
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", m.path, m.from)
+	if err := os.WriteFile(backup, m.original, info.Mode().Perm()); err != nil {
+		log.Printf("WARNING: Failed to back up the config file before migrating it: %v", err)
+		return
+	}
+	if err := writeFileAtomic(m.path, m.data); err != nil {
+		log.Printf("WARNING: Failed to save the migrated config file: %v", err)
+		return
+	}
+	if err := os.Chmod(m.path, info.Mode().Perm()); err != nil {
+		log.Printf("WARNING: Failed to keep the permissions of the config file: %v", err)
+	}
+	log.Printf("INFO: Saved the migrated config file %s; the original is in %s", m.path, backup)
+}
+
+// lineDiff returns the lines removed from a and added to it to get b, with
+// their line numbers in a or b and - or +.
+func lineDiff(a []string, b []string) string {
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&sb, "  %4d - %s\n", i+1, a[i])
+			i++
+		default:
+			fmt.Fprintf(&sb, "  %4d + %s\n", j+1, b[j])
+			j++
+		}
+	}
+	return sb.String()
+}
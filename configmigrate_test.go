@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestMigrateConfig checks the text of migrated config files, which keeps
+// their comments and quoting, and which of them are written back.
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		config   string
+		want     string
+		writable bool
+	}{
+		{
+			name:   "current version",
+			path:   "worklog.yaml",
+			config: "version: 2\noutput_folder: Worklogs\n",
+		},
+		{
+			name:     "hyphenated keys",
+			path:     "worklog.yaml",
+			config:   "output-folder: Worklogs\nai-assisted: true\ntags:\n  on-call: bugs\n",
+			want:     "version: 2\noutput_folder: Worklogs\nai_assisted: true\ntags:\n  on-call: bugs\n",
+			writable: true,
+		},
+		{
+			name:     "after leading comments",
+			path:     "worklog.yaml",
+			config:   "# yaml-language-server: $schema=config.schema.json\n\"output-folder\": Worklogs\n",
+			want:     "# yaml-language-server: $schema=config.schema.json\nversion: 2\n\"output_folder\": Worklogs\n",
+			writable: true,
+		},
+		{
+			name:     "version 1",
+			path:     "worklog.yaml",
+			config:   "version: 1\noutput-folder: Worklogs\n",
+			want:     "version: 2\noutput_folder: Worklogs\n",
+			writable: true,
+		},
+		{
+			name:   "json",
+			path:   "worklog.json",
+			config: `{"output-folder": "Worklogs"}`,
+			want:   `{"output_folder": "Worklogs"}`,
+		},
+		{
+			name:   "plugin settings",
+			path:   pluginDataPath,
+			config: "output-folder: Worklogs\n",
+			want:   "output_folder: Worklogs\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.config), &doc); err != nil {
+				t.Fatal(err)
+			}
+			m, err := migrateConfig(tt.path, []byte(tt.config), &doc)
+			if err != nil {
+				t.Fatalf("migrateConfig: %v", err)
+			}
+			if m == nil {
+				if tt.want != "" {
+					t.Fatalf("migrateConfig() = nil, want %q", tt.want)
+				}
+				return
+			}
+			if got := string(m.data); got != tt.want {
+				t.Errorf("migrateConfig() = %q, want %q", got, tt.want)
+			}
+			if m.writable != tt.writable {
+				t.Errorf("writable = %v, want %v", m.writable, tt.writable)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigNewerVersion(t *testing.T) {
+	_, err := parseConfig("worklog.yaml", []byte("version: 3\n"))
+	want := "config file worklog.yaml is version 3, but this build of worklog-gen only reads up to version 2; upgrade worklog-gen"
+	if err == nil || err.Error() != want {
+		t.Errorf("parseConfig() error = %v, want %q", err, want)
+	}
+
+	_, err = parseConfig("worklog.yaml", []byte("model: gpt-4o\nversion: two\n"))
+	want = "invalid config file worklog.yaml:\n  line 2: 'version' should be a whole number from 1 to 2, not 'two'"
+	if err == nil || err.Error() != want {
+		t.Errorf("parseConfig() error = %v, want %q", err, want)
+	}
+}
+
+// TestMigratedConfigLines checks that problems in a migrated file are
+// reported at their lines in the file as the user wrote it, not counting
+// the version key the migration inserts.
+func TestMigratedConfigLines(t *testing.T) {
+	config := "# Work board\noutput-folder: Worklogs\ncopies: 5\n"
+	_, err := parseConfig("worklog.yaml", []byte(config))
+	want := "invalid config file worklog.yaml:\n  line 3: 'copies' should be a list, not a whole number"
+	if err == nil || err.Error() != want {
+		t.Errorf("parseConfig() error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// defaultConfluenceTitle names the pages of worklogs unless
+// --confluence-title is given.
+const defaultConfluenceTitle = "Worklog Week {{.Week}}, {{.Year}}"
+
+// confluenceSink publishes the worklog as a page in a Confluence space,
+// through the REST API that Confluence Cloud, Server, and Data Center share.
+// The page of a week is created under the parent page on the first delivery
+// and updated with a new version on later ones, so republishing a week
+// never creates a second page.
+type confluenceSink struct {
+	api    atlassianAPI
+	space  string
+	parent string
+	title  *template.Template
+}
+
+// newConfluenceSink creates a sink publishing to the space at baseURL, e.g.
+// https://example.atlassian.net/wiki. With a user, the token is an API token
+// sent with basic authentication, as Confluence Cloud expects; without one,
+// it is a personal access token of Server and Data Center.
+func newConfluenceSink(baseURL string, space string, parent string, user string, token string, title string) (*confluenceSink, error) {
+	if space == "" || token == "" {
+		return nil, fmt.Errorf("confluence publishing requires a space key (--confluence-space) and an API token (--confluence-token, CONFLUENCE_API_TOKEN, or the secret 'confluence' of the config file)")
+	}
+	if title == "" {
+		title = defaultConfluenceTitle
+	}
+	tmpl, err := template.New("confluence").Funcs(templateFuncs).Parse(title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Confluence page title: %w", err)
+	}
+	return &confluenceSink{
+		api:    newAtlassianAPI(baseURL, user, token),
+		space:  space,
+		parent: parent,
+		title:  tmpl,
+	}, nil
+}
+
+func (s *confluenceSink) Name() string {
+	return "confluence"
+}
+
+// confluencePage is the part of a Confluence page the sink reads and writes.
+type confluencePage struct {
+	ID        string              `json:"id,omitempty"`
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Space     map[string]string   `json:"space"`
+	Ancestors []map[string]string `json:"ancestors,omitempty"`
+	Version   *confluenceVersion  `json:"version,omitempty"`
+	Body      map[string]any      `json:"body,omitempty"`
+	Links     map[string]string   `json:"_links,omitempty"`
+}
+
+type confluenceVersion struct {
+	Number int `json:"number"`
+}
+
+func (s *confluenceSink) Send(ctx context.Context, report *Report) error {
+	var title bytes.Buffer
+	data := struct{ Year, Week int }{report.Doc.Year, report.Doc.Week}
+	if err := s.title.Execute(&title, data); err != nil {
+		return fmt.Errorf("failed to render the page title: %w", err)
+	}
+	storage, err := confluenceStorage(report.Markdown())
+	if err != nil {
+		return err
+	}
+
+	page := confluencePage{
+		Type:  "page",
+		Title: strings.TrimSpace(title.String()),
+		Space: map[string]string{"key": s.space},
+		Body: map[string]any{
+			"storage": map[string]string{"value": storage, "representation": "storage"},
+		},
+	}
+
+	existing, err := s.findPage(ctx, page.Title)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		if s.parent != "" {
+			page.Ancestors = []map[string]string{{"id": s.parent}}
+		}
+		var created confluencePage
+		if err := s.api.do(ctx, http.MethodPost, "/rest/api/content", page, &created); err != nil {
+			return fmt.Errorf("failed to create page '%s': %w", page.Title, err)
+		}
+		s.logPublished("Created", created)
+		return nil
+	}
+
+	page.ID = existing.ID
+	page.Version = &confluenceVersion{Number: 1}
+	if existing.Version != nil {
+		page.Version.Number = existing.Version.Number + 1
+	}
+	var updated confluencePage
+	if err := s.api.do(ctx, http.MethodPut, "/rest/api/content/"+url.PathEscape(existing.ID), page, &updated); err != nil {
+		return fmt.Errorf("failed to update page '%s': %w", page.Title, err)
+	}
+	s.logPublished("Updated", updated)
+	return nil
+}
+
+// findPage returns the page titled title in the space, or nil if there is
+// none.
+func (s *confluenceSink) findPage(ctx context.Context, title string) (*confluencePage, error) {
+	query := url.Values{"spaceKey": {s.space}, "title": {title}, "expand": {"version"}}
+	var found struct {
+		Results []confluencePage `json:"results"`
+	}
+	if err := s.api.do(ctx, http.MethodGet, "/rest/api/content?"+query.Encode(), nil, &found); err != nil {
+		return nil, fmt.Errorf("failed to look up page '%s': %w", title, err)
+	}
+	if len(found.Results) == 0 {
+		return nil, nil
+	}
+	return &found.Results[0], nil
+}
+
+// confluenceStorage converts a markdown worklog to Confluence's storage
+// format, which is XHTML.
+func confluenceStorage(markdown string) (string, error) {
+	var buf bytes.Buffer
+	converter := goldmark.New(goldmark.WithRendererOptions(html.WithXHTML()))
+	if err := converter.Convert([]byte(markdown), &buf); err != nil {
+		return "", fmt.Errorf("failed to convert worklog to Confluence storage format: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// logPublished logs the link to a page the sink created or updated.
+func (s *confluenceSink) logPublished(action string, page confluencePage) {
+	link := page.Links["webui"]
+	if link == "" {
+		log.Printf("INFO: %s Confluence page '%s'", action, page.Title)
+		return
+	}
+	base := page.Links["base"]
+	if base == "" {
+		base = s.api.baseURL
+	}
+	log.Printf("INFO: %s Confluence page %s", action, base+link)
+}
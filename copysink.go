@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// defaultCopyFilename names copies like the worklog in the output folder.
+const defaultCopyFilename = "worklog-week-{{.Week}}-{{.Year}}.{{.Extension}}"
+
+// CopyConfig is a further folder receiving a copy of every worklog, such as
+// a mounted team share next to the vault.
+type CopyConfig struct {
+	// Name identifies the copy as a sink, e.g. in publish --to; by default
+	// copies are named copy, copy-2, and so on.
+	Name   string `yaml:"name"`
+	Folder string `yaml:"folder"`
+	// Filename is a text/template of the file name, relative to the folder,
+	// with .Year, .Week, .Extension, and the template functions, e.g.
+	// "{{.Year}}/W{{.Week}} Ben.{{.Extension}}".
+	Filename string `yaml:"filename"`
+}
+
+// copyFilenameData is passed to the file name templates of copies.
+type copyFilenameData struct {
+	Year      int
+	Week      int
+	Extension string
+}
+
+// copySink writes the worklog to a folder other than the output folder, as a
+// sink so that each copy is reported, retried by publish, and can have its
+// own --sink-template.
+type copySink struct {
+	name     string
+	folder   string
+	filename *template.Template
+}
+
+// newCopySinks creates a sink per folder given with --copy-to and per copy
+// in the config file.
+func newCopySinks(folders []string, copies []CopyConfig) ([]Sink, error) {
+	copies = slices.Clone(copies)
+	for _, folder := range folders {
+		copies = append(copies, CopyConfig{Folder: folder})
+	}
+	var sinks []Sink
+	names := make(map[string]bool)
+	for i, dest := range copies {
+		if strings.TrimSpace(dest.Folder) == "" {
+			return nil, fmt.Errorf("copy %d has no folder", i+1)
+		}
+		name := strings.ToLower(strings.TrimSpace(dest.Name))
+		if name == "" {
+			name = "copy"
+			for n := 2; names[name]; n++ {
+				name = fmt.Sprintf("copy-%d", n)
+			}
+		}
+		if names[name] {
+			return nil, fmt.Errorf("several copies are named '%s'", name)
+		}
+		names[name] = true
+
+		filename := dest.Filename
+		if filename == "" {
+			filename = defaultCopyFilename
+		}
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the file name of copy '%s': %w", name, err)
+		}
+		sinks = append(sinks, &copySink{name: name, folder: dest.Folder, filename: tmpl})
+	}
+	return sinks, nil
+}
+
+func (s *copySink) Name() string {
+	return s.name
+}
+
+func (s *copySink) Send(ctx context.Context, report *Report) error {
+	extension := report.Format
+	if renderer, err := output.LookupRenderer(report.Format); err == nil {
+		extension = renderer.Extension
+	}
+	var name bytes.Buffer
+	if err := s.filename.Execute(&name, copyFilenameData{Year: report.Doc.Year, Week: report.Doc.Week, Extension: extension}); err != nil {
+		return fmt.Errorf("failed to render the file name: %w", err)
+	}
+	filename := filepath.FromSlash(strings.TrimSpace(name.String()))
+	if filename == "" || !filepath.IsLocal(filename) {
+		return fmt.Errorf("the file name '%s' is not a path within %s", name.String(), s.folder)
+	}
+
+	path := filepath.Join(s.folder, filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+	written, err := writeFileSafely(path, []byte(report.Content))
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", written, err)
+	}
+	log.Printf("INFO: Saved a copy of the worklog to %s", written)
+	return nil
+}
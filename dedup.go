@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// duplicateThreshold is the share of significant words two titles from
+// different sources must have in common to count as the same work.
+const duplicateThreshold = 0.8
+
+// dedupItems merges items from different sources that describe the same
+// work, e.g. a board card and the pull request implementing it, so the work
+// is only counted once. Items are the same when they share a link or their
+// titles are nearly identical. The first item is kept and enriched with the
+// links of the items merged into it. Items from the same source are never
+// merged, as two similar cards on one board are separate work.
+func dedupItems(items []worklog.Item) []worklog.Item {
+	var result []worklog.Item
+	for _, item := range items {
+		merged := false
+		for i := range result {
+			if slices.Contains(result[i].Sources(), item.Source) {
+				continue
+			}
+			if reason, ok := sameWork(result[i], item); ok {
+				activeTrace.merge(item, result[i], reason)
+				result[i].Merged = append(result[i].Merged, item)
+				for _, link := range item.Links {
+					if !slices.Contains(result[i].Links, link) {
+						result[i].Links = append(result[i].Links, link)
+					}
+				}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// sameWork reports whether a and b describe the same work, and why.
+func sameWork(a worklog.Item, b worklog.Item) (string, bool) {
+	for _, link := range a.Links {
+		if slices.Contains(b.Links, link) {
+			return "shared link " + link, true
+		}
+	}
+
+	aWords := significantWords(a.Title)
+	bWords := significantWords(b.Title)
+	if len(aWords) == 0 || len(bWords) == 0 {
+		return "", false
+	}
+	common := 0
+	for word := range aWords {
+		if bWords[word] {
+			common++
+		}
+	}
+	share := float64(common) / float64(min(len(aWords), len(bWords)))
+	if share < duplicateThreshold {
+		return "", false
+	}
+	return fmt.Sprintf("%.0f%% of the words in common", share*100), true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// printDryRun writes the items of a dry run to w, by column and category,
+// followed in AI-assisted mode by the prompt each category would be
+// summarized with, so parsing and tagging can be checked before spending
+// tokens.
+func printDryRun(w io.Writer, categories map[string][]worklog.Item, columns []string, weights worklog.Weights, opts summarize.Options, year int, week int) error {
+	total := 0
+	for _, items := range categories {
+		total += len(items)
+	}
+	fmt.Fprintf(w, "Dry run for week %d %d: %d %s; no LLM calls were made and no files were written\n", week, year, total, worklog.Pluralize(total, "item", "items"))
+
+	order, byColumn := columnCategories(categories, columns)
+	for _, column := range order {
+		if column != "" {
+			fmt.Fprintf(w, "\n=== %s ===\n", column)
+		}
+		for _, category := range worklog.OrderedCategories(byColumn[column], weights) {
+			items := byColumn[column][category]
+			if len(items) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\n## %s (%d)\n\n", output.Section{Category: category}.Title(), len(items))
+			for _, title := range worklog.AttributedTitles(items, opts.Attribution) {
+				fmt.Fprintf(w, "- %s\n", title)
+			}
+
+			if !opts.AIAssisted {
+				continue
+			}
+			if opts.Raw(category) {
+				fmt.Fprintln(w, "\nListed as raw items, not sent to the LLM.")
+				continue
+			}
+			if opts.Batch {
+				continue
+			}
+			prompt, err := summarize.SummaryPrompt(category, items, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\nPrompt:\n\n%s\n", indent(prompt, "    "))
+		}
+
+		if opts.AIAssisted && opts.Batch {
+			prompts, err := summarize.BatchPrompts(byColumn[column], opts)
+			if err != nil {
+				return err
+			}
+			for _, prompt := range prompts {
+				fmt.Fprintf(w, "\nBatched prompt:\n\n%s\n", indent(prompt, "    "))
+			}
+		}
+	}
+	return nil
+}
+
+// indent prefixes every non-empty line of s with prefix.
+func indent(s string, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
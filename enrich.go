@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Enricher annotates an item, e.g. with links, the time spent on it, or a
+// redacted title. Enrichers run in a chain on every item after extraction and
+// before deduplication, so the links they add help to merge items.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, item worklog.Item) (worklog.Item, error)
+}
+
+// enricherOptions collects the command-line settings of all enrichers.
+type enricherOptions struct {
+	ticketURL   string
+	timesheet   string
+	expandLinks bool
+}
+
+func registerEnricherFlags(fs *flag.FlagSet) *enricherOptions {
+	opts := &enricherOptions{}
+	fs.StringVar(&opts.ticketURL, "ticket-url", "", "URL of a ticket with {key} for its key, e.g. https://jira.example.com/browse/{key}, to link items mentioning ticket keys")
+	fs.StringVar(&opts.timesheet, "timesheet", "", "CSV timesheet with ticket and hours columns to add #spent/ tags to items mentioning the tickets")
+	fs.BoolVar(&opts.expandLinks, "expand-links", false, "Replace URLs in item titles with the title of the page")
+	return opts
+}
+
+// build creates the enabled enrichers in the order they run: ticket
+// resolution, time lookup, link expansion, and redaction, which comes last so
+// it also applies to the titles of expanded links.
+func (o *enricherOptions) build(cfg *Config) ([]Enricher, error) {
+	var enrichers []Enricher
+
+	if o.ticketURL != "" {
+		if !strings.Contains(o.ticketURL, "{key}") {
+			return nil, fmt.Errorf("--ticket-url must contain {key}")
+		}
+		enrichers = append(enrichers, &ticketEnricher{urlTemplate: o.ticketURL})
+	}
+
+	if o.timesheet != "" {
+		hours, err := loadTimesheet(o.timesheet)
+		if err != nil {
+			return nil, err
+		}
+		enrichers = append(enrichers, &timesheetEnricher{hours: hours})
+	}
+
+	if o.expandLinks {
+		enrichers = append(enrichers, &linkExpander{titles: make(map[string]string)})
+	}
+
+	if len(cfg.Redact) > 0 {
+		enrichers = append(enrichers, &redactor{terms: newAnonymizer(cfg.Redact)})
+	}
+
+	return enrichers, nil
+}
+
+// enrichItems runs every item through the chain of enrichers.
+func enrichItems(ctx context.Context, enrichers []Enricher, items []worklog.Item) ([]worklog.Item, error) {
+	result := make([]worklog.Item, 0, len(items))
+	for _, item := range items {
+		for _, enricher := range enrichers {
+			enriched, err := enricher.Enrich(ctx, item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", enricher.Name(), err)
+			}
+			if enriched.Title != item.Title {
+				activeTrace.enrich(enricher.Name(), item.Title, enriched.Title)
+			}
+			item = enriched
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// addLink returns links with link appended, unless it is already there.
+func addLink(links []string, link string) []string {
+	if slices.Contains(links, link) {
+		return links
+	}
+	return append(slices.Clip(links), link)
+}
+
+var ticketKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]+-\d+$`)
+
+// ticketEnricher links the tickets an item mentions by key.
+type ticketEnricher struct {
+	urlTemplate string
+}
+
+func (e *ticketEnricher) Name() string {
+	return "ticket resolution"
+}
+
+func (e *ticketEnricher) Enrich(ctx context.Context, item worklog.Item) (worklog.Item, error) {
+	for _, link := range item.Links {
+		if ticketKeyPattern.MatchString(link) {
+			item.Links = addLink(item.Links, strings.ReplaceAll(e.urlTemplate, "{key}", link))
+		}
+	}
+	return item, nil
+}
+
+// timesheetEnricher adds the hours booked on the tickets an item mentions as
+// a #spent/ tag, unless the item already has one.
+type timesheetEnricher struct {
+	hours map[string]float64
+}
+
+func (e *timesheetEnricher) Name() string {
+	return "time lookup"
+}
+
+func (e *timesheetEnricher) Enrich(ctx context.Context, item worklog.Item) (worklog.Item, error) {
+	if _, ok := worklog.SpentHours(item.Title); ok {
+		return item, nil
+	}
+	total := 0.0
+	for _, link := range item.Links {
+		total += e.hours[link]
+	}
+	if total > 0 {
+		item.Title += " #spent/" + strconv.FormatFloat(total, 'f', -1, 64) + "h"
+	}
+	return item, nil
+}
+
+// loadTimesheet sums the hours per ticket of a CSV timesheet with a header
+// row naming a ticket (or key, issue) and an hours column.
+func loadTimesheet(path string) (map[string]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open timesheet: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timesheet: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("timesheet %s is empty", path)
+	}
+	ticketColumn := historyColumn(records[0], []string{"ticket", "key", "issue"})
+	hoursColumn := historyColumn(records[0], []string{"hours", "time", "duration"})
+	if ticketColumn < 0 || hoursColumn < 0 {
+		return nil, fmt.Errorf("timesheet needs a ticket and an hours column, found: %s", strings.Join(records[0], ", "))
+	}
+
+	hours := make(map[string]float64)
+	for _, record := range records[1:] {
+		if ticketColumn >= len(record) || hoursColumn >= len(record) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[hoursColumn]), 64)
+		if err != nil {
+			continue
+		}
+		hours[strings.ToUpper(strings.TrimSpace(record[ticketColumn]))] += value
+	}
+	return hours, nil
+}
+
+var pageTitlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// linkExpander replaces URLs in item titles with the titles of the pages, so
+// a card like "Read https://example.com/post/123" becomes readable. The URL
+// stays in the item's links. Pages that can't be fetched are left as they
+// are.
+type linkExpander struct {
+	titles map[string]string
+}
+
+func (e *linkExpander) Name() string {
+	return "link expansion"
+}
+
+func (e *linkExpander) Enrich(ctx context.Context, item worklog.Item) (worklog.Item, error) {
+	for _, link := range item.Links {
+		isURL := strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://")
+		if !isURL || !strings.Contains(item.Title, link) {
+			continue
+		}
+		title, ok := e.titles[link]
+		if !ok {
+			var err error
+			if title, err = fetchPageTitle(ctx, link); err != nil {
+				log.Printf("WARNING: Could not expand link %s: %v", link, err)
+			}
+			e.titles[link] = title
+		}
+		if title != "" {
+			item.Title = strings.ReplaceAll(item.Title, link, `"`+title+`"`)
+		}
+	}
+	return item, nil
+}
+
+// fetchPageTitle returns the contents of the <title> element of the page at
+// url, or an empty string if it has none.
+func fetchPageTitle(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	head, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	match := pageTitlePattern.FindSubmatch(head)
+	if match == nil {
+		return "", nil
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " "), nil
+}
+
+// redactor replaces the terms configured under redact in item titles, before
+// they are sent to the LLM or written anywhere. Unlike --anonymize, it also
+// applies to the local worklog.
+type redactor struct {
+	terms *anonymizer
+}
+
+func (e *redactor) Name() string {
+	return "redaction"
+}
+
+func (e *redactor) Enrich(ctx context.Context, item worklog.Item) (worklog.Item, error) {
+	item.Title = e.terms.replaceTerms(item.Title)
+	links := make([]string, 0, len(item.Links))
+	for _, link := range item.Links {
+		links = addLink(links, e.terms.replaceTerms(link))
+	}
+	item.Links = links
+	return item, nil
+}
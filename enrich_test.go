@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// TestEnricherChain runs an item through every enricher and checks that the
+// redaction comes last, so it also applies to the title of an expanded link.
+func TestEnricherChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><head><title>Acme &amp; us: the\n  postmortem</title></head></html>")
+	}))
+	defer server.Close()
+	timesheet := filepath.Join(t.TempDir(), "timesheet.csv")
+	if err := os.WriteFile(timesheet, []byte("Ticket,Hours\nOPS-12,1.5\nops-12,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &enricherOptions{ticketURL: "https://jira.example.com/browse/{key}", timesheet: timesheet, expandLinks: true}
+	enrichers, err := opts.build(&Config{Redact: map[string]string{"Acme": "Customer A"}})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var names []string
+	for _, enricher := range enrichers {
+		names = append(names, enricher.Name())
+	}
+	if want := []string{"ticket resolution", "time lookup", "link expansion", "redaction"}; !slices.Equal(names, want) {
+		t.Fatalf("enrichers = %q, want %q", names, want)
+	}
+
+	link := server.URL + "/post/1"
+	items, err := enrichItems(context.Background(), enrichers, []worklog.Item{
+		{Title: "OPS-12 read " + link, Links: []string{"OPS-12", link}},
+	})
+	if err != nil {
+		t.Fatalf("enrichItems: %v", err)
+	}
+	if want := `OPS-12 read "Customer A & us: the postmortem" #spent/3.5h`; items[0].Title != want {
+		t.Errorf("title = %q, want %q", items[0].Title, want)
+	}
+	if want := []string{"OPS-12", link, "https://jira.example.com/browse/OPS-12"}; !slices.Equal(items[0].Links, want) {
+		t.Errorf("links = %q, want %q", items[0].Links, want)
+	}
+}
+
+func TestTicketURLWithoutKey(t *testing.T) {
+	opts := &enricherOptions{ticketURL: "https://jira.example.com/browse/"}
+	if _, err := opts.build(&Config{}); err == nil || !strings.Contains(err.Error(), "{key}") {
+		t.Errorf("build() error = %v, want --ticket-url to need {key}", err)
+	}
+}
+
+func TestLoadTimesheet(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    map[string]float64
+		wantErr string
+	}{
+		{"sums per ticket", "Date,Issue,Duration\n2026-10-12,OPS-1,2\n2026-10-13, ops-1 ,0.5\n", map[string]float64{"OPS-1": 2.5}, ""},
+		{"skips unparsable hours", "ticket,hours\nOPS-1,2h\nOPS-2,\nOPS-3,1\nOPS-4\n", map[string]float64{"OPS-3": 1}, ""},
+		{"missing hours column", "ticket,notes\nOPS-1,2\n", nil, "timesheet needs a ticket and an hours column, found: ticket, notes"},
+		{"missing ticket column", "date,hours\n2026-10-12,2\n", nil, "timesheet needs a ticket and an hours column, found: date, hours"},
+		{"empty", "", nil, "is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "timesheet.csv")
+			if err := os.WriteFile(path, []byte(tt.csv), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadTimesheet(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadTimesheet() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadTimesheet: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("loadTimesheet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTimesheetKeepsSpentTag checks that an item with its own #spent/ tag
+// isn't given another.
+func TestTimesheetKeepsSpentTag(t *testing.T) {
+	e := &timesheetEnricher{hours: map[string]float64{"OPS-1": 3}}
+	for title, want := range map[string]string{
+		"Fix OPS-1 #spent/1h": "Fix OPS-1 #spent/1h",
+		"Fix OPS-1":           "Fix OPS-1 #spent/3h",
+	} {
+		item, err := e.Enrich(context.Background(), worklog.Item{Title: title, Links: []string{"OPS-1"}})
+		if err != nil || item.Title != want {
+			t.Errorf("Enrich(%q) = %q, %v; want %q", title, item.Title, err, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// evalFixture is a recorded set of categorized items to run prompts against.
+type evalFixture struct {
+	Name       string
+	Categories map[string][]string `json:"categories"`
+}
+
+// items turns the fixture's card titles into items.
+func (f evalFixture) items() map[string][]worklog.Item {
+	categories := make(map[string][]worklog.Item, len(f.Categories))
+	for category, titles := range f.Categories {
+		categories[category] = worklog.NewItems(worklog.SourceFixture, titles)
+	}
+	return categories
+}
+
+type evalVariant struct {
+	name   string
+	model  string
+	prompt *template.Template
+}
+
+func loadEvalFixtures(dir string) ([]evalFixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(paths)
+
+	var fixtures []evalFixture
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		var fixture evalFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		fixture.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		fixtures = append(fixtures, fixture)
+	}
+
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no *.json fixtures found in %s", dir)
+	}
+	return fixtures, nil
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// runEval implements the eval subcommand. It summarizes recorded item sets
+// with every combination of the given prompts and models and writes a
+// side-by-side comparison, so prompt changes can be judged on real data.
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	fixturesDir := fs.String("fixtures", "", `Folder of JSON fixtures shaped like {"categories": {"bugs": ["..."]}}`)
+	prompts := fs.String("prompts", "", "Comma-separated prompt template files to compare (default: the built-in prompt)")
+	models := fs.String("models", summarize.DefaultModel, "Comma-separated models to compare")
+	outputPath := fs.String("output", "", "File to write the comparison report to (default: stdout)")
+	apiKey := fs.String("api-key", "", "API key of the LLM provider, or a comma-separated list of keys to rotate across (can also be set via OPENAI_API_KEY, ANTHROPIC_API_KEY, or GEMINI_API_KEY env var)")
+	registerProviderFlags(fs)
+	contextPath := fs.String("context", "", "Path to a markdown file injected into every prompt")
+	recordingOpts := registerRecordingFlags(fs)
+	fs.Parse(args)
+
+	if err := recordingOpts.apply(); err != nil {
+		return err
+	}
+
+	if *fixturesDir == "" {
+		return fmt.Errorf("fixtures flag is required")
+	}
+
+	fixtures, err := loadEvalFixtures(*fixturesDir)
+	if err != nil {
+		return err
+	}
+
+	key, err := resolveAPIKey(*apiKey)
+	if err != nil {
+		return err
+	}
+	client := newLLMClient(key)
+	background, err := loadContextFile(*contextPath)
+	if err != nil {
+		return err
+	}
+
+	promptPaths := splitList(*prompts)
+	if len(promptPaths) == 0 {
+		promptPaths = []string{""}
+	}
+
+	var variants []evalVariant
+	for _, path := range promptPaths {
+		var tmpl *template.Template
+		promptName := "default"
+		if path != "" {
+			if tmpl, err = summarize.LoadPromptTemplate(path); err != nil {
+				return err
+			}
+			promptName = filepath.Base(path)
+		}
+		for _, model := range splitList(*models) {
+			variants = append(variants, evalVariant{name: promptName + " / " + model, model: model, prompt: tmpl})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Prompt evaluation\n\n")
+	sb.WriteString(fmt.Sprintf("%d fixtures × %d variants\n\n", len(fixtures), len(variants)))
+
+	for _, fixture := range fixtures {
+		sb.WriteString(fmt.Sprintf("## %s\n\n", fixture.Name))
+
+		results := make([]map[string][]string, len(variants))
+		for i, variant := range variants {
+			log.Printf("INFO: Running fixture '%s' with %s", fixture.Name, variant.name)
+			results[i], err = summarize.ByCategory(fixture.items(), summarize.Options{
+				Client:     client,
+				AIAssisted: true,
+				Model:      variant.model,
+				Context:    background,
+				Prompt:     variant.prompt,
+			})
+			if err != nil {
+				return fmt.Errorf("fixture '%s', variant '%s': %w", fixture.Name, variant.name, err)
+			}
+		}
+
+		sb.WriteString("| Category |")
+		for _, variant := range variants {
+			sb.WriteString(" " + escapeTableCell(variant.name) + " |")
+		}
+		sb.WriteString("\n|---|" + strings.Repeat("---|", len(variants)) + "\n")
+
+		for _, category := range worklog.OrderedCategories(fixture.Categories, nil) {
+			if len(fixture.Categories[category]) == 0 {
+				continue
+			}
+			sb.WriteString("| " + escapeTableCell(output.Section{Category: category}.Title()) + " |")
+			for _, result := range results {
+				sb.WriteString(" " + escapeTableCell(strings.Join(result[category], "\n")) + " |")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if *outputPath == "" {
+		fmt.Print(sb.String())
+		return nil
+	}
+	if err := os.WriteFile(*outputPath, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write evaluation report: %w", err)
+	}
+	log.Printf("SUCCESS: Wrote evaluation report to %s", *outputPath)
+	return nil
+}
+
+// escapeTableCell keeps multi-line text inside a single markdown table cell.
+func escapeTableCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
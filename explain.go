@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ben/obsidian-worklog-gen/categorize"
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// explainTrace collects the decisions made during a run for --explain, so a
+// surprising worklog can be traced back to its causes. Like the cassette, it
+// is process-wide; a nil trace records nothing.
+type explainTrace struct {
+	mu         sync.Mutex
+	included   []tracedItem
+	excluded   []tracedItem
+	merged     []tracedMerge
+	enriched   []tracedEnrichment
+	decisions  []categorize.Decision
+	locked     []string
+	prompts    []tracedPrompt
+	categories map[string][]worklog.Item
+}
+
+type tracedItem struct {
+	Title  string
+	Reason string
+}
+
+type tracedMerge struct {
+	Item   worklog.Item
+	Into   worklog.Item
+	Reason string
+}
+
+type tracedEnrichment struct {
+	Enricher string
+	Before   string
+	After    string
+}
+
+type tracedPrompt struct {
+	Model    string
+	Prompt   string
+	Response string
+}
+
+// activeTrace is set by --explain.
+var activeTrace *explainTrace
+
+func (t *explainTrace) include(items []worklog.Item, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, item := range items {
+		t.included = append(t.included, tracedItem{Title: item.Title, Reason: reason})
+	}
+}
+
+func (t *explainTrace) exclude(title string, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.excluded = append(t.excluded, tracedItem{Title: title, Reason: reason})
+}
+
+func (t *explainTrace) merge(item worklog.Item, into worklog.Item, reason string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.merged = append(t.merged, tracedMerge{Item: item, Into: into, Reason: reason})
+}
+
+func (t *explainTrace) enrich(enricher string, before string, after string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enriched = append(t.enriched, tracedEnrichment{Enricher: enricher, Before: before, After: after})
+}
+
+func (t *explainTrace) categorize(decisions []categorize.Decision, categories map[string][]worklog.Item) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.decisions = decisions
+	t.categories = categories
+}
+
+func (t *explainTrace) lock(section string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.locked = append(t.locked, section)
+}
+
+func (t *explainTrace) prompt(model string, prompt string, response string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prompts = append(t.prompts, tracedPrompt{Model: model, Prompt: prompt, Response: response})
+}
+
+// render writes the trace as markdown. Generated bullets are traced back to
+// the items they were most likely derived from, see matchItems.
+func (t *explainTrace) render(doc *output.Document) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Explanation of week %d %d\n\n", doc.Week, doc.Year))
+
+	sb.WriteString("## Included items\n\n")
+	for _, item := range t.included {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", item.Title, item.Reason))
+	}
+	if len(t.included) == 0 {
+		sb.WriteString("None.\n")
+	}
+
+	sb.WriteString("\n## Excluded items\n\n")
+	for _, item := range t.excluded {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", item.Title, item.Reason))
+	}
+	for _, merge := range t.merged {
+		sb.WriteString(fmt.Sprintf("- %s (%s): merged into %s (%s), %s\n", merge.Item.Title, merge.Item.Source, merge.Into.Title, merge.Into.Source, merge.Reason))
+	}
+	if len(t.excluded) == 0 && len(t.merged) == 0 {
+		sb.WriteString("None.\n")
+	}
+
+	if len(t.enriched) > 0 {
+		sb.WriteString("\n## Enrichment\n\n")
+		for _, enrichment := range t.enriched {
+			sb.WriteString(fmt.Sprintf("- %s: %s → %s\n", enrichment.Enricher, enrichment.Before, enrichment.After))
+		}
+	}
+
+	sb.WriteString("\n## Categories\n\n")
+	for _, decision := range t.decisions {
+		sb.WriteString(fmt.Sprintf("- %s → %s (%s: %s)\n", decision.Item.Title, decision.Category, decision.Strategy, decision.Rule))
+	}
+	for _, section := range t.locked {
+		sb.WriteString(fmt.Sprintf("- Section '%s' was kept as edited by hand and not regenerated\n", section))
+	}
+
+	if doc.AIAssisted {
+		sb.WriteString("\n## Bullets\n")
+		for _, section := range doc.Sections {
+			if section.Manual != "" || len(section.KeyPoints) == 0 {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("\n### %s\n\n", section.Title()))
+			for _, point := range section.KeyPoints {
+				sb.WriteString(fmt.Sprintf("- %s\n", point))
+				matched := matchItems(point, t.categories[section.Category])
+				if len(matched) == 0 {
+					sb.WriteString("  - not traceable to an item\n")
+				}
+				for _, item := range matched {
+					sb.WriteString(fmt.Sprintf("  - from: %s\n", item.Title))
+				}
+			}
+		}
+	}
+
+	if len(t.prompts) > 0 {
+		sb.WriteString("\n## Prompts\n\n")
+		for i, prompt := range t.prompts {
+			sb.WriteString(fmt.Sprintf("### Prompt %d (%s)\n\n", i+1, prompt.Model))
+			sb.WriteString(fence(prompt.Prompt))
+			sb.WriteString("Response:\n\n")
+			sb.WriteString(fence(prompt.Response))
+		}
+	}
+
+	return sb.String()
+}
+
+// fence wraps text in a code fence longer than any backtick run in it.
+func fence(text string) string {
+	marker := "```"
+	for strings.Contains(text, marker) {
+		marker += "`"
+	}
+	return marker + "\n" + strings.TrimRight(text, "\n") + "\n" + marker + "\n\n"
+}
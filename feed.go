@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+)
+
+const maxFeedEntries = 20
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link,omitempty"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// updateFeed rewrites atom.xml in outputFolder with the most recent weekly
+// worklogs. Entry links are only written when baseURL is known.
+func updateFeed(outputFolder string, baseURL string, author string) (string, error) {
+	files, err := listWorklogs(outputFolder)
+	if err != nil {
+		return "", err
+	}
+
+	absFolder, err := filepath.Abs(outputFolder)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve output folder: %w", err)
+	}
+	feedID := fmt.Sprintf("urn:worklog-gen:%x", sha1.Sum([]byte(absFolder)))
+	if baseURL != "" {
+		feedID = strings.TrimRight(baseURL, "/") + "/atom.xml"
+	}
+
+	if author == "" {
+		author = "worklog-gen"
+	}
+
+	feed := atomFeed{
+		ID:     feedID,
+		Title:  "Worklog",
+		Author: atomAuthor{Name: author},
+	}
+	if baseURL != "" {
+		feed.Links = []atomLink{{Href: feedID, Rel: "self"}}
+	}
+
+	var latest time.Time
+	seen := make(map[[2]int]bool)
+	for _, file := range files {
+		key := [2]int{file.Year, file.Week}
+		if seen[key] || len(feed.Entries) == maxFeedEntries {
+			continue
+		}
+		seen[key] = true
+
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read worklog: %w", err)
+		}
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read worklog: %w", err)
+		}
+
+		content := atomContent{Type: "text", Body: string(data)}
+		if file.Extension == "md" {
+			var html bytes.Buffer
+			if err := goldmark.Convert(data, &html); err != nil {
+				return "", fmt.Errorf("failed to convert %s to HTML: %w", file.Path, err)
+			}
+			content = atomContent{Type: "html", Body: html.String()}
+		}
+
+		entry := atomEntry{
+			ID:      fmt.Sprintf("%s:week-%d-%d", feedID, file.Year, file.Week),
+			Title:   fmt.Sprintf("Week %d %d", file.Week, file.Year),
+			Updated: info.ModTime().UTC().Format(time.RFC3339),
+			Content: content,
+		}
+		if baseURL != "" {
+			link := strings.TrimRight(baseURL, "/") + "/" + url.PathEscape(filepath.Base(file.Path))
+			entry.ID = link
+			entry.Links = []atomLink{{Href: link, Rel: "alternate"}}
+		}
+		feed.Entries = append(feed.Entries, entry)
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	feed.Updated = latest.UTC().Format(time.RFC3339)
+
+	encoded, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode feed: %w", err)
+	}
+
+	path, err := writeFileSafely(filepath.Join(outputFolder, "atom.xml"), append([]byte(xml.Header), append(encoded, '\n')...))
+	if err != nil {
+		return "", fmt.Errorf("failed to write feed: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// runGit runs git in dir and returns its standard output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+const defaultCommitMessage = "Add worklog for week {{.Week}} {{.Year}}"
+
+// commitData is available to commit message templates.
+type commitData struct {
+	Year  int
+	Week  int
+	Items int
+	Files []string
+}
+
+// commitWorklog commits the given files, and only those, in the git
+// repository holding them, pushing afterwards if push is set. Files that
+// didn't change are not committed.
+func commitWorklog(files []string, messageTemplate string, data commitData, push bool) error {
+	tmpl, err := template.New("commit").Funcs(templateFuncs).Parse(messageTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse commit message template: %w", err)
+	}
+	var message strings.Builder
+	if err := tmpl.Execute(&message, data); err != nil {
+		return fmt.Errorf("failed to render commit message: %w", err)
+	}
+
+	dir := filepath.Dir(files[0])
+	root, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	root = strings.TrimSpace(root)
+
+	var paths []string
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, abs)
+	}
+
+	if _, err := runGit(root, append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+	if _, err := runGit(root, append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		log.Println("INFO: Worklog unchanged, nothing to commit")
+		return nil
+	}
+	if _, err := runGit(root, append([]string{"commit", "-m", message.String(), "--"}, paths...)...); err != nil {
+		return err
+	}
+	log.Printf("INFO: Committed %s", strings.Join(files, ", "))
+
+	if push {
+		if _, err := runGit(root, "push"); err != nil {
+			return err
+		}
+		log.Println("INFO: Pushed worklog commit")
+	}
+	return nil
+}
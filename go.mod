@@ -2,6 +2,10 @@ module github.com/ben/obsidian-worklog-gen
 
 go 1.23.4
 
-require github.com/sashabaranov/go-openai v1.38.1
-
-require github.com/yuin/goldmark v1.7.8 // indirect
+require (
+	github.com/sashabaranov/go-openai v1.38.1
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/crypto v0.41.0
+	golang.org/x/text v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+const sourceBrowser = "browser"
+
+// Header names used for the columns of browser history exports by the
+// common browsers and history export extensions.
+var (
+	historyURLColumns   = []string{"url", "link", "address"}
+	historyTitleColumns = []string{"title", "name", "page title"}
+	historyTimeColumns  = []string{"visit time", "visittime", "last visit time", "lastvisittime", "date", "time", "timestamp", "visited", "visit_time", "last_visit_time"}
+)
+
+var historyTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"1/2/2006, 3:04:05 PM",
+	"1/2/2006 15:04",
+	"2006-01-02",
+}
+
+// historySource turns pages visited on configured domains, such as papers on
+// arxiv.org or internal docs, into "learning" items, capturing research that
+// never becomes a card. Each page counts once, however often it was visited.
+type historySource struct {
+	path    string
+	domains []string
+}
+
+func (s *historySource) Name() string {
+	return "browser history"
+}
+
+func (s *historySource) Items(ctx context.Context, start time.Time, end time.Time) ([]worklog.Item, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open browser history: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read browser history header: %w", err)
+	}
+	urlColumn := historyColumn(header, historyURLColumns)
+	titleColumn := historyColumn(header, historyTitleColumns)
+	timeColumn := historyColumn(header, historyTimeColumns)
+	if urlColumn < 0 || timeColumn < 0 {
+		return nil, fmt.Errorf("browser history needs a URL and a visit time column, found: %s", strings.Join(header, ", "))
+	}
+
+	var items []worklog.Item
+	seen := make(map[string]bool)
+	invalid := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read browser history: %w", err)
+		}
+		if urlColumn >= len(record) || timeColumn >= len(record) {
+			invalid++
+			continue
+		}
+
+		visited, ok := parseVisitTime(record[timeColumn])
+		if !ok {
+			invalid++
+			continue
+		}
+		if visited.Before(start) || !visited.Before(end) {
+			continue
+		}
+
+		pageURL := strings.TrimSpace(record[urlColumn])
+		if seen[pageURL] || !s.matchesDomain(pageURL) {
+			continue
+		}
+		seen[pageURL] = true
+
+		title := pageURL
+		if titleColumn >= 0 && titleColumn < len(record) && strings.TrimSpace(record[titleColumn]) != "" {
+			title = strings.TrimSpace(record[titleColumn])
+		}
+		day := time.Date(visited.Year(), visited.Month(), visited.Day(), 0, 0, 0, 0, time.Local)
+		item := worklog.NewDatedItem(sourceBrowser, title+" #learn", day)
+		if !strings.Contains(title, pageURL) {
+			// The link lets the page be merged with a card about it.
+			item.Links = append(item.Links, pageURL)
+		}
+		items = append(items, item)
+	}
+
+	if invalid > 0 {
+		log.Printf("WARNING: Skipped %d browser history entries without a readable URL or visit time", invalid)
+	}
+	return items, nil
+}
+
+// matchesDomain reports whether the page is on one of the domains or their
+// subdomains.
+func (s *historySource) matchesDomain(pageURL string) bool {
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range s.domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// historyColumn returns the index of the first header matching one of names,
+// or -1.
+func historyColumn(header []string, names []string) int {
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		for _, name := range names {
+			if column == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseVisitTime parses the visit times of history exports: formatted dates
+// and times in local time, or Unix timestamps in seconds, milliseconds, or
+// microseconds.
+func parseVisitTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch {
+		case n > 1e15:
+			return time.UnixMicro(n), true
+		case n > 1e12:
+			return time.UnixMilli(n), true
+		default:
+			return time.Unix(n, 0), true
+		}
+	}
+	for _, layout := range historyTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// buildICS renders every card with a completion date as an all-day calendar
+// event on that day, so the worklog can be overlaid on a calendar.
+func buildICS(categories map[string][]worklog.Item, now time.Time) (string, int) {
+	var sb strings.Builder
+	writeLine := func(line string) {
+		sb.WriteString(foldICSLine(line))
+		sb.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//obsidian-worklog-gen//worklog//EN")
+	writeLine("CALSCALE:GREGORIAN")
+
+	events := 0
+	for _, category := range worklog.OrderedCategories(categories, nil) {
+		for _, item := range categories[category] {
+			if item.Date.IsZero() {
+				continue
+			}
+			date := item.Date
+
+			writeLine("BEGIN:VEVENT")
+			writeLine("UID:" + item.ID + "@worklog-gen")
+			writeLine("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+			writeLine("DTSTART;VALUE=DATE:" + date.Format("20060102"))
+			writeLine("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format("20060102"))
+			writeLine("SUMMARY:" + escapeICSText(item.Title))
+			writeLine("CATEGORIES:" + escapeICSText(output.Section{Category: category}.Title()))
+			writeLine("TRANSP:TRANSPARENT")
+			writeLine("END:VEVENT")
+			events++
+		}
+	}
+
+	writeLine("END:VCALENDAR")
+	return sb.String(), events
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeICSText(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// foldICSLine splits lines longer than 75 octets as required by RFC 5545,
+// without breaking UTF-8 sequences.
+func foldICSLine(line string) string {
+	var sb strings.Builder
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > 75 {
+			sb.WriteString("\r\n ")
+			length = 1
+		}
+		sb.WriteRune(r)
+		length += size
+	}
+	return sb.String()
+}
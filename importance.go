@@ -0,0 +1,161 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// tagWeights scores priority and incident severity tags. A card's weight is
+// the highest matching tag, so "#p2 #sev1" counts as a sev1.
+var tagWeights = map[string]float64{
+	"p0": 4, "p1": 3, "p2": 2, "p3": 1,
+	"critical": 4, "urgent": 4, "high": 3, "medium": 2, "low": 0.5,
+	"sev0": 5, "sev1": 4, "sev2": 3, "sev3": 2, "sev4": 1,
+	"incident": 3, "outage": 4, "security": 3,
+}
+
+// itemImportance infers how significant a card is from its priority and
+// severity tags plus the time spent on it (#spent/3h, #spent/2d).
+func itemImportance(title string) float64 {
+	score := 0.0
+	for _, tag := range worklog.ExtractTags(title) {
+		if weight, ok := tagWeights[tag]; ok && weight > score {
+			score = weight
+		}
+	}
+
+	// A full working day of effort counts as much as a high priority tag,
+	// capped so long-running chores don't outrank incidents.
+	spent, _ := worklog.SpentHours(title)
+	return score + min(spent/8*3, 4)
+}
+
+// capItems keeps at most limit items, in their original order. Items
+// completed in the given week come first, then the most important ones by
+// itemImportance; ties keep the earlier item. It returns the kept items and
+// those left out.
+func capItems(items []worklog.Item, limit int, year int, week int) ([]worklog.Item, []worklog.Item) {
+	if limit <= 0 || len(items) <= limit {
+		return items, nil
+	}
+
+	inWeek := func(item worklog.Item) bool {
+		if item.Date.IsZero() {
+			return false
+		}
+		itemYear, itemWeek := item.Date.ISOWeek()
+		return itemYear == year && itemWeek == week
+	}
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := items[order[i]], items[order[j]]
+		if inWeek(a) != inWeek(b) {
+			return inWeek(a)
+		}
+		return itemImportance(a.Title) > itemImportance(b.Title)
+	})
+
+	keep := make(map[int]bool, limit)
+	for _, i := range order[:limit] {
+		keep[i] = true
+	}
+	var kept, dropped []worklog.Item
+	for i, item := range items {
+		if keep[i] {
+			kept = append(kept, item)
+		} else {
+			dropped = append(dropped, item)
+		}
+	}
+	return kept, dropped
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "into": true,
+	"that": true, "this": true, "was": true, "were": true, "are": true, "has": true,
+	"have": true, "had": true, "our": true, "its": true, "via": true, "all": true,
+	"new": true, "more": true, "been": true, "also": true, "which": true, "across": true,
+}
+
+// significantWords returns the lowercased words of s that carry meaning,
+// skipping tags, mentions, short words, and stop words.
+func significantWords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "#") || strings.HasPrefix(field, "@") {
+			continue
+		}
+		word := strings.ToLower(strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}))
+		if len([]rune(word)) < 3 || stopWords[word] {
+			continue
+		}
+		words[word] = true
+	}
+	return words
+}
+
+// matchItems returns the items that text was most likely derived from, based
+// on the share of each item's significant words that appear in text. It is
+// how generated bullets are traced back to the cards behind them.
+func matchItems(text string, items []worklog.Item) []worklog.Item {
+	textWords := significantWords(text)
+
+	var matched []worklog.Item
+	for _, item := range items {
+		itemWords := significantWords(item.Title)
+		if len(itemWords) == 0 {
+			continue
+		}
+
+		common := 0
+		for word := range itemWords {
+			if textWords[word] || textWords[strings.TrimSuffix(word, "s")] || textWords[word+"s"] {
+				common++
+			}
+		}
+		if float64(common)/float64(len(itemWords)) >= 0.5 {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
+
+// sortByImportance orders each section's items, or its AI-generated key
+// points, by the inferred importance of the underlying cards. Bullets are
+// scored by the most important card they trace back to.
+func sortByImportance(doc *output.Document, categories map[string][]worklog.Item) {
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		if section.Manual != "" {
+			continue
+		}
+
+		sortStable(section.Items, itemImportance)
+		sortStable(section.KeyPoints, func(point string) float64 {
+			best := 0.0
+			for _, item := range matchItems(point, categories[section.Category]) {
+				best = max(best, itemImportance(item.Title))
+			}
+			return best
+		})
+	}
+}
+
+func sortStable(values []string, score func(string) float64) {
+	scores := make(map[string]float64, len(values))
+	for _, value := range values {
+		scores[value] = score(value)
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return scores[values[i]] > scores[values[j]]
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// jiraSink posts the worklog as a comment on a Jira issue, such as the epic a
+// team tracks its weekly status in, through the REST API version 2 that Jira
+// Cloud, Server, and Data Center share. The comment of a week is updated when
+// the week is delivered again, so republishing a week never posts a second
+// comment.
+type jiraSink struct {
+	api   atlassianAPI
+	issue string
+}
+
+// newJiraSink creates a sink commenting on issue at baseURL, e.g.
+// https://example.atlassian.net. With a user, the token is an API token sent
+// with basic authentication, as Jira Cloud expects; without one, it is a
+// personal access token of Server and Data Center.
+func newJiraSink(baseURL string, issue string, user string, token string) (*jiraSink, error) {
+	if issue == "" || token == "" {
+		return nil, fmt.Errorf("jira publishing requires an issue key (--jira-issue) and an API token (--jira-token, JIRA_API_TOKEN, or the secret 'jira' of the config file)")
+	}
+	return &jiraSink{api: newAtlassianAPI(baseURL, user, token), issue: issue}, nil
+}
+
+func (s *jiraSink) Name() string {
+	return "jira"
+}
+
+// jiraComment is the part of a Jira comment the sink reads and writes.
+type jiraComment struct {
+	ID   string `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+func (s *jiraSink) Send(ctx context.Context, report *Report) error {
+	body := strings.TrimSpace(output.JiraWiki(report.Markdown()))
+	title, _, _ := strings.Cut(body, "\n")
+
+	existing, err := s.findComment(ctx, title)
+	if err != nil {
+		return err
+	}
+	path := "/rest/api/2/issue/" + url.PathEscape(s.issue) + "/comment"
+	if existing == nil {
+		if err := s.api.do(ctx, http.MethodPost, path, jiraComment{Body: body}, nil); err != nil {
+			return fmt.Errorf("failed to comment on issue %s: %w", s.issue, err)
+		}
+		log.Printf("INFO: Commented on Jira issue %s", s.issue)
+		return nil
+	}
+	if err := s.api.do(ctx, http.MethodPut, path+"/"+url.PathEscape(existing.ID), jiraComment{Body: body}, nil); err != nil {
+		return fmt.Errorf("failed to update the comment on issue %s: %w", s.issue, err)
+	}
+	log.Printf("INFO: Updated the comment on Jira issue %s", s.issue)
+	return nil
+}
+
+// findComment returns the latest of the issue's recent comments starting with
+// title, the heading of the week's worklog, or nil if there is none.
+func (s *jiraSink) findComment(ctx context.Context, title string) (*jiraComment, error) {
+	query := url.Values{"orderBy": {"-created"}, "maxResults": {"50"}}
+	path := "/rest/api/2/issue/" + url.PathEscape(s.issue) + "/comment?" + query.Encode()
+	var found struct {
+		Comments []jiraComment `json:"comments"`
+	}
+	if err := s.api.do(ctx, http.MethodGet, path, nil, &found); err != nil {
+		return nil, fmt.Errorf("failed to read the comments of issue %s: %w", s.issue, err)
+	}
+	for i, comment := range found.Comments {
+		if first, _, _ := strings.Cut(strings.TrimSpace(comment.Body), "\n"); first == title {
+			return &found.Comments[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// keyCooldown is how long a rate-limited key is passed over when the
+// response doesn't say when to retry.
+const keyCooldown = 30 * time.Second
+
+// keyPool is an http.RoundTripper spreading LLM requests over several API
+// keys in turn, in the Authorization header or, for Anthropic, Gemini, and
+// Azure OpenAI, their own key header. A request that is rate limited is
+// retried with the next key that isn't cooling down, so one exhausted key
+// doesn't fail the run.
+type keyPool struct {
+	mu   sync.Mutex
+	keys []*pooledKey
+	next int
+	// transport sends the requests; nil uses http.DefaultTransport.
+	transport http.RoundTripper
+}
+
+type pooledKey struct {
+	key       string
+	coolUntil time.Time
+	usage     keyUsage
+}
+
+// keyUsage is the usage of one key for the run report. Keys are identified
+// by their last characters only.
+type keyUsage struct {
+	Key         string     `json:"key"`
+	Requests    int        `json:"requests"`
+	RateLimited int        `json:"rate_limited"`
+	Tokens      tokenUsage `json:"tokens"`
+}
+
+var (
+	keyPoolsMu sync.Mutex
+	// keyPools holds a pool per key list, so all clients of a run share the
+	// rotation and the usage counts.
+	keyPools = make(map[string]*keyPool)
+	// keyPoolOrder holds the pools in the order they were created, the
+	// order of their keys in the run report.
+	keyPoolOrder []*keyPool
+)
+
+// keyPoolFor returns the process-wide pool of a comma-separated key list, or
+// nil for a single key.
+func keyPoolFor(apiKey string, transport http.RoundTripper) *keyPool {
+	keys := splitList(apiKey)
+	if len(keys) < 2 {
+		return nil
+	}
+
+	keyPoolsMu.Lock()
+	defer keyPoolsMu.Unlock()
+	if pool, ok := keyPools[apiKey]; ok {
+		return pool
+	}
+	pool := &keyPool{transport: transport}
+	for _, key := range keys {
+		pool.keys = append(pool.keys, &pooledKey{key: key, usage: keyUsage{Key: maskKey(key)}})
+	}
+	keyPools[apiKey] = pool
+	keyPoolOrder = append(keyPoolOrder, pool)
+	return pool
+}
+
+// maskKey keeps the last four characters of a key, enough to tell keys apart
+// in reports without revealing them.
+func maskKey(key string) string {
+	if len(key) <= 4 {
+		return "..."
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// pick returns the next key in turn that isn't cooling down, skipping the
+// keys in tried. If every key is cooling down, the one available soonest is
+// used.
+func (p *keyPool) pick(tried map[*pooledKey]bool) *pooledKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var soonest *pooledKey
+	for range p.keys {
+		key := p.keys[p.next]
+		p.next = (p.next + 1) % len(p.keys)
+		if tried[key] {
+			continue
+		}
+		if !key.coolUntil.After(now) {
+			key.usage.Requests++
+			return key
+		}
+		if soonest == nil || key.coolUntil.Before(soonest.coolUntil) {
+			soonest = key
+		}
+	}
+	if soonest != nil {
+		soonest.usage.Requests++
+	}
+	return soonest
+}
+
+func (p *keyPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := p.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	tried := make(map[*pooledKey]bool)
+	for {
+		key := p.pick(tried)
+		tried[key] = true
+
+		attempt := req.Clone(req.Context())
+		switch {
+		case attempt.Header.Get("X-Api-Key") != "":
+			attempt.Header.Set("X-Api-Key", key.key)
+		case attempt.Header.Get("X-Goog-Api-Key") != "":
+			attempt.Header.Set("X-Goog-Api-Key", key.key)
+		case attempt.Header.Get(openai.AzureAPIKeyHeader) != "":
+			attempt.Header.Set(openai.AzureAPIKeyHeader, key.key)
+		default:
+			attempt.Header.Set("Authorization", "Bearer "+key.key)
+		}
+		if len(tried) > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		resp, err := transport.RoundTrip(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			p.recordUsage(key, resp)
+			return resp, nil
+		}
+
+		p.mu.Lock()
+		key.usage.RateLimited++
+		key.coolUntil = time.Now().Add(retryAfter(resp.Header))
+		p.mu.Unlock()
+
+		// The body can only be sent again if the request can recreate it.
+		if len(tried) == len(p.keys) || req.GetBody == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+}
+
+// retryAfter reads the time to wait from a rate-limited response.
+func retryAfter(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header.Get("Retry-After"))); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := time.ParseDuration(header.Get("X-Ratelimit-Reset-Requests")); err == nil && reset > 0 {
+		return reset
+	}
+	return keyCooldown
+}
+
+// recordUsage adds the token usage reported in a successful response to the
+// key's counts.
+func (p *keyPool) recordUsage(key *pooledKey, resp *http.Response) {
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	// Anthropic reports input and output tokens instead of prompt and
+	// completion tokens, and Gemini reports usage metadata.
+	var parsed struct {
+		Usage struct {
+			openai.Usage
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return
+	}
+	usage, metadata := parsed.Usage, parsed.UsageMetadata
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key.usage.Tokens.Prompt += usage.PromptTokens + usage.InputTokens + metadata.PromptTokenCount
+	key.usage.Tokens.Completion += usage.CompletionTokens + usage.OutputTokens + metadata.CandidatesTokenCount
+	key.usage.Tokens.Total += usage.TotalTokens + usage.InputTokens + usage.OutputTokens + metadata.TotalTokenCount
+}
+
+// keyUsages returns the usage of every pooled key, for the run report.
+func keyUsages() []keyUsage {
+	keyPoolsMu.Lock()
+	defer keyPoolsMu.Unlock()
+
+	var usages []keyUsage
+	for _, pool := range keyPoolOrder {
+		pool.mu.Lock()
+		for _, key := range pool.keys {
+			usages = append(usages, key.usage)
+		}
+		pool.mu.Unlock()
+	}
+	return usages
+}
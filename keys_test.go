@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// limitedTransport answers 429 for the keys in limited and 200 otherwise,
+// recording the key of every request and checking that the body is sent
+// again on retries.
+func limitedTransport(t *testing.T, header string, limited map[string]bool, sent *[]string) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		key := strings.TrimPrefix(req.Header.Get(header), "Bearer ")
+		*sent = append(*sent, key)
+		if body, _ := io.ReadAll(req.Body); string(body) != "prompt" {
+			t.Errorf("request with %s has body %q, want %q", key, body, "prompt")
+		}
+		status := http.StatusOK
+		if limited[key] {
+			status = http.StatusTooManyRequests
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+}
+
+func newTestPool(transport http.RoundTripper) *keyPool {
+	pool := &keyPool{transport: transport}
+	for _, key := range []string{"key-a", "key-b", "key-c"} {
+		pool.keys = append(pool.keys, &pooledKey{key: key})
+	}
+	return pool
+}
+
+func postPrompt(t *testing.T, pool *keyPool, header string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "https://llm.example.com/v1/chat", strings.NewReader("prompt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != "Authorization" {
+		req.Header.Set(header, "placeholder")
+	}
+	resp, err := pool.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestKeyPoolRotation follows requests through a pool of three keys, one of
+// which is rate limited: it cools down while the others take turns.
+func TestKeyPoolRotation(t *testing.T) {
+	var sent []string
+	pool := newTestPool(limitedTransport(t, "Authorization", map[string]bool{"key-b": true}, &sent))
+
+	for range 3 {
+		if status := postPrompt(t, pool, "Authorization"); status != http.StatusOK {
+			t.Fatalf("status = %d, want 200", status)
+		}
+	}
+	if want := []string{"key-a", "key-b", "key-c", "key-a"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("keys sent = %q, want %q", sent, want)
+	}
+	if key := pool.keys[1]; key.usage.RateLimited != 1 || !key.coolUntil.After(time.Now()) {
+		t.Errorf("key-b: %d rate limited, cooling until %v; want 1 and cooling down", key.usage.RateLimited, key.coolUntil)
+	}
+	if requests := []int{pool.keys[0].usage.Requests, pool.keys[1].usage.Requests, pool.keys[2].usage.Requests}; !reflect.DeepEqual(requests, []int{2, 1, 1}) {
+		t.Errorf("requests per key = %v, want [2 1 1]", requests)
+	}
+}
+
+// TestKeyPoolExhausted checks that the response of the last key is returned
+// once every key is rate limited, and that the key goes in the provider's
+// own header when the request uses one.
+func TestKeyPoolExhausted(t *testing.T) {
+	var sent []string
+	limited := map[string]bool{"key-a": true, "key-b": true, "key-c": true}
+	pool := newTestPool(limitedTransport(t, "X-Api-Key", limited, &sent))
+
+	if status := postPrompt(t, pool, "X-Api-Key"); status != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", status)
+	}
+	if want := []string{"key-a", "key-b", "key-c"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("keys sent = %q, want %q", sent, want)
+	}
+}
+
+func TestKeyUsagesOrder(t *testing.T) {
+	pools, order := keyPools, keyPoolOrder
+	keyPools, keyPoolOrder = make(map[string]*keyPool), nil
+	defer func() { keyPools, keyPoolOrder = pools, order }()
+
+	for _, keys := range []string{"sk-zzzz1111,sk-zzzz2222", "sk-aaaa3333,sk-aaaa4444", "sk-mmmm5555,sk-mmmm6666"} {
+		keyPoolFor(keys, nil)
+	}
+	if keyPoolFor("sk-single", nil) != nil {
+		t.Error("a single key got a pool")
+	}
+	want := []string{"...1111", "...2222", "...3333", "...4444", "...5555", "...6666"}
+	for range 10 {
+		var got []string
+		for _, usage := range keyUsages() {
+			got = append(got, usage.Key)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("keyUsages() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, keyCooldown},
+		{"seconds", http.Header{"Retry-After": {"12"}}, 12 * time.Second},
+		{"reset", http.Header{"X-Ratelimit-Reset-Requests": {"1m30s"}}, 90 * time.Second},
+		{"date", http.Header{"Retry-After": {"Wed, 21 Oct 2026 07:28:00 GMT"}}, keyCooldown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
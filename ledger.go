@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+const ledgerFile = "ledger.jsonl"
+
+// modelPrice is the price of a model in US dollars per million tokens.
+type modelPrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// modelPrices are the list prices of common models. Dated versions such as
+// gpt-4o-2024-08-06 are priced by the longest name they start with, so
+// gpt-4o-mini isn't mistaken for gpt-4o.
+var modelPrices = map[string]modelPrice{
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.60},
+	"gpt-4o":            {Prompt: 2.50, Completion: 10.00},
+	"gpt-4.1-nano":      {Prompt: 0.10, Completion: 0.40},
+	"gpt-4.1-mini":      {Prompt: 0.40, Completion: 1.60},
+	"gpt-4.1":           {Prompt: 2.00, Completion: 8.00},
+	"gpt-4-turbo":       {Prompt: 10.00, Completion: 30.00},
+	"gpt-3.5-turbo":     {Prompt: 0.50, Completion: 1.50},
+	"o1-mini":           {Prompt: 1.10, Completion: 4.40},
+	"o1":                {Prompt: 15.00, Completion: 60.00},
+	"o3-mini":           {Prompt: 1.10, Completion: 4.40},
+	"claude-3-5-haiku":  {Prompt: 0.80, Completion: 4.00},
+	"claude-3-5-sonnet": {Prompt: 3.00, Completion: 15.00},
+	"claude-3-7-sonnet": {Prompt: 3.00, Completion: 15.00},
+	"claude-3-opus":     {Prompt: 15.00, Completion: 75.00},
+	"gemini-1.5-flash":  {Prompt: 0.075, Completion: 0.30},
+	"gemini-1.5-pro":    {Prompt: 1.25, Completion: 5.00},
+	"gemini-2.0-flash":  {Prompt: 0.10, Completion: 0.40},
+}
+
+// priceOf returns the price of model by the longest name it starts with,
+// preferring the configured prices over the built-in ones of the same name,
+// so a configured price of gpt-4o doesn't apply to gpt-4o-mini.
+func priceOf(model string, configured map[string]modelPrice) (modelPrice, bool) {
+	best, price, found := "", modelPrice{}, false
+	for _, prices := range []map[string]modelPrice{configured, modelPrices} {
+		for name, p := range prices {
+			if strings.HasPrefix(model, name) && (!found || len(name) > len(best)) {
+				best, price, found = name, p, true
+			}
+		}
+	}
+	return price, found
+}
+
+// usageCost returns the cost of the tokens used by model in US dollars, and
+// false if the price of the model is unknown. Local models cost nothing.
+func usageCost(model string, tokens tokenUsage, prices map[string]modelPrice) (float64, bool) {
+	if !summarize.NeedsAPIKey(llmProvider) {
+		return 0, true
+	}
+	price, ok := priceOf(model, prices)
+	if !ok {
+		return 0, false
+	}
+	return (float64(tokens.Prompt)*price.Prompt + float64(tokens.Completion)*price.Completion) / 1e6, true
+}
+
+// ledgerEntry is the spend of one run as stored in the cost ledger.
+type ledgerEntry struct {
+	Time     time.Time             `json:"time"`
+	Command  string                `json:"command"`
+	Provider string                `json:"provider"`
+	Models   map[string]tokenUsage `json:"models"`
+	CostUSD  float64               `json:"cost_usd"`
+}
+
+// budgetOptions holds the flags of the monthly LLM budget.
+type budgetOptions struct {
+	monthly float64
+	enforce bool
+}
+
+func registerBudgetFlags(fs *flag.FlagSet) *budgetOptions {
+	opts := &budgetOptions{}
+	fs.Float64Var(&opts.monthly, "monthly-budget", 0, "Monthly LLM budget in US dollars across all runs; a warning is logged once the spend recorded in the state directory crosses it (0 for no budget)")
+	fs.BoolVar(&opts.enforce, "enforce-budget", false, "Refuse to call the LLM once the monthly budget is used up, instead of only warning")
+	return opts
+}
+
+// ledger returns the cost ledger recording the spend of command in
+// stateDir.
+func (o *budgetOptions) ledger(stateDir string, command string, prices map[string]modelPrice) *costLedger {
+	return &costLedger{stateDir: stateDir, command: command, prices: prices, monthly: o.monthly, enforce: o.enforce}
+}
+
+// costLedger tracks the token spend of all runs in the state directory,
+// priced per model, and holds the runs to the monthly budget.
+type costLedger struct {
+	stateDir string
+	command  string
+	prices   map[string]modelPrice
+	monthly  float64
+	enforce  bool
+
+	once     sync.Once
+	checkErr error
+
+	// mu guards recorded and cost, the spend of the run once it is in the
+	// ledger.
+	mu       sync.Mutex
+	recorded bool
+	cost     float64
+}
+
+// activeLedger records the spend of the current run; it is nil in commands
+// without a state directory.
+var activeLedger *costLedger
+
+// check warns once the budget of the current month is used up, or returns an
+// error with --enforce-budget. It is called before the first LLM call.
+func (l *costLedger) check() error {
+	if l == nil || l.monthly <= 0 || (activeCassette != nil && activeCassette.replay) {
+		return nil
+	}
+	l.once.Do(func() {
+		now := time.Now()
+		spent, err := monthSpend(l.stateDir, now)
+		if err != nil {
+			log.Printf("WARNING: Failed to read the cost ledger: %v", err)
+			return
+		}
+		if spent < l.monthly {
+			return
+		}
+		if l.enforce {
+			l.checkErr = fmt.Errorf("no LLM calls are made with --enforce-budget, as the monthly budget of $%.2f is used up ($%.2f spent in %s)", l.monthly, spent, now.Format("January 2006"))
+			return
+		}
+		log.Printf("WARNING: The monthly budget of $%.2f is used up ($%.2f spent in %s)", l.monthly, spent, now.Format("January 2006"))
+	})
+	return l.checkErr
+}
+
+// record appends the spend of the run to the ledger and returns its cost.
+// Runs without LLM calls, and replayed runs, cost nothing and are not
+// recorded. A run is recorded once: later calls, such as that of fatalf
+// after the run report was finished, only return its cost.
+func (l *costLedger) record() float64 {
+	if l == nil || (activeCassette != nil && activeCassette.replay) {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.recorded {
+		return l.cost
+	}
+	usage := usageByModel()
+	if len(usage) == 0 {
+		return 0
+	}
+
+	entry := ledgerEntry{Time: time.Now(), Command: l.command, Provider: llmProvider, Models: usage}
+	var unpriced []string
+	for model, tokens := range usage {
+		cost, ok := usageCost(model, tokens, l.prices)
+		if !ok {
+			unpriced = append(unpriced, model)
+			continue
+		}
+		entry.CostUSD += cost
+	}
+	if len(unpriced) > 0 && l.monthly > 0 {
+		sort.Strings(unpriced)
+		log.Printf("WARNING: No price known for %s, so %s tokens don't count toward the budget (set model_prices in the config)", strings.Join(unpriced, ", "), worklog.Pluralize(len(unpriced), "its", "their"))
+	}
+
+	before, err := monthSpend(l.stateDir, entry.Time)
+	if err != nil {
+		log.Printf("WARNING: Failed to read the cost ledger: %v", err)
+	}
+	if err := appendLedger(l.stateDir, entry); err != nil {
+		log.Printf("WARNING: Failed to record LLM spend: %v", err)
+		return entry.CostUSD
+	}
+	l.recorded, l.cost = true, entry.CostUSD
+	after := before + entry.CostUSD
+	if entry.CostUSD > 0 {
+		log.Printf("INFO: LLM calls cost about $%.4f, $%.2f spent in %s", entry.CostUSD, after, entry.Time.Format("January 2006"))
+	}
+	if l.monthly > 0 && before < l.monthly && after >= l.monthly {
+		log.Printf("WARNING: This run crossed the monthly budget of $%.2f ($%.2f spent in %s)", l.monthly, after, entry.Time.Format("January 2006"))
+	}
+	return entry.CostUSD
+}
+
+// appendLedger adds an entry to the cost ledger in stateDir.
+func appendLedger(stateDir string, entry ledgerEntry) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode ledger entry: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(stateDir, ledgerFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return nil
+}
+
+// monthSpend sums the cost of the runs recorded in the month of now. A
+// missing ledger is not an error.
+func monthSpend(stateDir string, now time.Time) (float64, error) {
+	f, err := os.Open(filepath.Join(stateDir, ledgerFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	spent := 0.0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return 0, fmt.Errorf("failed to parse ledger: %w", err)
+		}
+		entryTime := entry.Time.In(now.Location())
+		if entryTime.Year() == now.Year() && entryTime.Month() == now.Month() {
+			spent += entry.CostUSD
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	return spent, nil
+}
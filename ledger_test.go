@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPriceOf(t *testing.T) {
+	configured := map[string]modelPrice{"gpt-4o": {Prompt: 1, Completion: 2}, "llama3": {}}
+	tests := []struct {
+		model  string
+		want   modelPrice
+		wantOK bool
+	}{
+		{"gpt-4o-mini-2024-07-18", modelPrices["gpt-4o-mini"], true},
+		{"gpt-4o-2024-08-06", configured["gpt-4o"], true},
+		{"llama3:8b", modelPrice{}, true},
+		{"mistral-large", modelPrice{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := priceOf(tt.model, configured)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("priceOf(%q) = %v, %v; want %v, %v", tt.model, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestLedgerRecordsOnce checks that the spend of a run is in the ledger
+// once, however often the run report asks for it, and counts toward the
+// month of the run only.
+func TestLedgerRecordsOnce(t *testing.T) {
+	usage := modelUsage
+	modelUsage = map[string]tokenUsage{"gpt-4o": {Prompt: 200_000, Completion: 50_000, Total: 250_000}}
+	defer func() { modelUsage = usage }()
+
+	stateDir := t.TempDir()
+	now := time.Now()
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 12, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+	if err := appendLedger(stateDir, ledgerEntry{Time: lastMonth, Command: "run", CostUSD: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := (&budgetOptions{monthly: 2}).ledger(stateDir, "run", nil)
+	if err := ledger.check(); err != nil {
+		t.Fatalf("check() before the budget is used up = %v", err)
+	}
+	// 0.2M prompt tokens at $2.50 and 0.05M completion tokens at $10.
+	const cost = 1.0
+	for range 3 {
+		if got := ledger.record(); math.Abs(got-cost) > 1e-9 {
+			t.Fatalf("record() = %v, want %v", got, cost)
+		}
+	}
+	spent, err := monthSpend(stateDir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(spent-cost) > 1e-9 {
+		t.Errorf("spent this month = %v, want %v", spent, cost)
+	}
+
+	if err := appendLedger(stateDir, ledgerEntry{Time: now, Command: "backfill", CostUSD: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	enforced := (&budgetOptions{monthly: 2, enforce: true}).ledger(stateDir, "run", nil)
+	if err := enforced.check(); err == nil {
+		t.Error("check() with --enforce-budget allowed a run over the budget")
+	}
+}
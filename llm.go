@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// resolveAPIKey returns the key passed on the command line, falling back to
+// the provider's environment variable, such as OPENAI_API_KEY. Either can be
+// a comma-separated list of keys to rotate across. Replaying recorded
+// responses and local providers need no key. It fails once the monthly
+// budget is used up with --enforce-budget, as every LLM call needs a key.
+func resolveAPIKey(apiKey string) (string, error) {
+	if err := activeLedger.check(); err != nil {
+		return "", err
+	}
+	if llmProvider == summarize.ProviderAzure && llmEndpoint() == "" {
+		return "", fmt.Errorf("--provider azure needs the endpoint of the resource as --base-url or in AZURE_OPENAI_ENDPOINT")
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv(summarize.APIKeyEnv(llmProvider))
+	}
+	if apiKey == "" {
+		secret, err := configSecrets.lookup(llmProvider)
+		if err != nil {
+			return "", err
+		}
+		apiKey = secret
+	}
+	if apiKey == "" && activeCassette != nil && activeCassette.replay {
+		apiKey = "replay"
+	}
+	if apiKey == "" && !summarize.NeedsAPIKey(llmProvider) {
+		apiKey = llmProvider
+	}
+	if apiKey == "" && llmProvider == summarize.ProviderAzure && llmAzure.AD {
+		token, err := azureADToken()
+		if err != nil {
+			return "", err
+		}
+		apiKey = token
+	}
+	if apiKey == "" {
+		return "", fmt.Errorf("no %s API key provided", llmProvider)
+	}
+	return apiKey, nil
+}
+
+var (
+	// llmProvider is the model provider of all LLM clients of the process,
+	// set by --provider.
+	llmProvider = summarize.ProviderOpenAI
+	// llmBaseURL replaces the provider's API endpoint, set by --base-url.
+	llmBaseURL string
+	// llmTemperature is the sampling temperature of all LLM calls, set by
+	// --temperature; nil leaves it to the provider.
+	llmTemperature *float32
+	// llmAzure sets up the azure provider, from the --azure-* flags and the
+	// azure_deployments setting.
+	llmAzure summarize.AzureConfig
+)
+
+// registerProviderFlags adds the --provider, --temperature, --base-url, and
+// --azure-* flags to fs.
+func registerProviderFlags(fs *flag.FlagSet) {
+	fs.Func("provider", "LLM provider: openai (default), anthropic, gemini, azure, ollama for local models, or mock to call no model", func(value string) error {
+		if !slices.Contains(summarize.Providers, value) {
+			return fmt.Errorf("unsupported provider '%s' (expected %s)", value, strings.Join(summarize.Providers, ", "))
+		}
+		llmProvider = value
+		return nil
+	})
+	fs.Func("temperature", "Sampling temperature of all LLM calls, from 0 for the most predictable wording to 2 (default: the provider's)", func(value string) error {
+		temperature, err := strconv.ParseFloat(value, 32)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return fmt.Errorf("invalid temperature '%s' (expected a number from 0 to 2)", value)
+		}
+		llmTemperature = new(float32)
+		*llmTemperature = float32(temperature)
+		return nil
+	})
+	fs.StringVar(&llmBaseURL, "base-url", "", "Base URL of the LLM API, e.g. http://localhost:8080/v1 for an OpenAI-compatible server, or the resource endpoint for azure (default: the provider's; ollama: http://localhost:11434/v1; azure: AZURE_OPENAI_ENDPOINT env var)")
+	fs.StringVar(&llmAzure.Deployment, "azure-deployment", "", "Azure OpenAI deployment serving the models not mapped in azure_deployments (default: the model name)")
+	fs.StringVar(&llmAzure.APIVersion, "azure-api-version", summarize.DefaultAzureAPIVersion, "Azure OpenAI API version")
+	fs.Func("azure-auth", "Azure OpenAI authentication: key (default), or aad for a Microsoft Entra ID token as --api-key, from AZURE_OPENAI_AD_TOKEN, or from the Azure CLI", func(value string) error {
+		switch value {
+		case "key", "aad":
+			llmAzure.AD = value == "aad"
+			return nil
+		}
+		return fmt.Errorf("unsupported authentication '%s' (expected key or aad)", value)
+	})
+}
+
+// llmEndpoint returns the base URL of the LLM API, falling back to the
+// AZURE_OPENAI_ENDPOINT environment variable for azure.
+func llmEndpoint() string {
+	if llmBaseURL == "" && llmProvider == summarize.ProviderAzure {
+		return os.Getenv("AZURE_OPENAI_ENDPOINT")
+	}
+	return llmBaseURL
+}
+
+// azureCognitiveServicesScope is the resource Entra ID tokens for Azure
+// OpenAI are issued for.
+const azureCognitiveServicesScope = "https://cognitiveservices.azure.com"
+
+// azureADToken returns an Entra ID access token for Azure OpenAI from the
+// AZURE_OPENAI_AD_TOKEN environment variable or, failing that, the Azure CLI
+// of the signed-in user.
+func azureADToken() (string, error) {
+	if token := os.Getenv("AZURE_OPENAI_AD_TOKEN"); token != "" {
+		return token, nil
+	}
+	out, err := exec.Command("az", "account", "get-access-token", "--resource", azureCognitiveServicesScope, "--query", "accessToken", "--output", "tsv").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get an Entra ID token from the Azure CLI (sign in with az login, or set AZURE_OPENAI_AD_TOKEN): %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// llmLimiter is shared by all LLM calls of the process.
+var llmLimiter *summarize.RateLimiter
+
+// newLLMClient creates a client that goes through the active cassette, if
+// any, rotates across the keys of a key list, shares the process-wide rate
+// limit, and records its token usage and prompts.
+func newLLMClient(apiKey string) *summarize.Client {
+	var transport http.RoundTripper
+	if activeCassette != nil {
+		transport = activeCassette
+	}
+	if pool := keyPoolFor(apiKey, transport); pool != nil {
+		transport = pool
+	}
+	var httpClient *http.Client
+	if transport != nil {
+		httpClient = &http.Client{Transport: transport}
+	}
+	client, err := summarize.NewProviderClient(summarize.Config{
+		Provider:    llmProvider,
+		APIKey:      apiKey,
+		BaseURL:     llmEndpoint(),
+		HTTPClient:  httpClient,
+		Temperature: llmTemperature,
+		Azure:       llmAzure,
+	})
+	if err != nil {
+		// The provider and its endpoint are checked by resolveAPIKey.
+		log.Fatalf("ERROR: %v", err)
+	}
+	client.Limiter = llmLimiter
+	client.OnUsage = recordUsage
+	client.OnPrompt = activeTrace.prompt
+	return client
+}
+
+// normalizeItemLanguage translates the items of categories written in another
+// language than language, recording each translation in the trace. The items
+// of raw categories are left as they are and never sent to the LLM. The
+// translated titles are applied to both categories and items.
+func normalizeItemLanguage(client *summarize.Client, model string, categories map[string][]worklog.Item, items []worklog.Item, language string, raw func(string) bool) error {
+	var pending []worklog.Item
+	for _, category := range worklog.OrderedCategories(categories, nil) {
+		if !raw(category) {
+			pending = append(pending, categories[category]...)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	translated, translations, err := summarize.NormalizeLanguage(context.Background(), client, model, pending, language)
+	if err != nil {
+		return fmt.Errorf("failed to translate items: %w", err)
+	}
+	for _, translation := range translations {
+		activeTrace.enrich("translation from "+translation.Language, translation.Before, translation.After)
+	}
+	if len(translations) > 0 {
+		log.Printf("INFO: Translated %d %s to %s", len(translations), worklog.Pluralize(len(translations), "item", "items"), language)
+	}
+
+	titles := make(map[string]string, len(translated))
+	for _, item := range translated {
+		titles[item.ID] = item.Title
+	}
+	retitle := func(items []worklog.Item) {
+		for i := range items {
+			if title, ok := titles[items[i].ID]; ok {
+				items[i].Title = title
+			}
+		}
+	}
+	for category, categoryItems := range categories {
+		if !raw(category) {
+			retitle(categoryItems)
+		}
+	}
+	retitle(items)
+	return nil
+}
+
+// loadContextFile reads the optional context file; an empty path yields no
+// context.
+func loadContextFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read context file: %w", err)
+	}
+	return string(data), nil
+}
+
+// tokenUsage counts the tokens of all LLM calls made by the process.
+type tokenUsage struct {
+	Prompt     int `json:"prompt"`
+	Completion int `json:"completion"`
+	Total      int `json:"total"`
+}
+
+func (u *tokenUsage) add(usage openai.Usage) {
+	u.Prompt += usage.PromptTokens
+	u.Completion += usage.CompletionTokens
+	u.Total += usage.TotalTokens
+}
+
+var (
+	usageMu      sync.Mutex
+	sessionUsage tokenUsage
+	// modelUsage breaks the session usage down by model, for pricing.
+	modelUsage = make(map[string]tokenUsage)
+)
+
+func recordUsage(model string, usage openai.Usage) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	sessionUsage.add(usage)
+	if usage.TotalTokens > 0 {
+		perModel := modelUsage[model]
+		perModel.add(usage)
+		modelUsage[model] = perModel
+	}
+}
+
+// totalUsage returns the tokens used so far.
+func totalUsage() tokenUsage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return sessionUsage
+}
+
+// usageByModel returns the tokens used so far by each model.
+func usageByModel() map[string]tokenUsage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return maps.Clone(modelUsage)
+}
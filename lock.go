@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockFile guards the state directory, so a manual run and a scheduled run
+// can't both write the state, modify the board, or deliver at the same time.
+const lockFile = "run.lock"
+
+// runLock is the content of the lock file, describing the run holding it.
+type runLock struct {
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// acquireLock takes the lock of stateDir and returns a function releasing
+// it. If another run holds the lock, it fails unless force is set, which
+// takes over a lock left behind by a run that crashed or was killed.
+func acquireLock(stateDir string, command string, force bool) (func(), error) {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	path := filepath.Join(stateDir, lockFile)
+
+	if force {
+		if holder, err := readLock(path); err == nil {
+			log.Printf("WARNING: Taking over the lock of %s (pid %d, started %s)", holder.Command, holder.PID, holder.StartedAt.Format(time.RFC3339))
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove lock file: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		holder, readErr := readLock(path)
+		if readErr != nil {
+			return nil, fmt.Errorf("another run holds the lock %s; if none is running, rerun with --force", path)
+		}
+		return nil, fmt.Errorf("another run (%s, pid %d, started %s) holds the lock %s; if it is no longer running, rerun with --force",
+			holder.Command, holder.PID, holder.StartedAt.Format(time.RFC3339), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+
+	lock := runLock{PID: os.Getpid(), Command: command, StartedAt: time.Now()}
+	err = json.NewEncoder(f).Encode(lock)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return func() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARNING: Failed to release lock %s: %v", path, err)
+		}
+	}, nil
+}
+
+// readLock reads the lock file at path.
+func readLock(path string) (runLock, error) {
+	var lock runLock
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lock, err
+	}
+	err = json.Unmarshal([]byte(strings.TrimSpace(string(data))), &lock)
+	return lock, err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAcquireLock checks that a second run is refused while the first holds
+// the lock, can take over with force, and that a released lock is free.
+func TestAcquireLock(t *testing.T) {
+	stateDir := filepath.Join(t.TempDir(), "state")
+
+	release, err := acquireLock(stateDir, "run", false)
+	if err != nil {
+		t.Fatalf("acquireLock: %v", err)
+	}
+	holder, err := readLock(filepath.Join(stateDir, lockFile))
+	if err != nil || holder.PID != os.Getpid() || holder.Command != "run" {
+		t.Errorf("lock file = %+v, %v; want this run", holder, err)
+	}
+
+	if _, err := acquireLock(stateDir, "publish", false); err == nil || !strings.Contains(err.Error(), "another run (run, pid ") {
+		t.Errorf("second acquireLock() error = %v, want the holder named", err)
+	}
+
+	takeover, err := acquireLock(stateDir, "flush", true)
+	if err != nil {
+		t.Fatalf("acquireLock with force: %v", err)
+	}
+	if holder, _ := readLock(filepath.Join(stateDir, lockFile)); holder.Command != "flush" {
+		t.Errorf("lock held by %q after the takeover, want flush", holder.Command)
+	}
+	takeover()
+	// Releasing a lock taken over by another run leaves nothing to remove.
+	release()
+
+	if _, err := os.Stat(filepath.Join(stateDir, lockFile)); !os.IsNotExist(err) {
+		t.Errorf("lock file after release: %v", err)
+	}
+	again, err := acquireLock(stateDir, "run", false)
+	if err != nil {
+		t.Fatalf("acquireLock after release: %v", err)
+	}
+	again()
+}
+
+func TestAcquireLockUnreadable(t *testing.T) {
+	stateDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(stateDir, lockFile), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acquireLock(stateDir, "run", false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("acquireLock() error = %v, want a hint to use --force", err)
+	}
+}
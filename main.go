@@ -2,395 +2,844 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
-	"github.com/sashabaranov/go-openai"
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/text"
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
 )
 
-func extractColumnItems(content string, columnName string) ([]string, error) {
-	parser := goldmark.DefaultParser()
-	reader := text.NewReader([]byte(content))
-	doc := parser.Parse(reader)
-
-	var items []string
+func saveWorklog(outputFolder string, year int, week int, extension string, content string) (string, error) {
+	err := os.MkdirAll(outputFolder, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output folder: %w", err)
+	}
 
-	var foundTargetHeading bool
-	var currentHeadingLevel int
+	filename, err := writeFileSafely(worklogFilename(outputFolder, year, week, extension), []byte(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to write worklog file: %w", err)
+	}
 
-	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
-		if !entering {
-			return ast.WalkContinue, nil
-		}
+	log.Printf("INFO: Saved worklog to %s", filename)
+	return filename, nil
+}
 
-		switch node := n.(type) {
-		case *ast.Heading:
-			headingLevel := node.Level
+var subcommands = map[string]func(args []string) error{
+	"backfill":  runBackfill,
+	"config":    runConfig,
+	"eval":      runEval,
+	"flush":     runFlush,
+	"publish":   runPublish,
+	"remind":    runRemind,
+	"site":      runSite,
+	"templates": runTemplates,
+	"timeline":  runTimeline,
+	"translate": runTranslate,
+	"undo":      runUndo,
+}
 
-			if foundTargetHeading && headingLevel <= currentHeadingLevel {
-				return ast.WalkStop, nil
-			}
+// noLLMOff turns off a flag that needs the LLM when running with --no-llm,
+// warning if it was set.
+func noLLMOff(set bool, name string) bool {
+	if set {
+		log.Printf("WARNING: Ignoring %s, since --no-llm never calls the LLM", name)
+	}
+	return false
+}
 
-			if headingLevel == 2 {
-				headingText := string(node.Text([]byte(content)))
-				if strings.TrimSpace(headingText) == columnName {
-					foundTargetHeading = true
-					currentHeadingLevel = headingLevel
-				}
-			}
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	log.SetPrefix("WORKLOG-GEN: ")
 
-		case *ast.ListItem:
-			if foundTargetHeading {
-				listItemText := string(node.Text([]byte(content)))
-
-				if strings.Contains(listItemText, "[") && strings.Contains(listItemText, "]") {
-					checkboxIndex := strings.Index(listItemText, "]")
-					if checkboxIndex >= 0 && checkboxIndex+1 < len(listItemText) {
-						itemText := strings.Join(strings.Fields(listItemText[checkboxIndex+1:]), " ")
-						if itemText != "" {
-							items = append(items, itemText)
-						}
-					}
-				}
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			if err := subcommand(os.Args[2:]); err != nil {
+				log.Fatalf("ERROR: %v", err)
 			}
+			return
 		}
+	}
+
+	boardPath := flag.String("board", "", "Path to the Kanban board markdown file")
+	boardGitRef := flag.String("board-git-ref", "", "Read the board as of this git revision of its repository, e.g. HEAD@{1 week ago}")
+	var columns listFlag
+	flag.Var(&columns, "column", "Column to summarize; repeat it or give a comma-separated list for a worklog with a section per column")
+	outputFolder := flag.String("output-folder", "", "Folder to write the summary")
+	// Worklogs cover ISO weeks, whose year differs from the calendar year
+	// around New Year.
+	isoYear, isoWeek := time.Now().ISOWeek()
+	week := flag.Int("week", isoWeek, "ISO week to generate the worklog for, e.g. to catch up on a past week")
+	year := flag.Int("year", isoYear, "ISO year of the week to generate")
+	period := flag.String("period", "week", "Period to write: week, or month or quarter to roll up the weekly worklogs in the output folder into one summary note (requires --ai-assisted)")
+	apiKey := flag.String("api-key", "", "API key of the LLM provider, or a comma-separated list of keys to rotate across (can also be set via OPENAI_API_KEY, ANTHROPIC_API_KEY, or GEMINI_API_KEY env var)")
+	registerProviderFlags(flag.CommandLine)
+	aiAssisted := flag.Bool("ai-assisted", false, "Use AI to generate summaries (requires an API key)")
+	noLLM := flag.Bool("no-llm", false, "Never call the LLM: list the cleaned card titles by category instead of summaries and skip the steps that need it, e.g. when the API is down or the budget is used up")
+	format := flag.String("format", "md", "Output format: md, rst, adoc, text, or slack")
+	outputTemplate := flag.String("output-template", "", "Path to a text/template file laying out the whole worklog instead of the built-in layout (same fields as --webhook-template)")
+	contextPath := flag.String("context", "", "Path to a markdown file (team charter, project descriptions, glossary) injected into every AI prompt")
+	notesPath := flag.String("notes", "", "Path to a markdown file merged into the worklog's Notes section")
+	sortOrder := flag.String("sort", "board", "Order of bullets within a category: board (as returned) or importance (priority, severity, and time spent)")
+	icsExport := flag.Bool("ics", false, "Also write completed items with a completion date as calendar events to an .ics file")
+	feed := flag.Bool("feed", false, "Maintain an atom.xml feed of the weekly worklogs in the output folder")
+	feedBaseURL := flag.String("feed-base-url", "", "URL under which the output folder is published, used for feed links")
+	feedAuthor := flag.String("feed-author", os.Getenv("USER"), "Author name of the feed")
+	appendTo := flag.String("append-to", "", "Markdown note to add the week to, e.g. a rolling Worklog.md, instead of writing a worklog file of its own; a week already in the note is replaced")
+	appendHeading := flag.String("append-heading", "", "Heading of the --append-to note to add weeks below (default: the top of the note); it is added if missing")
+	draft := flag.Bool("draft", false, "Only write the local file; deliver to sinks later with the publish subcommand")
+	weekOnly := flag.Bool("completed-in-week", true, "Only include cards whose completion date (@{2024-05-03} or ✅ 2024-05-03) falls in the worklog's week; cards without one are kept")
+	skipUndated := flag.Bool("skip-undated", false, "With --completed-in-week, also leave out cards without a completion date")
+	maxItems := flag.Int("max-items", 0, "Cap the worklog at this many items, keeping those completed in the week first, then those with the highest priority, severity, or time spent (0: no cap)")
+	patterns := flag.Bool("patterns", false, "Add a patterns appendix with the days and times of day items were completed")
+	overloadWarnings := flag.Bool("overload-warnings", false, "Warn when the week's items, late-night completions, or incidents are well above your average in the run history")
+	overloadNoteFlag := flag.Bool("overload-note", false, "Like --overload-warnings, and also add a gentle note to a self-review section of the worklog")
+	dryRun := flag.Bool("dry-run", false, "Print the extracted items by category, and with --ai-assisted the summary prompts, without calling the LLM or writing any files")
+	sandbox := flag.Bool("sandbox", false, "Only print the worklog, summarized by the mock provider with --ai-assisted: no file is written, the board isn't modified, nothing is delivered, and no model is called, e.g. for demos on real data")
+	explain := flag.Bool("explain", false, "Also write a trace of the run's decisions (included and excluded items, categories, prompts, and where each bullet came from) next to the worklog")
+	usageReport := registerUsageReportFlag(flag.CommandLine)
+	explainCategorization := flag.Bool("explain-categorization", false, "Log which categorization strategy and rule decided each item's category")
+	compareLastWeek := flag.Bool("compare-last-week", false, "Add commentary comparing the week's categories to the previous week in the run history (requires --ai-assisted)")
+	digest := flag.Int("digest", 0, "Also write an ultra-short version of the worklog with this many sentences in total (requires --ai-assisted)")
+	plainLanguage := flag.Bool("plain-language", false, "Add a jargon-free summary for non-engineering stakeholders to every section (requires --ai-assisted)")
+	dualAudience := flag.Bool("dual-audience", false, "Add a separate stakeholder section with a jargon-free summary of every category before the technical sections (requires --ai-assisted)")
+	citations := flag.Bool("citations", false, "Add footnotes after each bullet citing the board cards it was written from, linking to the cards when the board is in a vault")
+	blockIDs := flag.Bool("block-ids", false, "Append an Obsidian block ID (^abc123) to every card in the columns that has none, so links to cards, such as those of --citations, stay stable")
+	sourceBadges := flag.Bool("source-badges", false, "Mark raw items with a badge for their source (📋 board, 🔀 git, 📅 calendar, 🎙️ voice memo, 📷 board photo, 🌐 browser history)")
+	gitCommit := flag.Bool("git-commit", false, "Commit the generated files in the git repository of the output folder")
+	gitCommitMessage := flag.String("git-commit-message", defaultCommitMessage, "text/template for the commit message (fields: .Year, .Week, .Items, .Files)")
+	gitPush := flag.Bool("git-push", false, "Push after committing with --git-commit")
+	quiet := flag.Bool("quiet", false, "Only print a one-line result; warnings and errors still go to stderr")
+	jsonResult := flag.Bool("json", false, "Like --quiet, but print the result as JSON")
+	model := flag.String("model", "", "Model used for summaries (default "+summarize.DefaultModel+")")
+	maxTokens := flag.Int("max-tokens", summarize.DefaultMaxTokens, "Maximum length of each category summary in tokens; raise it if busy weeks get cut off")
+	bulletWords := flag.Int("bullet-words", 0, "Maximum number of words per key point; longer summaries are asked for again (default: no limit)")
+	itemsPerBullet := flag.Int("items-per-bullet", 0, "Maximum number of items each key point may cover; more compressed summaries are asked for again (default: no limit)")
+	concurrency := flag.Int("concurrency", 4, "Number of categories summarized at the same time")
+	refreshSummaries := flag.Bool("refresh-summaries", false, "Summarize every category again instead of reusing the cached summaries of categories whose items didn't change")
+	batch := flag.Bool("batch", false, "Summarize all categories in a single LLM request per model instead of one per category")
+	language := flag.String("language", "", "Language of the worklog, as a code (en, de) or name; items in other languages are translated to it before summarizing")
+	promptPath := flag.String("prompt", "", "Path to a text/template file replacing the summary prompt (fields: .Category, .Items)")
+	configPath := flag.String("config", "", "Path to a YAML config file (default: worklog.yaml in the vault root, the Obsidian plugin's settings in the vault, or ~/.config/worklog-gen/config.yaml)")
+	vault := flag.String("vault", "", "Obsidian vault whose worklog-gen plugin settings to use (default: the vault containing the board)")
+	stateDir := flag.String("state-dir", defaultStateDir(), "Directory for the run history and other state")
+	force := flag.Bool("force", false, "Run even if the state directory is locked by another run, e.g. one that crashed")
+	sinkOpts := registerSinkFlags(flag.CommandLine)
+	recordingOpts := registerRecordingFlags(flag.CommandLine)
+	budgetOpts := registerBudgetFlags(flag.CommandLine)
+	alertOpts := registerAlertFlags(flag.CommandLine)
+	sourceOpts := registerSourceFlags(flag.CommandLine)
+	enricherOpts := registerEnricherFlags(flag.CommandLine)
+	profileOpts := registerProfileFlags(flag.CommandLine)
 
-		return ast.WalkContinue, nil
-	})
+	flag.Parse()
 
+	if *sandbox && (profileOpts.cpu != "" || profileOpts.memory != "") {
+		log.Fatalf("ERROR: The profiling flags can't be combined with --sandbox, since they write files")
+	}
+	stopProfiling, err := profileOpts.start()
 	if err != nil {
-		return nil, err
+		log.Fatalf("ERROR: %v", err)
 	}
+	defer stopProfiling()
 
-	if !foundTargetHeading {
-		return nil, fmt.Errorf("column '%s' not found", columnName)
+	cfg, err := loadConfig(resolveConfigPath(*configPath, *vault, filepath.Dir(*boardPath)))
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
 	}
+	if err := cfg.applyFlags(flag.CommandLine, true); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	llmAzure.Deployments = cfg.AzureDeployments
+	configSecrets = secretStore{values: cfg.Secrets, stateDir: *stateDir}
+	activeLedger = budgetOpts.ledger(*stateDir, "worklog-gen", cfg.ModelPrices)
 
-	return items, nil
-}
+	if *quiet || *jsonResult {
+		log.SetOutput(quietWriter{os.Stderr})
+	}
 
-func categorizeByTags(titles []string) map[string][]string {
-	categories := map[string][]string{
-		"features":        {},
-		"bugs":            {},
-		"planning/design": {},
-		"documentation":   {},
-		"reviews":         {},
-		"meetings":        {},
-		"learning":        {},
-		"other":           {},
-	}
-
-	for _, title := range titles {
-		// Extract all hashtags from the title
-		words := strings.Fields(title)
-		var tags []string
-		for _, word := range words {
-			if strings.HasPrefix(word, "#") {
-				tags = append(tags, strings.ToLower(strings.TrimPrefix(word, "#")))
-			}
+	// A sandbox run reads the board and the state directory but never
+	// writes, delivers, or calls a model, whatever else is configured.
+	if *sandbox {
+		if *period != "week" {
+			log.Fatalf("ERROR: --period %s can't be combined with --sandbox, since it writes the rollup", *period)
 		}
-
-		// If no tags found, put in other category
-		if len(tags) == 0 {
-			categories["other"] = append(categories["other"], title)
-			continue
-		}
-
-		// Use the first matching tag to categorize
-		categorized := false
-		for _, tag := range tags {
-			switch tag {
-			case "build", "feat", "feature":
-				categories["features"] = append(categories["features"], title)
-				categorized = true
-			case "bug":
-				categories["bugs"] = append(categories["bugs"], title)
-				categorized = true
-			case "plan", "design":
-				categories["planning/design"] = append(categories["planning/design"], title)
-				categorized = true
-			case "doc", "docs":
-				categories["documentation"] = append(categories["documentation"], title)
-				categorized = true
-			case "review":
-				categories["reviews"] = append(categories["reviews"], title)
-				categorized = true
-			case "meet", "meeting":
-				categories["meetings"] = append(categories["meetings"], title)
-				categorized = true
-			case "learn":
-				categories["learning"] = append(categories["learning"], title)
-				categorized = true
-			}
-			if categorized {
-				break
-			}
+		if recordingOpts.record != "" {
+			log.Fatalf("ERROR: --record can't be combined with --sandbox, since it writes the responses")
 		}
+		llmProvider = summarize.ProviderMock
+		activeLedger = nil
+	}
+	// Dry runs and sandbox runs write nothing.
+	readOnly := *dryRun || *sandbox
+	if !readOnly {
+		cfg.migration.save()
+	}
+	// Steps whose answers the mock provider can't fake are skipped, like
+	// all steps calling the LLM with --no-llm.
+	skipLLMSteps := *dryRun || *noLLM || *sandbox
 
-		if !categorized {
-			categories["other"] = append(categories["other"], title)
+	if *noLLM {
+		if *period != "week" {
+			log.Fatalf("ERROR: --period %s can't be combined with --no-llm, since rolling up calls the LLM", *period)
+		}
+		*aiAssisted = noLLMOff(*aiAssisted, "--ai-assisted")
+		*plainLanguage = noLLMOff(*plainLanguage, "--plain-language")
+		*dualAudience = noLLMOff(*dualAudience, "--dual-audience")
+		*compareLastWeek = noLLMOff(*compareLastWeek, "--compare-last-week")
+		if *digest > 0 {
+			log.Println("WARNING: Ignoring --digest, since --no-llm never calls the LLM")
+			*digest = 0
 		}
 	}
 
-	return categories
-}
+	if *period != "week" {
+		if *outputFolder == "" {
+			log.Println("ERROR: output-folder flag is required")
+			flag.Usage()
+			os.Exit(1)
+		}
+		if !worklog.ValidWeek(*year, *week) {
+			log.Fatalf("ERROR: %d has no week %d", *year, *week)
+		}
+		rollupPeriod, err := worklog.PeriodOf(*period, *year, *week)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !*aiAssisted {
+			log.Fatalf("ERROR: --period %s requires --ai-assisted", *period)
+		}
+		if *appendTo != "" {
+			log.Fatalf("ERROR: --period %s rolls up the worklog files of the weeks and can't be combined with --append-to", *period)
+		}
+		if err := recordingOpts.apply(); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		key, err := resolveAPIKey(*apiKey)
+		if err != nil {
+			log.Fatalf("ERROR: %v, required for AI-assisted mode", err)
+		}
+		background, err := loadContextFile(*contextPath)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		renderer, err := output.LookupRenderer(*format)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
 
-func summarizeByCategory(categories map[string][]string, apiKey string, aiAssisted bool) (map[string][]string, error) {
-	result := make(map[string][]string)
+		rollupOpts := summarize.Options{Client: newLLMClient(key), AIAssisted: true, Model: *model, Context: background, Language: *language, RawCategories: cfg.RawCategories}
+		rollupPath, err := writeRollup(*outputFolder, *stateDir, renderer, rollupPeriod, rollupOpts)
+		activeLedger.record()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		log.Printf("SUCCESS: Rolled up %s to %s", rollupPeriod.Name, rollupPath)
+		return
+	}
 
-	if !aiAssisted {
-		for category, titles := range categories {
-			if len(titles) == 0 {
-				continue
-			}
+	if *boardPath == "" || len(columns) == 0 || *outputFolder == "" {
+		log.Println("ERROR: board, column, and output-folder flags are required")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if !worklog.ValidWeek(*year, *week) {
+		log.Fatalf("ERROR: %d has no week %d", *year, *week)
+	}
 
-			result[category] = titles
+	// A read-only run doesn't need the lock and can run next to a real one.
+	release := func() {}
+	if !readOnly {
+		release, err = acquireLock(*stateDir, "worklog-gen", *force)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		activeBackup = newRunBackup(*stateDir, "worklog-gen")
+	}
+	defer release()
+
+	runReport := newRunReport()
+	runReport.Inputs.Board = *boardPath
+	runReport.Inputs.Column = columns.String()
+	runReport.Inputs.OutputFolder = *outputFolder
+	runReport.Inputs.Format = *format
+	runReport.Inputs.AIAssisted = *aiAssisted
+	runReport.Inputs.Draft = *draft
+
+	// fatalf ends a failed run, recording the error in the run report and
+	// sending the configured alerts, unless the run is read-only.
+	fatalf := func(format string, args ...any) {
+		err := fmt.Errorf(format, args...)
+		runReport.finish(err)
+		if !readOnly {
+			if reportErr := writeRunReport(*stateDir, runReport); reportErr != nil {
+				log.Printf("WARNING: %v", reportErr)
+			}
+			if alertErr := alertOpts.notify(context.Background(), runReport, err); alertErr != nil {
+				log.Printf("WARNING: Failed to send failure alert: %v", alertErr)
+			}
 		}
-		return result, nil
+		release()
+		stopProfiling()
+		log.Fatalf("ERROR: %v", err)
 	}
 
-	if apiKey == "" {
-		return nil, fmt.Errorf("OpenAI API key is required for AI-assisted summarization")
+	if err := recordingOpts.apply(); err != nil {
+		fatalf("%v", err)
+	}
+	if *explain {
+		activeTrace = &explainTrace{}
 	}
 
-	client := openai.NewClient(apiKey)
-	ctx := context.Background()
+	outputRenderer, err := output.LookupRenderer(*format)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *outputTemplate != "" {
+		if outputRenderer, err = templatedRenderer(*outputTemplate, outputRenderer); err != nil {
+			fatalf("%v", err)
+		}
+	}
 
-	for category, titles := range categories {
-		if len(titles) == 0 {
-			continue
+	if *appendTo != "" {
+		if outputRenderer.Format != "md" {
+			fatalf("--append-to adds the week to a markdown note and requires --format md")
+		}
+		if *draft {
+			fatalf("--append-to can't be combined with --draft, since publish delivers a worklog file of its own")
 		}
+		if *citations {
+			fatalf("--append-to can't be combined with --citations, since the footnotes of the weeks in the note would share their labels")
+		}
+		if *feed {
+			fatalf("--append-to can't be combined with --feed, since the feed lists the worklog files of the weeks")
+		}
+	}
 
-		itemsList := strings.Join(titles, "\n- ")
-		prompt := fmt.Sprintf(`As an expert software engineer with strong communication skills, write a concise technical summary of the following items in the '%s' category. 
-Focus on technical impact, architectural decisions, and engineering outcomes. Write in a clear, professional tone suitable for team communication or management updates.
-Keep it brief but informative, highlighting key technical achievements and challenges.
+	if (*plainLanguage || *dualAudience) && !*aiAssisted {
+		fatalf("The plain-language and dual-audience flags require --ai-assisted")
+	}
 
-Items to summarize:
-%s
+	if *maxItems < 0 {
+		fatalf("--max-items must be a positive number of items")
+	}
+	if *maxTokens <= 0 {
+		fatalf("--max-tokens must be a positive number of tokens")
+	}
+	if *bulletWords < 0 || *itemsPerBullet < 0 {
+		fatalf("--bullet-words and --items-per-bullet must not be negative")
+	}
+	if *digest < 0 {
+		fatalf("--digest must be a positive number of sentences")
+	}
+	if *digest > 0 && !*aiAssisted {
+		fatalf("--digest requires --ai-assisted")
+	}
+	if *compareLastWeek && !*aiAssisted {
+		fatalf("--compare-last-week requires --ai-assisted")
+	}
 
-Format your response as a brief technical summary paragraph, followed by key bullet points if needed.`, category, itemsList)
+	if *sortOrder != "board" && *sortOrder != "importance" {
+		fatalf("Unsupported sort order '%s' (expected board or importance)", *sortOrder)
+	}
 
-		resp, err := client.CreateChatCompletion(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model: "gpt-4o-mini",
-				Messages: []openai.ChatCompletionMessage{
-					{
-						Role:    openai.ChatMessageRoleUser,
-						Content: prompt,
-					},
-				},
-				MaxTokens: 500,
-			},
-		)
+	sinkOpts.copies = cfg.Copies
+	sinkOpts.modelPrices = cfg.ModelPrices
+	sinks, err := sinkOpts.build()
+	if err != nil {
+		fatalf("%v", err)
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("error calling OpenAI API for category '%s': %w", category, err)
+	if *boardGitRef == "" {
+		_, err = os.Stat(*boardPath)
+		if os.IsNotExist(err) {
+			fatalf("Board file '%s' does not exist", *boardPath)
 		}
+	}
 
-		if len(resp.Choices) == 0 {
-			return nil, fmt.Errorf("no response from OpenAI API for category '%s'", category)
+	extractStart := time.Now()
+	if *boardGitRef != "" {
+		log.Printf("INFO: Reading board file: %s at %s", *boardPath, *boardGitRef)
+	} else {
+		log.Printf("INFO: Reading board file: %s", *boardPath)
+	}
+	boardMarkdown, err := readBoard(*boardPath, *boardGitRef)
+	if err != nil {
+		fatalf("Failed to read board file: %v", err)
+	}
+	if *blockIDs {
+		if *boardGitRef != "" {
+			fatalf("--block-ids can't be combined with --board-git-ref")
 		}
-
-		responseText := resp.Choices[0].Message.Content
-		bullets := extractBulletPoints(responseText)
-
-		if len(bullets) == 0 {
-			log.Printf("WARNING: Empty summary received for category '%s'", category)
+		if *dryRun {
+			fatalf("--block-ids can't be combined with --dry-run, since it writes to the board")
+		}
+		if *sandbox {
+			fatalf("--block-ids can't be combined with --sandbox, since it writes to the board")
 		}
+		if boardMarkdown, err = addBlockIDs(*boardPath, boardMarkdown, columns); err != nil {
+			fatalf("Failed to add block IDs: %v", err)
+		}
+	}
 
-		result[category] = bullets
+	columnLabel := fmt.Sprintf("%s '%s'", worklog.Pluralize(len(columns), "column", "columns"), strings.Join(columns, "', '"))
+	log.Printf("INFO: Extracting items from %s", columnLabel)
+	var excluded func(text string, reason string)
+	if activeTrace != nil {
+		excluded = activeTrace.exclude
+	}
+	items, cached, err := extractColumnsCached(*stateDir, boardMarkdown, columns, !readOnly, excluded)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if cached {
+		log.Println("INFO: The board didn't change since the last run, reusing its extracted items")
 	}
 
-	return result, nil
-}
+	if len(items) == 0 {
+		log.Println("WARNING: No cards found in the specified column")
+	} else {
+		log.Printf("INFO: Found %d cards in %s", len(items), columnLabel)
+	}
 
-func extractBulletPoints(text string) []string {
-	lines := strings.Split(text, "\n")
-	var bullets []string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "• ") ||
-			(len(line) >= 2 && line[0] >= '1' && line[0] <= '9' && line[1] == '.') {
-			cleanLine := line
-			if strings.HasPrefix(line, "- ") {
-				cleanLine = line[2:]
-			} else if strings.HasPrefix(line, "• ") {
-				cleanLine = line[2:]
-			} else if len(line) >= 2 && line[0] >= '1' && line[0] <= '9' && line[1] == '.' {
-				parts := strings.SplitN(line, ". ", 2)
-				if len(parts) > 1 {
-					cleanLine = parts[1]
-				}
-			}
+	currentYear, currentWeek := *year, *week
 
-			cleanLine = strings.TrimSpace(cleanLine)
-			if cleanLine != "" {
-				bullets = append(bullets, cleanLine)
+	if *weekOnly {
+		var excluded []worklog.Item
+		items, excluded = completedInWeek(items, currentYear, currentWeek, *skipUndated)
+		if len(excluded) > 0 {
+			log.Printf("INFO: Leaving out %d %s not completed in week %d", len(excluded), worklog.Pluralize(len(excluded), "card", "cards"), currentWeek)
+		}
+		for _, item := range excluded {
+			reason := fmt.Sprintf("completed on %s, outside week %d %d", item.Date.Format(worklog.DateLayout), currentWeek, currentYear)
+			if item.Date.IsZero() {
+				reason = "no completion date"
 			}
+			activeTrace.exclude(item.Title, reason)
 		}
 	}
+	activeTrace.include(items, "checked card in "+columnLabel)
 
-	return bullets
-}
-
-func buildMarkdownSummary(summaries map[string][]string, year int, week int, aiAssisted bool) string {
-	var sb strings.Builder
-
-	sb.WriteString(fmt.Sprintf("## Week %d %d\n\n", week, year))
-
-	for category, bullets := range summaries {
-		if len(bullets) == 0 {
-			continue
+	if sourceOpts.historyDomains == "" {
+		sourceOpts.historyDomains = strings.Join(cfg.HistoryDomains, ",")
+	}
+	if skipLLMSteps && sourceOpts.needsLLM() {
+		log.Println("WARNING: Leaving out voice memos and board photos, since reading them calls the LLM")
+		sourceOpts.voiceMemos, sourceOpts.boardPhotos = "", ""
+	}
+	var sourceKey string
+	if sourceOpts.needsLLM() {
+		if sourceKey, err = resolveAPIKey(*apiKey); err != nil {
+			fatalf("%v, required for --voice-memos and --board-photos", err)
 		}
+	}
+	sources, err := sourceOpts.build(columns, sourceKey, *stateDir)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if len(sources) > 0 {
+		start := worklog.WeekStart(currentYear, currentWeek)
+		sourceItems, err := collectSourceItems(context.Background(), sources, start, start.AddDate(0, 0, 7))
+		if err != nil {
+			fatalf("Failed to collect items: %v", err)
+		}
+		assignColumn(sourceItems, columns)
+		items = append(items, sourceItems...)
+	}
 
-		sb.WriteString(fmt.Sprintf("### %s\n\n", strings.Title(category)))
+	enrichers, err := enricherOpts.build(cfg)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if items, err = enrichItems(context.Background(), enrichers, items); err != nil {
+		fatalf("Failed to enrich items: %v", err)
+	}
 
-		if aiAssisted {
-			if len(bullets) > 0 {
-				sb.WriteString(bullets[0])
-				sb.WriteString("\n\n")
-			}
+	if deduped := dedupItems(items); len(deduped) < len(items) {
+		log.Printf("INFO: Merged %d items that appear in more than one source", len(items)-len(deduped))
+		items = deduped
+	}
 
-			if len(bullets) > 1 {
-				sb.WriteString("**Key Points:**\n")
-				for _, bullet := range bullets[1:] {
-					sb.WriteString(fmt.Sprintf("- %s\n", bullet))
-				}
-				sb.WriteString("\n")
+	if *maxItems > 0 {
+		var dropped []worklog.Item
+		if items, dropped = capItems(items, *maxItems, currentYear, currentWeek); len(dropped) > 0 {
+			log.Printf("WARNING: Found more than %d items, leaving out the %d least important", *maxItems, len(dropped))
+			for _, item := range dropped {
+				activeTrace.exclude(item.Title, fmt.Sprintf("over the --max-items cap of %d", *maxItems))
 			}
-		} else {
-			for _, item := range bullets {
-				sb.WriteString(fmt.Sprintf("- %s\n", item))
-			}
-			sb.WriteString("\n")
 		}
 	}
 
-	return sb.String()
-}
-
-func saveWorklog(outputFolder string, year int, week int, content string) error {
-	err := os.MkdirAll(outputFolder, 0755)
+	if skipLLMSteps && usesLLM(cfg) {
+		log.Println("WARNING: Categorizing without the llm strategy, since it calls the LLM")
+		cfg.dropLLMStrategy()
+	}
+	var categorizeKey string
+	if usesLLM(cfg) {
+		if categorizeKey, err = resolveAPIKey(*apiKey); err != nil {
+			fatalf("%v, required for the llm categorization strategy", err)
+		}
+	}
+	itemCategorizer, err := newCategorizer(cfg, categorizeKey)
 	if err != nil {
-		return fmt.Errorf("failed to create output folder: %w", err)
+		fatalf("%v", err)
 	}
-
-	filename := fmt.Sprintf("%s/worklog-week-%d-%d.md", outputFolder, week, year)
-
-	err = os.WriteFile(filename, []byte(content), 0644)
+	categories, decisions, err := itemCategorizer.Categorize(items)
 	if err != nil {
-		return fmt.Errorf("failed to write worklog file: %w", err)
+		fatalf("Failed to categorize items: %v", err)
+	}
+	if *explainCategorization {
+		logDecisions(decisions)
+	}
+	activeTrace.categorize(decisions, categories)
+	if *language != "" && skipLLMSteps {
+		log.Printf("WARNING: Leaving the items untranslated, since translating them to %s calls the LLM", *language)
+	} else if *language != "" {
+		key, err := resolveAPIKey(*apiKey)
+		if err != nil {
+			fatalf("%v, required for --language", err)
+		}
+		raw := summarize.Options{RawCategories: cfg.RawCategories}.Raw
+		if err := normalizeItemLanguage(newLLMClient(key), *model, categories, items, *language, raw); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	runReport.stage("extract", extractStart)
+	runReport.Items = len(items)
+	runReport.Categories = make(map[string]int)
+	for category, categoryItems := range categories {
+		if len(categoryItems) > 0 {
+			runReport.Categories[category] = len(categoryItems)
+		}
 	}
 
-	log.Printf("INFO: Saved worklog to %s", filename)
-	return nil
-}
+	var locked []output.Section
+	if outputRenderer.Format == "md" && *appendTo != "" {
+		note, err := os.ReadFile(*appendTo)
+		if err == nil {
+			locked = lockedSections(noteWeek(string(note), weekTitle(currentYear, currentWeek)))
+		}
+	} else if outputRenderer.Format == "md" {
+		existing, err := os.ReadFile(worklogFilename(*outputFolder, currentYear, currentWeek, outputRenderer.Extension))
+		if err == nil {
+			locked = lockedSections(string(existing))
+		}
+	}
+	for _, section := range locked {
+		log.Printf("INFO: Keeping manually edited section '%s'", section.Title())
+		activeTrace.lock(section.Title())
+	}
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
-	log.SetPrefix("WORKLOG-GEN: ")
+	var client *summarize.Client
+	if *aiAssisted && !*dryRun {
+		*apiKey, err = resolveAPIKey(*apiKey)
+		if err != nil {
+			fatalf("%v, required for AI-assisted mode", err)
+		}
+		client = newLLMClient(*apiKey)
+		log.Printf("INFO: Generating AI-assisted summaries using the %s API", client.Provider.Name())
+	} else if *noLLM {
+		log.Println("INFO: Listing the cleaned card titles by category, without calling the LLM")
+	} else if !*dryRun {
+		log.Println("INFO: Generating simple category-based summaries")
+	}
 
-	boardPath := flag.String("board", "", "Path to the Kanban board markdown file")
-	column := flag.String("column", "", "Column to summarize")
-	outputFolder := flag.String("output-folder", "", "Folder to write the summary")
-	apiKey := flag.String("api-key", "", "OpenAI API key (can also be set via OPENAI_API_KEY env var)")
-	aiAssisted := flag.Bool("ai-assisted", false, "Use AI to generate summaries (requires OpenAI API key)")
+	background, err := loadContextFile(*contextPath)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var summaryTemplate *template.Template
+	if *batch && *promptPath != "" && *aiAssisted {
+		log.Println("WARNING: Ignoring --prompt, since --batch sends its own prompt covering all categories")
+	} else if *promptPath != "" {
+		if summaryTemplate, err = summarize.LoadPromptTemplate(*promptPath); err != nil {
+			fatalf("%v", err)
+		}
+	}
 
-	flag.Parse()
+	attribution := worklog.AttributionNone
+	if *sourceBadges {
+		attribution = worklog.AttributionBadge
+	} else if len(worklog.ItemSources(categories)) > 1 {
+		attribution = worklog.AttributionLabel
+	}
 
-	if *boardPath == "" || *column == "" || *outputFolder == "" {
-		log.Println("ERROR: board, column, and output-folder flags are required")
-		flag.Usage()
-		os.Exit(1)
+	summarizeStart := time.Now()
+	summarizeOpts := summarize.Options{
+		Client:         client,
+		AIAssisted:     *aiAssisted,
+		CategoryModels: cfg.CategoryModels,
+		RawCategories:  cfg.RawCategories,
+		Model:          *model,
+		MaxTokens:      *maxTokens,
+		Budget:         summarize.Budget{Words: *bulletWords, ItemsPerBullet: *itemsPerBullet},
+		Prompt:         summaryTemplate,
+		Context:        background,
+		Attribution:    attribution,
+		Language:       *language,
+		CleanTitles:    *noLLM,
+		Concurrency:    *concurrency,
+		Batch:          *batch,
+	}
+	// Recorded runs must send every request, and summaries of read-only
+	// runs aren't kept.
+	var summaries *summaryCache
+	if *aiAssisted && !readOnly && activeCassette == nil {
+		summaries = loadSummaryCache(*stateDir, *refreshSummaries)
+		summarizeOpts.Cache = summaries
+	}
+	if *dryRun {
+		if err := printDryRun(os.Stdout, categories, columns, cfg.CategoryWeights, summarizeOpts, currentYear, currentWeek); err != nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+	if *plainLanguage || *dualAudience {
+		log.Println("INFO: Generating plain-language summaries")
+	}
+	sections, err := columnSummaries(categories, columns, locked, summarizeOpts, *plainLanguage || *dualAudience)
+	if err != nil {
+		fatalf("Failed to generate summaries: %v", err)
+	}
+	if summaries != nil {
+		if summaries.hits > 0 {
+			log.Printf("INFO: Reused the cached %s of %d unchanged %s", worklog.Pluralize(summaries.hits, "summary", "summaries"), summaries.hits, worklog.Pluralize(summaries.hits, "category", "categories"))
+		}
+		if err := summaries.save(); err != nil {
+			log.Printf("WARNING: Failed to save the summary cache: %v", err)
+		}
+	}
+	var comparison string
+	if *compareLastWeek {
+		records, err := loadHistory(*stateDir)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if previous, ok := previousRecord(records, currentYear, currentWeek); ok {
+			log.Printf("INFO: Comparing the week to week %d, %d", previous.Week, previous.Year)
+			if comparison, err = weekComparison(categories, previous, summarizeOpts); err != nil {
+				fatalf("Failed to compare weeks: %v", err)
+			}
+		} else {
+			log.Println("WARNING: No earlier week in the run history to compare to")
+		}
 	}
+	runReport.stage("summarize", summarizeStart)
 
-	_, err := os.Stat(*boardPath)
-	if os.IsNotExist(err) {
-		log.Fatalf("ERROR: Board file '%s' does not exist", *boardPath)
+	if len(sections) == 0 {
+		log.Println("WARNING: All summaries are empty")
 	}
 
-	log.Printf("INFO: Reading board file: %s", *boardPath)
-	data, err := os.ReadFile(*boardPath)
-	if err != nil {
-		log.Fatalf("ERROR: Failed to read board file: %v", err)
+	renderStart := time.Now()
+	log.Printf("INFO: Building worklog summary for week %d, %d", currentWeek, currentYear)
+	doc := &output.Document{Year: currentYear, Week: currentWeek, AIAssisted: *aiAssisted, Sections: sections}
+	doc.DualAudience = *dualAudience
+	doc.Comparison = comparison
+	if *sortOrder == "importance" {
+		sortByImportance(doc, categories)
+	}
+	applyLockedSections(doc, locked, cfg.CategoryWeights)
+	doc.Collaboration = output.DetectCollaboration(items)
+	doc.Estimates = output.EstimateStats(categories, cfg.CategoryWeights)
+	if *patterns {
+		doc.Patterns = output.Patterns(items)
+	}
+	if *overloadWarnings || *overloadNoteFlag {
+		records, err := loadHistory(*stateDir)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		baseline := baselineLoads(records, currentYear, currentWeek, cfg.Overload.withDefaults().BaselineWeeks)
+		if len(baseline) < minBaselineWeeks {
+			log.Printf("INFO: Skipping overload check, the run history has %d of the %d previous weeks needed", len(baseline), minBaselineWeeks)
+		}
+		if signals := overloadSignals(measureLoad(worklog.Titles(items)), baseline, cfg.Overload); len(signals) > 0 {
+			log.Printf("WARNING: This week looks heavier than usual: %s", strings.Join(signals, "; "))
+			if *overloadNoteFlag {
+				doc.SelfReview = overloadNote(signals)
+			}
+		}
 	}
-	boardMarkdown := string(data)
 
-	log.Printf("INFO: Extracting items from column: %s", *column)
-	items, err := extractColumnItems(boardMarkdown, *column)
-	if err != nil {
-		log.Fatalf("ERROR: %v", err)
+	if *notesPath != "" {
+		notes, err := os.ReadFile(*notesPath)
+		if err != nil {
+			fatalf("Failed to read notes file: %v", err)
+		}
+		doc.Notes = strings.TrimSpace(string(notes))
+	}
+	if *citations {
+		citationVault := *vault
+		if citationVault == "" {
+			citationVault, _ = findVault(filepath.Dir(*boardPath))
+		}
+		citeItems(doc, categories, *boardPath, citationVault)
+	}
+	if usageReport.has(usageInWorklog) {
+		// The digest below is written after the worklog, so its tokens only
+		// count in the log and the sidecar.
+		doc.Usage = runUsage(cfg.ModelPrices)
+	}
+	summary := outputRenderer.Render(doc)
+	if *sandbox {
+		fmt.Print(summary)
+		return
 	}
 
-	if len(items) == 0 {
-		log.Println("WARNING: No cards found in the specified column")
+	var worklogPath string
+	if *appendTo != "" {
+		worklogPath, err = appendToNote(*appendTo, *appendHeading, currentYear, currentWeek, summary)
 	} else {
-		log.Printf("INFO: Found %d cards in column '%s'", len(items), *column)
+		worklogPath, err = saveWorklog(*outputFolder, currentYear, currentWeek, outputRenderer.Extension, summary)
+	}
+	if err != nil {
+		fatalf("Failed to save worklog: %v", err)
+	}
+	runReport.Worklog = worklogPath
+	writtenFiles := []string{worklogPath}
+
+	if *digest > 0 {
+		log.Printf("INFO: Compressing the worklog into %d %s", *digest, worklog.Pluralize(*digest, "sentence", "sentences"))
+		// Raw-only categories stay out of the digest, which is written by the
+		// model.
+		digestDoc := *doc
+		digestDoc.Sections = slices.DeleteFunc(slices.Clone(doc.Sections), func(section output.Section) bool {
+			return summarizeOpts.Raw(section.Category)
+		})
+		doc.Digest, err = summarize.Digest(output.RenderMarkdown(&digestDoc), *digest, summarizeOpts)
+		if err != nil {
+			fatalf("Failed to generate digest: %v", err)
+		}
+		digestPath, err := saveWorklog(*outputFolder, currentYear, currentWeek, "digest.txt", doc.Digest+"\n")
+		if err != nil {
+			fatalf("Failed to save digest: %v", err)
+		}
+		writtenFiles = append(writtenFiles, digestPath)
 	}
 
-	categories := categorizeByTags(items)
+	if usageReport.has(usageInSidecar) {
+		data, err := json.MarshalIndent(newUsageSidecar(currentYear, currentWeek, runUsage(cfg.ModelPrices)), "", "  ")
+		if err != nil {
+			fatalf("Failed to encode usage report: %v", err)
+		}
+		if _, err := saveWorklog(*outputFolder, currentYear, currentWeek, "usage.json", string(data)+"\n"); err != nil {
+			fatalf("Failed to save usage report: %v", err)
+		}
+	}
 
-	if *aiAssisted {
-		if *apiKey == "" {
-			*apiKey = os.Getenv("OPENAI_API_KEY")
-			if *apiKey == "" {
-				log.Println("ERROR: No OpenAI API key provided. Required for AI-assisted mode.")
-				os.Exit(1)
-			}
+	if activeTrace != nil {
+		// The trace is for debugging, so it is not committed or delivered.
+		if _, err := saveWorklog(*outputFolder, currentYear, currentWeek, "explain.md", activeTrace.render(doc)); err != nil {
+			fatalf("Failed to save explanation: %v", err)
 		}
-		log.Println("INFO: Generating AI-assisted summaries using OpenAI API")
-	} else {
-		log.Println("INFO: Generating simple category-based summaries")
 	}
 
-	summaries, err := summarizeByCategory(categories, *apiKey, *aiAssisted)
-	if err != nil {
-		log.Fatalf("ERROR: Failed to generate summaries: %v", err)
+	if *icsExport {
+		calendar, events := buildICS(categories, time.Now())
+		icsPath, err := saveWorklog(*outputFolder, currentYear, currentWeek, "ics", calendar)
+		if err != nil {
+			fatalf("Failed to save calendar export: %v", err)
+		}
+		log.Printf("INFO: Exported %d dated items to %s", events, icsPath)
+		writtenFiles = append(writtenFiles, icsPath)
 	}
 
-	hasAnySummaries := false
-	for _, bullets := range summaries {
-		if len(bullets) > 0 {
-			hasAnySummaries = true
-			break
+	if *feed {
+		feedPath, err := updateFeed(*outputFolder, *feedBaseURL, *feedAuthor)
+		if err != nil {
+			fatalf("Failed to update feed: %v", err)
 		}
+		log.Printf("INFO: Updated feed %s", feedPath)
+		writtenFiles = append(writtenFiles, feedPath)
 	}
+	runReport.stage("render", renderStart)
+
+	result := runResult{Worklog: worklogPath, Year: currentYear, Week: currentWeek, Categories: make(map[string]int), Digest: doc.Digest}
+	if *draft && len(sinks) > 0 {
+		result.Draft = true
+		log.Println("INFO: Draft mode: nothing was delivered. Review the worklog, then publish it with:")
+		if !*quiet && !*jsonResult {
+			fmt.Printf("%s publish --week %d --year %d --output-folder %s --format %s <sink flags>\n",
+				filepath.Base(os.Args[0]), currentWeek, currentYear, *outputFolder, outputRenderer.Format)
+		}
+	} else {
+		report := &Report{Doc: doc, Format: outputRenderer.Format, Content: summary}
+		if sinkOpts.anonymize {
+			report = anonymizeReport(report, cfg.Anonymize)
+		}
+		deliverStart := time.Now()
+		results, err := deliver(context.Background(), sinks, report)
+		runReport.stage("deliver", deliverStart)
+		runReport.addSinks(results)
+		if err != nil {
+			// A sink that is down shouldn't lose the report: it is retried
+			// by the next run or the flush subcommand.
+			if queueErr := queueFailures(*stateDir, results, report); queueErr != nil {
+				fatalf("Failed to deliver worklog: %v (and failed to queue it: %v)", err, queueErr)
+			}
+		} else if err := queueFailures(*stateDir, results, report); err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+		// Earlier failures are only retried on the sinks that are up again.
+		var reachable []Sink
+		for i, sinkResult := range results {
+			if sinkResult.Err == nil {
+				result.Delivered = append(result.Delivered, sinkResult.Name)
+				reachable = append(reachable, sinks[i])
+			}
+		}
 
-	if !hasAnySummaries {
-		log.Println("WARNING: All summaries are empty")
+		if delivered, _, err := flushQueue(context.Background(), *stateDir, reachable); err != nil {
+			log.Printf("WARNING: Failed to retry queued deliveries: %v", err)
+		} else if delivered > 0 {
+			log.Printf("INFO: Delivered %d queued %s from earlier runs", delivered, worklog.Pluralize(delivered, "report", "reports"))
+		}
 	}
 
-	currentYear := time.Now().Year()
-	_, currentWeek := time.Now().ISOWeek()
+	if *gitCommit {
+		data := commitData{Year: currentYear, Week: currentWeek, Items: len(items), Files: writtenFiles}
+		if err := commitWorklog(writtenFiles, *gitCommitMessage, data, *gitPush); err != nil {
+			fatalf("Failed to commit worklog: %v", err)
+		}
+	}
 
-	log.Printf("INFO: Building worklog summary for week %d, %d", currentWeek, currentYear)
-	summary := buildMarkdownSummary(summaries, currentYear, currentWeek, *aiAssisted)
+	record := HistoryRecord{Year: currentYear, Week: currentWeek, GeneratedAt: time.Now()}
+	for _, category := range worklog.OrderedCategories(categories, nil) {
+		for _, item := range categories[category] {
+			record.Items = append(record.Items, HistoryItem{ID: item.ID, Title: item.Title, Source: item.Source, Category: category})
+		}
+	}
+	if err := appendHistory(*stateDir, record); err != nil {
+		log.Printf("WARNING: Failed to record run history: %v", err)
+	}
 
-	err = saveWorklog(*outputFolder, currentYear, currentWeek, summary)
-	if err != nil {
-		log.Fatalf("ERROR: Failed to save worklog: %v", err)
+	for _, item := range record.Items {
+		result.Categories[item.Category]++
 	}
+	result.Items = len(record.Items)
 
-	totalItems := 0
-	for _, items := range categories {
-		totalItems += len(items)
+	logUsage(runUsage(cfg.ModelPrices))
+	runReport.finish(nil)
+	if err := writeRunReport(*stateDir, runReport); err != nil {
+		log.Printf("WARNING: %v", err)
 	}
 
-	worklogFilename := fmt.Sprintf("%d-%d.md", currentWeek, currentYear)
-	log.Printf("SUCCESS: Summarized %d items to %s/%s", totalItems, *outputFolder, worklogFilename)
+	log.Printf("SUCCESS: Summarized %d items to %s", result.Items, worklogPath)
+
+	switch {
+	case *jsonResult:
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			fatalf("Failed to encode result: %v", err)
+		}
+	case *quiet:
+		fmt.Println(result)
+	}
 }
@@ -0,0 +1,118 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Collaborator summarizes the cards that mention a single person.
+type Collaborator struct {
+	Name   string
+	Counts map[string]int
+	Items  []string
+}
+
+var collaborationKinds = []struct {
+	kind     string
+	singular string
+	plural   string
+	keywords []string
+}{
+	{"pairing", "pairing session", "pairing sessions", []string{"pair", "paired", "pairing"}},
+	{"review", "review", "reviews", []string{"review", "reviewed", "reviewing"}},
+	{"mentoring", "mentoring session", "mentoring sessions", []string{"mentor", "mentored", "mentoring", "onboard", "onboarded", "onboarding", "coached", "coaching"}},
+}
+
+// collaborationKind classifies a card by its tags first and its wording
+// second. Cards that match neither count as general collaboration.
+func collaborationKind(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !(r == '#' || r == '-' || r >= 'a' && r <= 'z')
+	})
+	for _, kind := range collaborationKinds {
+		for _, word := range words {
+			for _, keyword := range kind.keywords {
+				if word == "#"+keyword || word == keyword {
+					return kind.kind
+				}
+			}
+		}
+	}
+	return "collaboration"
+}
+
+// DetectCollaboration groups the cards mentioning other people by person,
+// ordered by the number of cards.
+func DetectCollaboration(items []worklog.Item) []Collaborator {
+	byName := make(map[string]*Collaborator)
+	for _, item := range items {
+		for _, name := range worklog.ExtractMentions(item.Title) {
+			key := strings.ToLower(name)
+			collaborator, ok := byName[key]
+			if !ok {
+				collaborator = &Collaborator{Name: name, Counts: make(map[string]int)}
+				byName[key] = collaborator
+			}
+			collaborator.Counts[collaborationKind(item.Title)]++
+			collaborator.Items = append(collaborator.Items, item.Title)
+		}
+	}
+
+	collaborators := make([]Collaborator, 0, len(byName))
+	for _, collaborator := range byName {
+		collaborators = append(collaborators, *collaborator)
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		if len(collaborators[i].Items) != len(collaborators[j].Items) {
+			return len(collaborators[i].Items) > len(collaborators[j].Items)
+		}
+		return strings.ToLower(collaborators[i].Name) < strings.ToLower(collaborators[j].Name)
+	})
+	return collaborators
+}
+
+// Summary describes the kinds of collaboration, e.g. "2 reviews, 1 pairing
+// session".
+func (c Collaborator) Summary() string {
+	var parts []string
+	for _, kind := range collaborationKinds {
+		switch count := c.Counts[kind.kind]; count {
+		case 0:
+		case 1:
+			parts = append(parts, "1 "+kind.singular)
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", count, kind.plural))
+		}
+	}
+	if count := c.Counts["collaboration"]; count > 0 {
+		parts = append(parts, fmt.Sprintf("%d other shared %s", count, worklog.Pluralize(count, "card", "cards")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseCollaborator reads a collaborator back from its name, the Summary of
+// its kinds of collaboration, and its items, as rendered in a worklog.
+func ParseCollaborator(name string, summary string, items []string) Collaborator {
+	collaborator := Collaborator{Name: name, Counts: make(map[string]int), Items: items}
+	for _, part := range strings.Split(summary, ", ") {
+		var count int
+		var noun string
+		if _, err := fmt.Sscanf(part, "%d", &count); err != nil {
+			continue
+		}
+		_, noun, _ = strings.Cut(part, " ")
+		if noun == "other shared card" || noun == "other shared cards" {
+			collaborator.Counts["collaboration"] += count
+			continue
+		}
+		for _, kind := range collaborationKinds {
+			if noun == kind.singular || noun == kind.plural {
+				collaborator.Counts[kind.kind] += count
+			}
+		}
+	}
+	return collaborator
+}
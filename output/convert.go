@@ -0,0 +1,122 @@
+package output
+
+import (
+	"regexp"
+	"strings"
+)
+
+// underscoreItalicPattern matches text in italics written with underscores,
+// which reStructuredText would read as a reference.
+var underscoreItalicPattern = regexp.MustCompile(`(^|[^\w_])_(\S(?:[^_]*\S)?)_($|[^\w_])`)
+
+// convertLines converts markdown to another format line by line, with line
+// converting a single line including its newline.
+func convertLines(markdown string, line func(string) string) string {
+	var sb strings.Builder
+	for _, l := range strings.SplitAfter(markdown, "\n") {
+		sb.WriteString(line(l))
+	}
+	return sb.String()
+}
+
+// ConvertMarkdown returns markdown unchanged, the Convert of markdown.
+func ConvertMarkdown(markdown string) string {
+	return markdown
+}
+
+// ConvertRST converts a markdown note, such as a rollup, to
+// reStructuredText.
+func ConvertRST(markdown string) string {
+	return convertLines(markdown, rstLine)
+}
+
+// ConvertAsciiDoc converts a markdown note, such as a rollup, to AsciiDoc.
+func ConvertAsciiDoc(markdown string) string {
+	return convertLines(markdown, adocLine)
+}
+
+// ConvertText converts a markdown note, such as a rollup, to plain text.
+func ConvertText(markdown string) string {
+	return convertLines(markdown, plainLine)
+}
+
+// ConvertSlack converts a markdown note, such as a rollup, to mrkdwn.
+func ConvertSlack(markdown string) string {
+	return convertLines(markdown, slackLine)
+}
+
+// rstLine converts a line of markdown to reStructuredText: headings are
+// underlined as in worklogs, links become hyperlink references, and
+// emphasis without an equivalent is reduced to its text; comments are
+// removed.
+func rstLine(line string) string {
+	text := strings.TrimRight(line, "\n")
+	newline := line[len(text):]
+
+	text = commentPattern.ReplaceAllString(text, "")
+	if strings.TrimSpace(text) == "" && strings.TrimSpace(line) != "" {
+		// A line of nothing but a comment, such as a manual section's marker.
+		return ""
+	}
+	text = imagePattern.ReplaceAllString(text, "$1")
+	text = mdLinkPattern.ReplaceAllString(text, "`$1 <$2>`_")
+	text = replaceWikiLinks(text)
+	text = emphasisPattern.ReplaceAllStringFunc(text, func(emphasis string) string {
+		m := emphasisPattern.FindStringSubmatch(emphasis)
+		if m[1] == "**" || m[1] == "__" {
+			return "**" + m[2] + "**"
+		}
+		return m[2]
+	})
+	text = underscoreItalicPattern.ReplaceAllString(text, "$1*$2*$3")
+
+	if m := headingPattern.FindStringSubmatch(text); m != nil {
+		level := strings.IndexFunc(text, func(r rune) bool { return r != '#' })
+		return strings.TrimRight(rstMarkup.heading(level, m[1]), "\n") + newline
+	}
+	if m := listPattern.FindStringSubmatch(text); m != nil {
+		bullet := rstMarkup.bullet
+		if m[1] != "" {
+			bullet = rstMarkup.nested
+		}
+		text = bullet + text[len(m[0]):]
+	}
+	return text + newline
+}
+
+// adocLine converts a line of markdown to AsciiDoc: headings, bold text,
+// list items, and links become their AsciiDoc equivalents, and emphasis
+// without one is reduced to its text; comments are removed.
+func adocLine(line string) string {
+	text := strings.TrimRight(line, "\n")
+	newline := line[len(text):]
+
+	text = commentPattern.ReplaceAllString(text, "")
+	if strings.TrimSpace(text) == "" && strings.TrimSpace(line) != "" {
+		// A line of nothing but a comment, such as a manual section's marker.
+		return ""
+	}
+	text = imagePattern.ReplaceAllString(text, "$1")
+	text = mdLinkPattern.ReplaceAllString(text, "$2[$1]")
+	text = replaceWikiLinks(text)
+	text = emphasisPattern.ReplaceAllStringFunc(text, func(emphasis string) string {
+		m := emphasisPattern.FindStringSubmatch(emphasis)
+		if m[1] == "**" || m[1] == "__" {
+			return asciiDocMarkup.bold(m[2])
+		}
+		return m[2]
+	})
+
+	if m := headingPattern.FindStringSubmatch(text); m != nil {
+		level := strings.IndexFunc(text, func(r rune) bool { return r != '#' })
+		return strings.TrimRight(asciiDocMarkup.heading(level, m[1]), "\n") + newline
+	}
+	if m := listPattern.FindStringSubmatch(text); m != nil {
+		bullet := asciiDocMarkup.bullet
+		if m[1] != "" {
+			bullet = asciiDocMarkup.nested
+		}
+		text = bullet + text[len(m[0]):]
+	}
+	return text + newline
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// EstimateStat compares estimated and actual effort of the estimated cards
+// in one category.
+type EstimateStat struct {
+	Category       string
+	Cards          int
+	EstimatedHours float64
+	ActualHours    float64
+}
+
+// Ratio is the actual effort as a multiple of the estimate.
+func (s EstimateStat) Ratio() float64 {
+	if s.EstimatedHours == 0 {
+		return 0
+	}
+	return s.ActualHours / s.EstimatedHours
+}
+
+// Title returns the heading used for the stat in the report.
+func (s EstimateStat) Title() string {
+	return Section{Category: s.Category}.Title()
+}
+
+// Summary describes the stat, e.g. "3 cards, estimated 2d, took 3d (1.5×)".
+func (s EstimateStat) Summary() string {
+	return fmt.Sprintf("%d %s, estimated %s, took %s (%.1f× the estimate)",
+		s.Cards, worklog.Pluralize(s.Cards, "card", "cards"), formatHours(s.EstimatedHours), formatHours(s.ActualHours), s.Ratio())
+}
+
+// ParseEstimateStat reads a stat back from its title and Summary, as
+// rendered in a worklog; the title Overall is the stat of the whole week.
+// The effort is as precise as the summary.
+func ParseEstimateStat(title string, summary string) (EstimateStat, bool) {
+	var cards int
+	var estimated, actual string
+	if _, err := fmt.Sscanf(summary, "%d", &cards); err != nil {
+		return EstimateStat{}, false
+	}
+	if _, rest, ok := strings.Cut(summary, "estimated "); ok {
+		estimated, rest, _ = strings.Cut(rest, ", took ")
+		actual, _, _ = strings.Cut(rest, " ")
+	}
+	estimatedHours, ok1 := parseHours(estimated)
+	actualHours, ok2 := parseHours(actual)
+	if !ok1 || !ok2 {
+		return EstimateStat{}, false
+	}
+	stat := EstimateStat{Category: strings.ToLower(title), Cards: cards, EstimatedHours: estimatedHours, ActualHours: actualHours}
+	if title == "Overall" {
+		stat.Category = ""
+	}
+	return stat, true
+}
+
+// parseHours reads effort printed by formatHours.
+func parseHours(text string) (float64, bool) {
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(text, "d"):
+		unit = 8
+	case !strings.HasSuffix(text, "h"):
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(text[:len(text)-1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return value * unit, true
+}
+
+// formatHours prints effort in days once it reaches a working day.
+func formatHours(hours float64) string {
+	if hours >= 8 {
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", hours/8), ".0") + "d"
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", hours), ".0") + "h"
+}
+
+// actualHours returns the effort spent on a card: the #spent/ tag if present,
+// otherwise the working days between its start and completion dates.
+func actualHours(title string) (float64, bool) {
+	if spent, ok := worklog.SpentHours(title); ok {
+		return spent, true
+	}
+
+	start, ok := worklog.StartDate(title)
+	if !ok {
+		return 0, false
+	}
+	end, ok := worklog.CompletionDate(title)
+	if !ok || end.Before(start) {
+		return 0, false
+	}
+	return float64(worklog.WorkingDays(start, end) * 8), true
+}
+
+func estimatedHours(title string) (float64, bool) {
+	for _, tag := range worklog.ExtractTags(title) {
+		if duration, ok := strings.CutPrefix(tag, "est/"); ok {
+			return worklog.ParseDurationHours(duration)
+		}
+	}
+	return 0, false
+}
+
+// EstimateStats computes estimate-vs-actual per category for cards carrying
+// both an estimate (#est/2d) and a measurable actual effort, in the order of
+// weights. The last entry, with an empty category, covers all categories.
+func EstimateStats(categories map[string][]worklog.Item, weights worklog.Weights) []EstimateStat {
+	var stats []EstimateStat
+	total := EstimateStat{}
+
+	for _, category := range worklog.OrderedCategories(categories, weights) {
+		stat := EstimateStat{Category: category}
+		for _, item := range categories[category] {
+			estimate, ok := estimatedHours(item.Title)
+			if !ok || estimate == 0 {
+				continue
+			}
+			actual, ok := actualHours(item.Title)
+			if !ok {
+				continue
+			}
+			stat.Cards++
+			stat.EstimatedHours += estimate
+			stat.ActualHours += actual
+		}
+		if stat.Cards == 0 {
+			continue
+		}
+
+		stats = append(stats, stat)
+		total.Cards += stat.Cards
+		total.EstimatedHours += stat.EstimatedHours
+		total.ActualHours += stat.ActualHours
+	}
+
+	if len(stats) == 0 {
+		return nil
+	}
+	return append(stats, total)
+}
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// jiraEscaper escapes the characters starting Jira wiki markup that markdown
+// text uses literally.
+var jiraEscaper = strings.NewReplacer("{", `\{`, "}", `\}`, "|", `\|`)
+
+// JiraWiki converts a markdown worklog to Jira's wiki markup, which comments
+// of the REST API version 2 are written in.
+func JiraWiki(markdown string) string {
+	return convertLines(markdown, jiraLine)
+}
+
+// jiraLine converts a line of markdown to wiki markup: headings become
+// heading blocks, bold text and list items their wiki equivalents, and links
+// wiki links; comments are removed.
+func jiraLine(line string) string {
+	text := strings.TrimRight(line, "\n")
+	newline := line[len(text):]
+
+	text = commentPattern.ReplaceAllString(text, "")
+	if strings.TrimSpace(text) == "" && strings.TrimSpace(line) != "" {
+		// A line of nothing but a comment, such as a manual section's marker.
+		return ""
+	}
+	text = jiraEscaper.Replace(text)
+	text = imagePattern.ReplaceAllString(text, "$1")
+	text = mdLinkPattern.ReplaceAllString(text, "[$1|$2]")
+	text = replaceWikiLinks(text)
+	text = emphasisPattern.ReplaceAllStringFunc(text, func(emphasis string) string {
+		m := emphasisPattern.FindStringSubmatch(emphasis)
+		switch m[1] {
+		case "**", "__":
+			return "*" + m[2] + "*"
+		case "~~":
+			return "-" + m[2] + "-"
+		}
+		return m[2]
+	})
+
+	if m := headingPattern.FindStringSubmatch(text); m != nil {
+		level := strings.IndexFunc(text, func(r rune) bool { return r != '#' })
+		return fmt.Sprintf("h%d. %s%s", level, m[1], newline)
+	}
+	if m := listPattern.FindStringSubmatch(text); m != nil {
+		bullet := "* "
+		if m[1] != "" {
+			bullet = "** "
+		}
+		text = bullet + text[len(m[0]):]
+	}
+	return text + newline
+}
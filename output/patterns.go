@@ -0,0 +1,148 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Late-night completions are those from LateNightStart until LateNightEnd
+// o'clock.
+const (
+	LateNightStart = 22
+	LateNightEnd   = 5
+)
+
+// WorkPatterns describes when the week's items were completed, for
+// workload self-awareness. Only items with a completion date count, and only
+// those with a time of day count towards late-night completions.
+type WorkPatterns struct {
+	Dated     int
+	ByWeekday [7]int
+	Timed     int
+	LateNight int
+}
+
+// HasTimeOfDay reports whether a completion date includes the time of day.
+// Midnight is indistinguishable from a date without time and doesn't count.
+func HasTimeOfDay(date time.Time) bool {
+	return date.Hour() != 0 || date.Minute() != 0 || date.Second() != 0
+}
+
+// IsLateNight reports whether date is a late-night completion.
+func IsLateNight(date time.Time) bool {
+	return HasTimeOfDay(date) && (date.Hour() >= LateNightStart || date.Hour() < LateNightEnd)
+}
+
+// Patterns computes the patterns of items, or nil if none of them has a
+// completion date.
+func Patterns(items []worklog.Item) *WorkPatterns {
+	patterns := &WorkPatterns{}
+	for _, item := range items {
+		if item.Date.IsZero() {
+			continue
+		}
+		patterns.Dated++
+		patterns.ByWeekday[item.Date.Weekday()]++
+		if HasTimeOfDay(item.Date) {
+			patterns.Timed++
+			if IsLateNight(item.Date) {
+				patterns.LateNight++
+			}
+		}
+	}
+	if patterns.Dated == 0 {
+		return nil
+	}
+	return patterns
+}
+
+// weekdays in the order of the working week.
+var weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// MostProductiveDays returns the days with the most completions.
+func (p *WorkPatterns) MostProductiveDays() []time.Weekday {
+	best := 0
+	for _, count := range p.ByWeekday {
+		best = max(best, count)
+	}
+	var days []time.Weekday
+	for _, day := range weekdays {
+		if p.ByWeekday[day] == best {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// Weekend returns the number of completions on Saturdays and Sundays.
+func (p *WorkPatterns) Weekend() int {
+	return p.ByWeekday[time.Saturday] + p.ByWeekday[time.Sunday]
+}
+
+// PatternLine is one labeled line of the patterns appendix.
+type PatternLine struct {
+	Label string
+	Text  string
+}
+
+// Lines describes the patterns for the report.
+func (p *WorkPatterns) Lines() []PatternLine {
+	var names []string
+	for _, day := range p.MostProductiveDays() {
+		names = append(names, day.String())
+	}
+	best := p.ByWeekday[p.MostProductiveDays()[0]]
+
+	var perDay []string
+	for _, day := range weekdays {
+		if p.ByWeekday[day] > 0 {
+			perDay = append(perDay, fmt.Sprintf("%s %d", day.String()[:3], p.ByWeekday[day]))
+		}
+	}
+
+	lines := []PatternLine{
+		{"Most productive " + worklog.Pluralize(len(names), "day", "days"), fmt.Sprintf("%s (%d of %d dated %s)", strings.Join(names, ", "), best, p.Dated, worklog.Pluralize(p.Dated, "item", "items"))},
+		{"Completions per day", strings.Join(perDay, ", ")},
+	}
+	if p.Timed > 0 {
+		lines = append(lines, PatternLine{"Late-night completions", fmt.Sprintf("%d of %d with a time (%02d:00–%02d:00)", p.LateNight, p.Timed, LateNightStart, LateNightEnd)})
+	}
+	if weekend := p.Weekend(); weekend > 0 {
+		lines = append(lines, PatternLine{"Weekend completions", fmt.Sprintf("%d", weekend)})
+	}
+	return lines
+}
+
+// ParsePatterns reads patterns back from their Lines, as rendered in a
+// worklog, or returns nil if they have no completions per day.
+func ParsePatterns(lines []PatternLine) *WorkPatterns {
+	patterns := &WorkPatterns{}
+	for _, line := range lines {
+		switch line.Label {
+		case "Completions per day":
+			for _, part := range strings.Split(line.Text, ", ") {
+				name, count, _ := strings.Cut(part, " ")
+				n, err := strconv.Atoi(count)
+				if err != nil {
+					continue
+				}
+				for _, day := range weekdays {
+					if day.String()[:3] == name {
+						patterns.ByWeekday[day] += n
+						patterns.Dated += n
+					}
+				}
+			}
+		case "Late-night completions":
+			fmt.Sscanf(line.Text, "%d of %d", &patterns.LateNight, &patterns.Timed)
+		}
+	}
+	if patterns.Dated == 0 {
+		return nil
+	}
+	return patterns
+}
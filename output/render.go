@@ -0,0 +1,415 @@
+// Package output builds the document of a worklog and renders it as
+// markdown, reStructuredText, AsciiDoc, plain text, or Slack's mrkdwn.
+package output
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Document is the format-independent representation of a worklog. Every
+// renderer works from the same document so the output formats never drift
+// apart in content.
+type Document struct {
+	Year       int
+	Week       int
+	AIAssisted bool
+	// DualAudience renders the sections' plain-language summaries together
+	// in a stakeholder section ahead of the technical sections, instead of
+	// within each section.
+	DualAudience bool
+	Sections     []Section
+	// Collaboration lists the people mentioned on cards and what was done
+	// together with them.
+	Collaboration []Collaborator
+	// Estimates compares estimated and actual effort per category; the last
+	// entry, without a category, covers the whole week.
+	Estimates []EstimateStat
+	// Patterns describes when the items were completed, for the optional
+	// patterns appendix.
+	Patterns *WorkPatterns
+	// SelfReview holds reflective notes about the week, such as a note about
+	// an unusually heavy workload.
+	SelfReview string
+	// Notes is free-form content from the user's notes file, rendered as is.
+	Notes string
+	// Comparison is commentary comparing the week to the previous one.
+	Comparison string
+	// Digest is the ultra-short version of the worklog from --digest. It is
+	// written to its own file rather than rendered.
+	Digest string
+	// Citations are the cards the bullets cite, as footnotes numbered from
+	// 1 in order.
+	Citations []Citation
+	// Usage is the token usage and cost of generating the document, per
+	// model, for the optional usage appendix.
+	Usage []ModelUsage
+}
+
+// Citation points a bullet back to the card it was written from. Link is an
+// Obsidian link to the card, such as [[Board#^abc123]], and is only rendered
+// in markdown.
+type Citation struct {
+	Text string
+	Link string
+}
+
+// Section holds the content generated for a single category. In AI-assisted
+// mode Summary and KeyPoints are filled, otherwise Items lists the raw card
+// titles. PlainSummary is an optional jargon-free version of Summary for
+// stakeholders outside engineering. Manual holds the verbatim markdown of a
+// section locked by the user. Column groups the sections of a worklog
+// covering several board columns under a heading per column.
+type Section struct {
+	Category     string
+	Column       string
+	Summary      string
+	KeyPoints    []string
+	Items        []string
+	PlainSummary string
+	Manual       string
+	// Citations maps a bullet to the numbers of the citations it carries.
+	Citations map[string][]int
+	// ItemCount is the number of items the section covers, which a summary
+	// doesn't show.
+	ItemCount int
+}
+
+// Title returns the human-readable heading for the section.
+func (s Section) Title() string {
+	return strings.Title(s.Category)
+}
+
+// BuildDocument creates the document of a week from the summaries of its
+// categories, as returned by summarize.ByCategory, with its sections in the
+// canonical category order.
+func BuildDocument(summaries map[string][]string, year int, week int, aiAssisted bool) *Document {
+	doc := &Document{Year: year, Week: week, AIAssisted: aiAssisted}
+
+	for _, category := range worklog.OrderedCategories(summaries, nil) {
+		bullets := summaries[category]
+		if len(bullets) == 0 {
+			continue
+		}
+
+		section := Section{Category: category}
+		if aiAssisted {
+			section.Summary = bullets[0]
+			section.KeyPoints = bullets[1:]
+		} else {
+			section.Items = bullets
+		}
+		doc.Sections = append(doc.Sections, section)
+	}
+
+	return doc
+}
+
+// Renderer renders documents in one output format.
+type Renderer struct {
+	Format    string
+	Extension string
+	Render    func(doc *Document) string
+	// Convert converts a markdown note that isn't a document of a week,
+	// such as a rollup, to the format.
+	Convert func(markdown string) string
+}
+
+var renderers = map[string]Renderer{
+	"md":    {Format: "md", Extension: "md", Render: RenderMarkdown, Convert: ConvertMarkdown},
+	"rst":   {Format: "rst", Extension: "rst", Render: RenderRST, Convert: ConvertRST},
+	"adoc":  {Format: "adoc", Extension: "adoc", Render: RenderAsciiDoc, Convert: ConvertAsciiDoc},
+	"text":  {Format: "text", Extension: "txt", Render: RenderText, Convert: ConvertText},
+	"slack": {Format: "slack", Extension: "slack", Render: RenderSlack, Convert: ConvertSlack},
+}
+
+var formatAliases = map[string]string{
+	"markdown":         "md",
+	"restructuredtext": "rst",
+	"asciidoc":         "adoc",
+	"txt":              "text",
+	"plain":            "text",
+	"mrkdwn":           "slack",
+}
+
+// LookupRenderer returns the renderer of format: md, rst, adoc, text, or
+// slack, or one of their other names.
+func LookupRenderer(format string) (Renderer, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if alias, ok := formatAliases[format]; ok {
+		format = alias
+	}
+
+	r, ok := renderers[format]
+	if !ok {
+		return Renderer{}, fmt.Errorf("unsupported output format '%s' (expected md, rst, adoc, text, or slack)", format)
+	}
+	return r, nil
+}
+
+// markup describes the syntax of a lightweight markup language, so a single
+// renderer can produce all text formats from a document.
+type markup struct {
+	// heading renders a heading; level 2 is the document title, level 3 a
+	// section or a column, and level 4 a section within a column.
+	heading func(level int, title string) string
+	bold    func(text string) string
+	bullet  string
+	nested  string
+	// listIntro ends a bold label that introduces a list.
+	listIntro string
+	// nestedGap surrounds nested lists (reStructuredText needs blank lines).
+	nestedGap string
+	// footnote renders the reference to citation n after a bullet and its
+	// definition at the end of the document; formats with inline footnotes
+	// have no definition.
+	footnote func(n int, citation Citation) (ref string, def string)
+}
+
+var markdownMarkup = markup{
+	heading: func(level int, title string) string {
+		return fmt.Sprintf("%s %s\n\n", strings.Repeat("#", level), title)
+	},
+	bold:      func(text string) string { return "**" + text + "**" },
+	bullet:    "- ",
+	nested:    "  - ",
+	listIntro: "\n",
+	footnote: func(n int, citation Citation) (string, string) {
+		def := fmt.Sprintf("[^%d]: %s", n, citation.Text)
+		if citation.Link != "" {
+			def += " (" + citation.Link + ")"
+		}
+		return fmt.Sprintf(" [^%d]", n), def + "\n"
+	},
+}
+
+var rstMarkup = markup{
+	heading: func(level int, title string) string {
+		switch level {
+		case 0, 1, 2:
+			return rstHeading(title, '=')
+		case 3:
+			return rstHeading(title, '-')
+		default:
+			return rstHeading(title, '~')
+		}
+	},
+	bold:      func(text string) string { return "**" + text + "**" },
+	bullet:    "- ",
+	nested:    "  - ",
+	listIntro: "\n\n",
+	nestedGap: "\n",
+	footnote: func(n int, citation Citation) (string, string) {
+		return fmt.Sprintf(" [#c%d]_", n), fmt.Sprintf(".. [#c%d] %s\n\n", n, citation.Text)
+	},
+}
+
+var asciiDocMarkup = markup{
+	heading: func(level int, title string) string {
+		return fmt.Sprintf("%s %s\n\n", strings.Repeat("=", level), title)
+	},
+	bold:      func(text string) string { return "*" + text + "*" },
+	bullet:    "* ",
+	nested:    "** ",
+	listIntro: "\n\n",
+	footnote: func(n int, citation Citation) (string, string) {
+		return " footnote:[" + strings.ReplaceAll(citation.Text, "]", "\\]") + "]", ""
+	},
+}
+
+// rstHeading underlines title with the given adornment character, as
+// reStructuredText requires the underline to be at least as long as the title.
+func rstHeading(title string, adornment rune) string {
+	return fmt.Sprintf("%s\n%s\n\n", title, strings.Repeat(string(adornment), len([]rune(title))))
+}
+
+// RenderMarkdown renders doc as markdown.
+func RenderMarkdown(doc *Document) string {
+	return renderText(doc, markdownMarkup)
+}
+
+// RenderRST renders doc as reStructuredText.
+func RenderRST(doc *Document) string {
+	return renderText(doc, rstMarkup)
+}
+
+// RenderAsciiDoc renders doc as AsciiDoc.
+func RenderAsciiDoc(doc *Document) string {
+	return renderText(doc, asciiDocMarkup)
+}
+
+// PlainLanguageLabel introduces a section's plain-language summary.
+const PlainLanguageLabel = "In plain language:"
+
+// SelfReviewHeading is the title of the section with reflective notes.
+const SelfReviewHeading = "Self-Review"
+
+// StakeholderHeading is the title of the section collecting the
+// plain-language summaries of a dual-audience document.
+const StakeholderHeading = "For Stakeholders"
+
+// ComparisonHeading is the title of the section comparing the week to the
+// previous one.
+const ComparisonHeading = "Compared to Last Week"
+
+// Titles of the appendices following the sections.
+const (
+	CollaborationHeading = "Collaboration"
+	EstimatesHeading     = "Estimates"
+	PatternsHeading      = "Patterns"
+	NotesHeading         = "Notes"
+	UsageHeading         = "LLM Usage"
+)
+
+func renderText(doc *Document, m markup) string {
+	var sb strings.Builder
+
+	cite := func(section Section, bullet string) string {
+		var refs strings.Builder
+		for _, n := range section.Citations[bullet] {
+			if n >= 1 && n <= len(doc.Citations) {
+				ref, _ := m.footnote(n, doc.Citations[n-1])
+				refs.WriteString(ref)
+			}
+		}
+		return refs.String()
+	}
+
+	sb.WriteString(m.heading(2, fmt.Sprintf("Week %d %d", doc.Week, doc.Year)))
+
+	if doc.DualAudience {
+		var plain strings.Builder
+		for _, section := range doc.Sections {
+			if section.PlainSummary != "" {
+				plain.WriteString(fmt.Sprintf("%s%s %s\n", m.bullet, m.bold(section.Title()+":"), section.PlainSummary))
+			}
+		}
+		if plain.Len() > 0 {
+			sb.WriteString(m.heading(3, StakeholderHeading))
+			sb.WriteString(plain.String())
+			sb.WriteString("\n")
+		}
+	}
+
+	column := ""
+	for _, section := range doc.Sections {
+		if section.Column != column && section.Column != "" {
+			sb.WriteString(m.heading(3, section.Column))
+		}
+		column = section.Column
+
+		if section.Manual != "" {
+			sb.WriteString(section.Manual)
+			continue
+		}
+
+		if section.Column != "" {
+			sb.WriteString(m.heading(4, section.Title()))
+		} else {
+			sb.WriteString(m.heading(3, section.Title()))
+		}
+
+		// Raw-only categories keep their items in AI-assisted worklogs.
+		if doc.AIAssisted && len(section.Items) == 0 {
+			if section.Summary != "" {
+				sb.WriteString(section.Summary)
+				sb.WriteString("\n\n")
+			}
+
+			if len(section.KeyPoints) > 0 {
+				sb.WriteString(m.bold("Key Points:") + m.listIntro)
+				for _, point := range section.KeyPoints {
+					sb.WriteString(m.bullet + point + cite(section, point) + "\n")
+				}
+				sb.WriteString("\n")
+			}
+
+			if section.PlainSummary != "" && !doc.DualAudience {
+				sb.WriteString(m.bold(PlainLanguageLabel) + " " + section.PlainSummary + "\n\n")
+			}
+		} else {
+			for _, item := range section.Items {
+				sb.WriteString(m.bullet + item + cite(section, item) + "\n")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if doc.Comparison != "" {
+		sb.WriteString(m.heading(3, ComparisonHeading))
+		sb.WriteString(doc.Comparison)
+		sb.WriteString("\n\n")
+	}
+
+	if len(doc.Collaboration) > 0 {
+		sb.WriteString(m.heading(3, CollaborationHeading))
+		for _, collaborator := range doc.Collaboration {
+			sb.WriteString(fmt.Sprintf("%s%s: %s\n%s", m.bullet, m.bold("@"+collaborator.Name), collaborator.Summary(), m.nestedGap))
+			for _, item := range collaborator.Items {
+				sb.WriteString(m.nested + item + "\n")
+			}
+			sb.WriteString(m.nestedGap)
+		}
+		if m.nestedGap == "" {
+			sb.WriteString("\n")
+		}
+	}
+
+	if len(doc.Estimates) > 0 {
+		sb.WriteString(m.heading(3, EstimatesHeading))
+		for _, stat := range doc.Estimates {
+			title := stat.Title()
+			if stat.Category == "" {
+				title = "Overall"
+			}
+			sb.WriteString(fmt.Sprintf("%s%s: %s\n", m.bullet, m.bold(title), stat.Summary()))
+		}
+		sb.WriteString("\n")
+	}
+
+	if doc.Patterns != nil {
+		sb.WriteString(m.heading(3, PatternsHeading))
+		for _, line := range doc.Patterns.Lines() {
+			sb.WriteString(fmt.Sprintf("%s%s: %s\n", m.bullet, m.bold(line.Label), line.Text))
+		}
+		sb.WriteString("\n")
+	}
+
+	if doc.SelfReview != "" {
+		sb.WriteString(m.heading(3, SelfReviewHeading))
+		sb.WriteString(doc.SelfReview)
+		sb.WriteString("\n\n")
+	}
+
+	if doc.Notes != "" {
+		sb.WriteString(m.heading(3, NotesHeading))
+		sb.WriteString(doc.Notes)
+		sb.WriteString("\n\n")
+	}
+
+	if len(doc.Usage) > 0 {
+		sb.WriteString(m.heading(3, UsageHeading))
+		lines := doc.Usage
+		if len(lines) > 1 {
+			lines = append(slices.Clone(lines), TotalUsage(lines))
+		}
+		for _, usage := range lines {
+			sb.WriteString(fmt.Sprintf("%s%s: %s\n", m.bullet, m.bold(usage.Model), usage.Summary()))
+		}
+		sb.WriteString("\n")
+	}
+
+	var defs strings.Builder
+	for i, citation := range doc.Citations {
+		_, def := m.footnote(i+1, citation)
+		defs.WriteString(def)
+	}
+	if defs.Len() > 0 {
+		sb.WriteString(strings.TrimRight(defs.String(), "\n") + "\n\n")
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"testing"
+)
+
+// giantDocument builds the document of a raw worklog of a giant board, with
+// items items spread over the built-in categories and every item cited.
+func giantDocument(items int) *Document {
+	categories := []string{"features", "bugs", "reviews", "planning/design", "other"}
+	summaries := make(map[string][]string, len(categories))
+	for i := range items {
+		category := categories[i%len(categories)]
+		summaries[category] = append(summaries[category], fmt.Sprintf("Fix bug %d in [[Project %d]] #bug @alice", i, i%7))
+	}
+	doc := BuildDocument(summaries, 2024, 18, false)
+	for i := range doc.Sections {
+		section := &doc.Sections[i]
+		section.Citations = make(map[string][]int, len(section.Items))
+		for _, item := range section.Items {
+			doc.Citations = append(doc.Citations, Citation{Text: item, Link: "[[Board#^abc123]]"})
+			section.Citations[item] = []int{len(doc.Citations)}
+		}
+	}
+	return doc
+}
+
+func BenchmarkRender(b *testing.B) {
+	doc := giantDocument(10000)
+	for _, format := range []string{"md", "rst", "adoc", "text", "slack"} {
+		renderer, err := LookupRenderer(format)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(format, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				renderer.Render(doc)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+var slackMarkup = markup{
+	heading: func(level int, title string) string {
+		return "*" + title + "*\n\n"
+	},
+	bold:      func(text string) string { return "*" + text + "*" },
+	bullet:    "• ",
+	nested:    "    ◦ ",
+	listIntro: "\n",
+	footnote: func(n int, citation Citation) (string, string) {
+		return fmt.Sprintf(" [%d]", n), fmt.Sprintf("[%d] %s\n", n, citation.Text)
+	},
+}
+
+// RenderSlack renders doc in Slack's mrkdwn, e.g. for pasting into a
+// channel. Markdown in summaries, item titles, notes, and manual sections is
+// converted to mrkdwn.
+func RenderSlack(doc *Document) string {
+	return convertLines(renderText(doc, slackMarkup), slackLine)
+}
+
+// SlackSections converts a markdown worklog to mrkdwn, split into a part per
+// heading: the title, every category, and each appendix, so that they can be
+// posted as sections of their own. The heading is the first line of a part.
+func SlackSections(markdown string) []string {
+	var sections []string
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		if headingPattern.MatchString(strings.TrimRight(line, "\n")) && strings.TrimSpace(sb.String()) != "" {
+			sections = append(sections, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		}
+		sb.WriteString(slackLine(line))
+	}
+	if strings.TrimSpace(sb.String()) != "" {
+		sections = append(sections, strings.TrimSpace(sb.String()))
+	}
+	return sections
+}
+
+// slackEscaper escapes the characters mrkdwn uses for links and mentions.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackLine converts a line of markdown to mrkdwn: headings and bold text
+// become bold, list items bullets, and links Slack links; comments are
+// removed.
+func slackLine(line string) string {
+	text := strings.TrimRight(line, "\n")
+	newline := line[len(text):]
+
+	text = commentPattern.ReplaceAllString(text, "")
+	if strings.TrimSpace(text) == "" && strings.TrimSpace(line) != "" {
+		// A line of nothing but a comment, such as a manual section's marker.
+		return ""
+	}
+	text = slackEscaper.Replace(text)
+	text = imagePattern.ReplaceAllString(text, "$1")
+	text = mdLinkPattern.ReplaceAllString(text, "<$2|$1>")
+	text = replaceWikiLinks(text)
+	text = emphasisPattern.ReplaceAllStringFunc(text, func(emphasis string) string {
+		m := emphasisPattern.FindStringSubmatch(emphasis)
+		switch m[1] {
+		case "**", "__":
+			return "*" + m[2] + "*"
+		case "~~":
+			return "~" + m[2] + "~"
+		}
+		return m[2]
+	})
+
+	if m := headingPattern.FindStringSubmatch(text); m != nil {
+		return "*" + m[1] + "*" + newline
+	}
+	if m := listPattern.FindStringSubmatch(text); m != nil {
+		bullet := slackMarkup.bullet
+		if m[1] != "" {
+			bullet = slackMarkup.nested
+		}
+		text = bullet + text[len(m[0]):]
+	}
+	return text + newline
+}
@@ -0,0 +1,101 @@
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var textMarkup = markup{
+	heading: func(level int, title string) string {
+		switch level {
+		case 0, 1, 2:
+			return textHeading(strings.ToUpper(title), '=')
+		case 3:
+			return textHeading(title, '-')
+		default:
+			return title + ":\n\n"
+		}
+	},
+	bold: func(text string) string { return text },
+	// Bullets are indented along with those of manual sections by plainLine.
+	bullet:    "- ",
+	nested:    "  - ",
+	listIntro: "\n",
+	footnote: func(n int, citation Citation) (string, string) {
+		return fmt.Sprintf(" [%d]", n), fmt.Sprintf("[%d] %s\n", n, citation.Text)
+	},
+}
+
+// textHeading underlines title, so headings stand out without markup.
+func textHeading(title string, underline rune) string {
+	return fmt.Sprintf("%s\n%s\n\n", title, strings.Repeat(string(underline), len([]rune(title))))
+}
+
+// RenderText renders doc as plain text without any markup, e.g. for pasting
+// into an email or a status report form. Markdown in summaries, item titles,
+// notes, and manual sections is reduced to its text.
+func RenderText(doc *Document) string {
+	return convertLines(renderText(doc, textMarkup), plainLine)
+}
+
+var (
+	headingPattern  = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	listPattern     = regexp.MustCompile(`^(\s*)[-*+]\s+(?:\[.\]\s+)?`)
+	imagePattern    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkPattern   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	wikiLinkPattern = regexp.MustCompile(`!?\[\[(?:[^\]|]*\|)?([^\]]*)\]\]`)
+	commentPattern  = regexp.MustCompile(`<!--.*?-->`)
+	emphasisPattern = regexp.MustCompile(`(\*\*|__|~~|==)(\S(?:.*?\S)?)(\*\*|__|~~|==)`)
+	italicPattern   = regexp.MustCompile(`(^|[^\w*])\*(\S(?:[^*]*\S)?)\*`)
+)
+
+// plainLine strips the markdown syntax from a line of text: headings are
+// underlined, list items indented, links replaced by their text and URL,
+// and emphasis, code spans, and comments removed.
+func plainLine(line string) string {
+	text := strings.TrimRight(line, "\n")
+	newline := line[len(text):]
+	if strings.Trim(text, "=-") == "" {
+		// Blank lines and the underlines of headings.
+		return line
+	}
+
+	text = commentPattern.ReplaceAllString(text, "")
+	text = imagePattern.ReplaceAllString(text, "$1")
+	text = mdLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		m := mdLinkPattern.FindStringSubmatch(link)
+		if m[1] == m[2] {
+			return m[1]
+		}
+		return fmt.Sprintf("%s (%s)", m[1], m[2])
+	})
+	text = replaceWikiLinks(text)
+	text = emphasisPattern.ReplaceAllString(text, "$2")
+	text = italicPattern.ReplaceAllString(text, "$1$2")
+	text = strings.ReplaceAll(text, "`", "")
+
+	if m := headingPattern.FindStringSubmatch(text); m != nil {
+		return m[1] + "\n" + strings.Repeat("-", len([]rune(m[1]))) + newline
+	}
+	if m := listPattern.FindStringSubmatch(text); m != nil {
+		text = "  " + m[1] + "- " + text[len(m[0]):]
+	}
+	if strings.TrimSpace(text) == "" && strings.TrimSpace(line) != "" {
+		// A line of nothing but a comment, such as a manual section's marker.
+		return ""
+	}
+	return text + newline
+}
+
+// replaceWikiLinks replaces the Obsidian links in text with their alias or
+// the name of the note they link to.
+func replaceWikiLinks(text string) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		target := wikiLinkPattern.FindStringSubmatch(link)[1]
+		if i := strings.IndexAny(target, "#^"); i > 0 {
+			target = target[:i]
+		}
+		return target
+	})
+}
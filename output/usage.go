@@ -0,0 +1,55 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelUsage is the token usage of one model in the run that generated a
+// document, with its estimated cost in US dollars. Priced is false when the
+// price of the model is unknown.
+type ModelUsage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64
+	Priced           bool
+}
+
+// Summary describes the usage, e.g. "1200 prompt + 300 completion tokens,
+// about $0.0004".
+func (u ModelUsage) Summary() string {
+	summary := fmt.Sprintf("%d prompt + %d completion tokens", u.PromptTokens, u.CompletionTokens)
+	if !u.Priced {
+		return summary + ", price unknown"
+	}
+	return summary + fmt.Sprintf(", about $%.4f", u.CostUSD)
+}
+
+// ParseModelUsage reads the usage of a model back from its Summary, as
+// rendered in a worklog.
+func ParseModelUsage(model string, summary string) (ModelUsage, bool) {
+	usage := ModelUsage{Model: model}
+	if _, err := fmt.Sscanf(summary, "%d prompt + %d completion tokens", &usage.PromptTokens, &usage.CompletionTokens); err != nil {
+		return ModelUsage{}, false
+	}
+	if _, cost, ok := strings.Cut(summary, ", about $"); ok {
+		if _, err := fmt.Sscanf(cost, "%g", &usage.CostUSD); err == nil {
+			usage.Priced = true
+		}
+	}
+	return usage, true
+}
+
+// TotalUsage sums the usage of all models; its cost only counts the priced
+// ones, and it is priced if any of them is.
+func TotalUsage(usage []ModelUsage) ModelUsage {
+	total := ModelUsage{Model: "Total"}
+	for _, u := range usage {
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+		total.CostUSD += u.CostUSD
+		total.Priced = total.Priced || u.Priced
+	}
+	return total
+}
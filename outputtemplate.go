@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"text/template"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// templatedRenderer renders worklogs with the text/template file at path
+// instead of the fixed layout of base, whose format and file extension it
+// keeps. The template receives the same data as webhook payload templates,
+// with the worklog in the layout of base as .Content, so a template can also
+// just add a header or footer around it.
+func templatedRenderer(path string, base output.Renderer) (output.Renderer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return output.Renderer{}, fmt.Errorf("failed to read output template: %w", err)
+	}
+	tmpl, err := template.New("worklog").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return output.Renderer{}, fmt.Errorf("failed to parse output template %s: %w", path, err)
+	}
+
+	render := func(doc *output.Document) string {
+		content := base.Render(doc)
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, newWebhookPayload(&Report{Doc: doc, Format: base.Format, Content: content})); err != nil {
+			log.Printf("WARNING: Failed to render output template %s, using the default layout: %v", path, err)
+			return content
+		}
+		return buf.String()
+	}
+	return output.Renderer{Format: base.Format, Extension: base.Extension, Render: render, Convert: base.Convert}, nil
+}
+
+// orderSections returns the sections of the given categories first, in the
+// order given, followed by the others in their usual order.
+func orderSections(sections []webhookSection, categories ...string) []webhookSection {
+	rank := func(section webhookSection) int {
+		if i := slices.Index(categories, section.Category); i >= 0 {
+			return i
+		}
+		return len(categories)
+	}
+	ordered := slices.Clone(sections)
+	slices.SortStableFunc(ordered, func(a, b webhookSection) int {
+		return rank(a) - rank(b)
+	})
+	return ordered
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// OverloadConfig sets when a week counts as unusually heavy compared to the
+// average of the previous weeks in the run history. Zero values use the
+// defaults.
+type OverloadConfig struct {
+	// BaselineWeeks is the number of previous weeks averaged (default 8).
+	BaselineWeeks int `yaml:"baseline_weeks"`
+	// ItemsRatio, LateNightRatio, and IncidentRatio are how many times the
+	// average the week's items, late-night completions, and incident items
+	// may reach before a warning (defaults 1.5, 2, and 2).
+	ItemsRatio     float64 `yaml:"items_ratio"`
+	LateNightRatio float64 `yaml:"late_night_ratio"`
+	IncidentRatio  float64 `yaml:"incident_ratio"`
+}
+
+const (
+	// minBaselineWeeks is the history needed for a meaningful average.
+	minBaselineWeeks = 3
+	// Late-night and incident counts below these never warn, as an average
+	// close to zero would otherwise make a single one an overload.
+	minLateNightWarning = 2
+	minIncidentWarning  = 2
+)
+
+func (c OverloadConfig) withDefaults() OverloadConfig {
+	if c.BaselineWeeks <= 0 {
+		c.BaselineWeeks = 8
+	}
+	if c.ItemsRatio <= 0 {
+		c.ItemsRatio = 1.5
+	}
+	if c.LateNightRatio <= 0 {
+		c.LateNightRatio = 2
+	}
+	if c.IncidentRatio <= 0 {
+		c.IncidentRatio = 2
+	}
+	return c
+}
+
+// incidentTags mark cards about incidents.
+var incidentTags = []string{"incident", "outage", "sev0", "sev1", "sev2", "hotfix", "oncall"}
+
+// weekLoad is what overload is judged by.
+type weekLoad struct {
+	Items     int
+	LateNight int
+	Incidents int
+}
+
+// measureLoad computes the load of a week's card titles. Completion times
+// are read from the titles, so past weeks can be measured from the history.
+func measureLoad(titles []string) weekLoad {
+	load := weekLoad{Items: len(titles)}
+	for _, title := range titles {
+		if date, ok := worklog.CompletionDate(title); ok && output.IsLateNight(date) {
+			load.LateNight++
+		}
+		if slices.ContainsFunc(worklog.ExtractTags(title), func(tag string) bool { return slices.Contains(incidentTags, tag) }) {
+			load.Incidents++
+		}
+	}
+	return load
+}
+
+// baselineLoads returns the loads of up to weeks weeks before the given one.
+func baselineLoads(records []HistoryRecord, year int, week int, weeks int) []weekLoad {
+	var loads []weekLoad
+	for i := len(records) - 1; i >= 0 && len(loads) < weeks; i-- {
+		record := records[i]
+		if record.Year > year || record.Year == year && record.Week >= week {
+			continue
+		}
+		titles := make([]string, len(record.Items))
+		for j, item := range record.Items {
+			titles[j] = item.Title
+		}
+		loads = append(loads, measureLoad(titles))
+	}
+	return loads
+}
+
+// overloadSignals compares the week's load to the average of the baseline
+// and describes every measure above its threshold. It returns nothing when
+// the baseline is too short to judge.
+func overloadSignals(current weekLoad, baseline []weekLoad, cfg OverloadConfig) []string {
+	if len(baseline) < minBaselineWeeks {
+		return nil
+	}
+	cfg = cfg.withDefaults()
+
+	var items, lateNight, incidents float64
+	for _, load := range baseline {
+		items += float64(load.Items)
+		lateNight += float64(load.LateNight)
+		incidents += float64(load.Incidents)
+	}
+	n := float64(len(baseline))
+	items, lateNight, incidents = items/n, lateNight/n, incidents/n
+
+	var signals []string
+	if items > 0 && float64(current.Items) > cfg.ItemsRatio*items {
+		signals = append(signals, fmt.Sprintf("%d items, %.1f× your average of %.1f", current.Items, float64(current.Items)/items, items))
+	}
+	if current.LateNight >= minLateNightWarning && float64(current.LateNight) > cfg.LateNightRatio*lateNight {
+		signals = append(signals, fmt.Sprintf("%d late-night completions (average %.1f)", current.LateNight, lateNight))
+	}
+	if current.Incidents >= minIncidentWarning && float64(current.Incidents) > cfg.IncidentRatio*incidents {
+		signals = append(signals, fmt.Sprintf("%d incident items (average %.1f)", current.Incidents, incidents))
+	}
+	return signals
+}
+
+// overloadNote is the gentle note added to the self-review section.
+func overloadNote(signals []string) string {
+	return fmt.Sprintf("This week was heavier than usual: %s. That is worth noticing; consider protecting some recovery time next week or sharing the load.", strings.Join(signals, "; "))
+}
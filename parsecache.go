@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// parseCacheFile holds the items extracted from the board in the last run,
+// so runs over a board that hasn't changed since, such as those triggered on
+// every save, skip parsing a potentially huge board again.
+const parseCacheFile = "parsed.json"
+
+// parseCacheVersion is part of the cache key and changes whenever the
+// extraction does, so items extracted by an older version aren't reused.
+const parseCacheVersion = 3
+
+// parseCache is the extraction of one version of the board.
+type parseCache struct {
+	Hash    string         `json:"hash"`
+	Columns []string       `json:"columns"`
+	Items   []worklog.Item `json:"items"`
+	// Excluded are the list items the extraction left out, reported again
+	// when the cache is used.
+	Excluded []parseExclusion `json:"excluded,omitempty"`
+}
+
+// parseExclusion is a list item left out of the extraction and why.
+type parseExclusion struct {
+	Text   string `json:"text"`
+	Reason string `json:"reason"`
+}
+
+// boardHash identifies the content of the board for the parse cache.
+func boardHash(content string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s", parseCacheVersion, content)))
+	return fmt.Sprintf("%x", sum)
+}
+
+// extractColumnsCached returns the items of columns like extractColumns, but
+// takes them from the parse cache in stateDir when the board's content and
+// the columns are the same as in the last run, reporting the list items left
+// out to excluded as a fresh extraction does. A fresh extraction replaces
+// the cache unless write is false, e.g. in a dry run. Failing to read or
+// write the cache only costs the time of parsing the board.
+func extractColumnsCached(stateDir string, content string, columns []string, write bool, excluded func(text string, reason string)) ([]worklog.Item, bool, error) {
+	path := filepath.Join(stateDir, parseCacheFile)
+	hash := boardHash(content)
+	if data, err := os.ReadFile(path); err == nil {
+		var cached parseCache
+		if json.Unmarshal(data, &cached) == nil && cached.Hash == hash && slices.Equal(cached.Columns, columns) {
+			if excluded != nil {
+				for _, exclusion := range cached.Excluded {
+					excluded(exclusion.Text, exclusion.Reason)
+				}
+			}
+			return cached.Items, true, nil
+		}
+	}
+
+	var exclusions []parseExclusion
+	items, err := extractColumns(content, columns, func(text string, reason string) {
+		exclusions = append(exclusions, parseExclusion{Text: text, Reason: reason})
+		if excluded != nil {
+			excluded(text, reason)
+		}
+	})
+	if err != nil || !write {
+		return items, false, err
+	}
+	data, err := json.Marshal(parseCache{Hash: hash, Columns: columns, Items: items, Excluded: exclusions})
+	if err == nil && os.MkdirAll(stateDir, 0755) == nil {
+		writeFileAtomic(path, data)
+	}
+	return items, false, nil
+}
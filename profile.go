@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profileOptions holds the flags writing pprof profiles of a run, for
+// finding out where time and memory go on giant boards.
+type profileOptions struct {
+	cpu    string
+	memory string
+}
+
+func registerProfileFlags(fs *flag.FlagSet) *profileOptions {
+	opts := &profileOptions{}
+	fs.StringVar(&opts.cpu, "cpuprofile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	fs.StringVar(&opts.memory, "memprofile", "", "Write a heap profile at the end of the run to this file, for go tool pprof")
+	return opts
+}
+
+// start begins CPU profiling and returns a function that stops it and writes
+// the heap profile. The profiles are only complete once that function ran.
+func (o *profileOptions) start() (func(), error) {
+	var cpuFile *os.File
+	if o.cpu != "" {
+		f, err := os.Create(o.cpu)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+			cpuFile = nil
+		}
+		if o.memory == "" {
+			return
+		}
+		f, err := os.Create(o.memory)
+		if err != nil {
+			log.Printf("WARNING: Failed to create memory profile: %v", err)
+			return
+		}
+		defer f.Close()
+		// Collect garbage first, so the profile shows what is still in use.
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Printf("WARNING: Failed to write memory profile: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,12 @@
+package main
+
+// comparisonPrompt asks for commentary on how a week's work compares to a
+// previous week's.
+const comparisonPrompt = `Compare a software engineer's completed work this week to week %d of %d and write one short paragraph of commentary on the trends, such as "Bug load doubled; feature work paused for the incident."
+Mention notable changes in the number of items per category and what explains them, based on the items. Don't list the items, and don't make up reasons the items don't support.
+
+Previous week:
+%s
+This week:
+%s
+Respond with the paragraph only.`
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// runPublish implements the publish subcommand, which delivers an existing,
+// possibly hand-edited, worklog to the configured sinks. It decouples
+// distribution from generation and is the second step of --draft mode.
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	currentYear, currentWeek := time.Now().ISOWeek()
+	file := fs.String("file", "", "Path of the worklog file to publish (overrides --week, --year and --output-folder)")
+	week := fs.Int("week", currentWeek, "ISO week of the worklog to publish")
+	year := fs.Int("year", currentYear, "Year of the worklog to publish")
+	outputFolder := fs.String("output-folder", "", "Folder containing the generated worklog")
+	format := fs.String("format", "md", "Format of the worklog file: md, rst, adoc, text, or slack")
+	to := fs.String("to", "", "Comma-separated sink names to publish to (default: all configured sinks)")
+	configPath := fs.String("config", "", "Path to a YAML config file (default: worklog.yaml in the vault root, the Obsidian plugin's settings in the vault, or ~/.config/worklog-gen/config.yaml)")
+	vault := fs.String("vault", "", "Obsidian vault whose worklog-gen plugin settings to use (default: the vault containing the worklog)")
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory for the run history and other state, locked while publishing")
+	force := fs.Bool("force", false, "Publish even if the state directory is locked by another run, e.g. one that crashed")
+	sinkOpts := registerSinkFlags(fs)
+	fs.Parse(args)
+
+	near := *outputFolder
+	if *file != "" {
+		near = filepath.Dir(*file)
+	}
+	cfg, err := loadConfig(resolveConfigPath(*configPath, *vault, near))
+	if err != nil {
+		return err
+	}
+	if err := cfg.applyFlags(fs, false); err != nil {
+		return err
+	}
+
+	if *file == "" && *outputFolder == "" {
+		return fmt.Errorf("either the file or the output-folder flag is required")
+	}
+
+	path := *file
+	if path != "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+		// Keep the week and year in sync with the file for non-markdown
+		// formats, which aren't parsed back into a document.
+		fmt.Sscanf(filepath.Base(path), "worklog-week-%d-%d", week, year)
+	}
+
+	if !worklog.ValidWeek(*year, *week) {
+		return fmt.Errorf("%d has no week %d", *year, *week)
+	}
+
+	outputRenderer, err := output.LookupRenderer(*format)
+	if err != nil {
+		return err
+	}
+
+	configSecrets = secretStore{values: cfg.Secrets, stateDir: *stateDir}
+	sinkOpts.copies = cfg.Copies
+	sinkOpts.modelPrices = cfg.ModelPrices
+	sinks, err := sinkOpts.build()
+	if err != nil {
+		return err
+	}
+	sinks, err = selectSinks(sinks, *to)
+	if err != nil {
+		return err
+	}
+	if len(sinks) == 0 {
+		return fmt.Errorf("no sinks configured, nothing to publish to")
+	}
+	if outputRenderer.Format != "md" {
+		// Only markdown worklogs are read back into a document, which every
+		// sink but the copies delivers; they would get an empty one.
+		for _, sink := range sinks {
+			if _, ok := sink.(*copySink); !ok {
+				return fmt.Errorf("the %s sink can only publish markdown worklogs, not %s; publish the .md file or use --to to pick copies only", sink.Name(), outputRenderer.Format)
+			}
+		}
+	}
+
+	release, err := acquireLock(*stateDir, "publish", *force)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if path == "" {
+		path = worklogFilename(*outputFolder, *year, *week, outputRenderer.Extension)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read worklog: %w", err)
+	}
+
+	report := &Report{
+		Doc:     &output.Document{Year: *year, Week: *week},
+		Format:  outputRenderer.Format,
+		Content: string(data),
+	}
+	if outputRenderer.Format == "md" {
+		doc, err := parseWorklog(report.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse worklog %s: %w", path, err)
+		}
+		report.Doc = doc
+	}
+
+	if sinkOpts.anonymize {
+		report = anonymizeReport(report, cfg.Anonymize)
+	}
+
+	results, err := deliver(context.Background(), sinks, report)
+	if queueErr := queueFailures(*stateDir, results, report); queueErr != nil {
+		if err != nil {
+			return fmt.Errorf("failed to deliver worklog: %w (and failed to queue it: %v)", err, queueErr)
+		}
+		log.Printf("WARNING: %v", queueErr)
+	}
+	if err != nil {
+		// Queued like the failures of a run, for the flush subcommand.
+		return fmt.Errorf("failed to deliver worklog, queued for flush: %w", err)
+	}
+
+	log.Printf("SUCCESS: Published %s to %d sink(s)", path, len(sinks))
+	return nil
+}
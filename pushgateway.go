@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// pushgatewaySink pushes metrics of the worklog to a Prometheus Pushgateway,
+// so the distribution of work and the LLM spend can be charted over time.
+// Each week is its own group, labeled with its year and week, and pushing a
+// week again replaces its metrics.
+type pushgatewaySink struct {
+	url    string
+	job    string
+	prices map[string]modelPrice
+}
+
+func (s *pushgatewaySink) Name() string {
+	return "pushgateway"
+}
+
+// Send pushes the items per category, and the tokens used and their cost per
+// model when the run called the LLM. Runs that don't know them, such as
+// publish without LLM calls or with a summarized worklog read back from its
+// file, leave those metrics of the week as they are, since a push only
+// replaces the metrics it contains.
+func (s *pushgatewaySink) Send(ctx context.Context, report *Report) error {
+	var sb strings.Builder
+	if items, ok := itemCounts(report.Doc); ok {
+		sb.WriteString("# HELP worklog_items Items completed in the week, per category.\n# TYPE worklog_items gauge\n")
+		for _, category := range slices.Sorted(maps.Keys(items)) {
+			fmt.Fprintf(&sb, "worklog_items{category=\"%s\"} %d\n", promLabel(category), items[category])
+		}
+	} else {
+		log.Printf("INFO: Not pushing the items per category, which the summaries of the worklog don't tell")
+	}
+
+	usage := report.Doc.Usage
+	if len(usage) == 0 {
+		usage = runUsage(s.prices)
+	}
+	if len(usage) > 0 {
+		sb.WriteString("# HELP worklog_tokens Tokens used to generate the worklog, per model.\n# TYPE worklog_tokens gauge\n")
+		for _, u := range usage {
+			fmt.Fprintf(&sb, "worklog_tokens{model=\"%s\",type=\"prompt\"} %d\n", promLabel(u.Model), u.PromptTokens)
+			fmt.Fprintf(&sb, "worklog_tokens{model=\"%s\",type=\"completion\"} %d\n", promLabel(u.Model), u.CompletionTokens)
+		}
+		sb.WriteString("# HELP worklog_cost_usd Estimated cost of generating the worklog in US dollars, per model.\n# TYPE worklog_cost_usd gauge\n")
+		for _, u := range usage {
+			fmt.Fprintf(&sb, "worklog_cost_usd{model=\"%s\"} %g\n", promLabel(u.Model), u.CostUSD)
+		}
+	}
+	sb.WriteString("# HELP worklog_pushed_timestamp_seconds When the worklog's metrics were last pushed.\n# TYPE worklog_pushed_timestamp_seconds gauge\n")
+	fmt.Fprintf(&sb, "worklog_pushed_timestamp_seconds %d\n", time.Now().Unix())
+
+	endpoint := fmt.Sprintf("%s/metrics/job/%s/year/%d/week/%d",
+		strings.TrimRight(s.url, "/"), url.PathEscape(s.job), report.Doc.Year, report.Doc.Week)
+	return sendRequest(ctx, http.MethodPost, endpoint, "text/plain; version=0.0.4", []byte(sb.String()), nil)
+}
+
+// itemCounts returns the number of items per category of doc. They are
+// unknown for summarized sections read back from a worklog file, which don't
+// list their items. Sections written by hand, without items of the week,
+// don't count.
+func itemCounts(doc *output.Document) (map[string]int, bool) {
+	items := make(map[string]int)
+	for _, section := range doc.Sections {
+		count := section.ItemCount
+		if count == 0 && section.Manual != "" {
+			continue
+		}
+		if count == 0 {
+			if section.Summary != "" || len(section.KeyPoints) > 0 {
+				return nil, false
+			}
+			count = len(section.Items)
+		}
+		items[section.Category] += count
+	}
+	return items, true
+}
+
+// promLabel escapes a label value for the Prometheus text format.
+func promLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
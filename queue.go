@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// queueDir holds the deliveries that failed, in the state directory, until
+// a later run or the flush subcommand delivers them.
+const queueDir = "queue"
+
+// queuedDelivery is a report waiting to be delivered to one sink. The report
+// is stored as delivered, so templates and anonymization configured for the
+// sink still apply on retry.
+type queuedDelivery struct {
+	Sink      string    `json:"sink"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	Report    *Report   `json:"report"`
+
+	path string
+}
+
+// queuePath names the queue entry of a sink and week, so a newer worklog of
+// the same week replaces one still waiting.
+func queuePath(stateDir string, sink string, year int, week int) string {
+	return filepath.Join(stateDir, queueDir, fmt.Sprintf("%s-%d-W%02d.json", sink, year, week))
+}
+
+// queueDelivery stores a report whose delivery to sink failed with err.
+func queueDelivery(stateDir string, sink string, report *Report, err error) error {
+	if mkErr := os.MkdirAll(filepath.Join(stateDir, queueDir), 0755); mkErr != nil {
+		return fmt.Errorf("failed to create queue folder: %w", mkErr)
+	}
+	entry := &queuedDelivery{
+		Sink:      sink,
+		QueuedAt:  time.Now(),
+		Attempts:  1,
+		LastError: err.Error(),
+		Report:    report,
+		path:      queuePath(stateDir, sink, report.Doc.Year, report.Doc.Week),
+	}
+	return entry.save()
+}
+
+func (q *queuedDelivery) save() error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode queued delivery: %w", err)
+	}
+	if err := writeFileAtomic(q.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write queued delivery: %w", err)
+	}
+	return nil
+}
+
+// dequeue removes the queue entry of a sink and week, if any, e.g. because a
+// newer version of the worklog was just delivered.
+func dequeue(stateDir string, sink string, year int, week int) error {
+	err := os.Remove(queuePath(stateDir, sink, year, week))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove queued delivery: %w", err)
+	}
+	return nil
+}
+
+// loadQueue returns the queued deliveries, oldest first. A missing queue is
+// not an error.
+func loadQueue(stateDir string) ([]*queuedDelivery, error) {
+	paths, err := filepath.Glob(filepath.Join(stateDir, queueDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []*queuedDelivery
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued delivery: %w", err)
+		}
+		entry := &queuedDelivery{path: path}
+		if err := json.Unmarshal(data, entry); err != nil || entry.Report == nil || entry.Report.Doc == nil {
+			log.Printf("WARNING: Ignoring unreadable queued delivery %s", path)
+			continue
+		}
+		queue = append(queue, entry)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].QueuedAt.Before(queue[j].QueuedAt)
+	})
+	return queue, nil
+}
+
+// queueFailures queues the report for every sink that failed to take it and
+// drops older queued versions for the sinks that did.
+func queueFailures(stateDir string, results []sinkResult, report *Report) error {
+	for _, result := range results {
+		if result.Err == nil {
+			if err := dequeue(stateDir, result.Name, report.Doc.Year, report.Doc.Week); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := queueDelivery(stateDir, result.Name, report, result.Err); err != nil {
+			return err
+		}
+		log.Printf("WARNING: Delivery to %s failed and was queued for the next run: %v", result.Name, result.Err)
+	}
+	return nil
+}
+
+// flushQueue retries the queued deliveries to the given sinks. Deliveries to
+// sinks that are not configured stay queued. It returns how many were
+// delivered and how many are still queued.
+func flushQueue(ctx context.Context, stateDir string, sinks []Sink) (int, int, error) {
+	queue, err := loadQueue(stateDir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	available := make(map[string]Sink, len(sinks))
+	for _, sink := range sinks {
+		available[sink.Name()] = sink
+	}
+
+	delivered, pending := 0, 0
+	for _, entry := range queue {
+		sink, ok := available[entry.Sink]
+		if !ok {
+			pending++
+			continue
+		}
+		log.Printf("INFO: Retrying delivery of week %d %d to %s, queued %s", entry.Report.Doc.Week, entry.Report.Doc.Year, entry.Sink, entry.QueuedAt.Format(time.RFC3339))
+		if err := sink.Send(ctx, entry.Report); err != nil {
+			entry.Attempts++
+			entry.LastError = err.Error()
+			if saveErr := entry.save(); saveErr != nil {
+				return delivered, pending, saveErr
+			}
+			log.Printf("WARNING: Delivery to %s failed again (attempt %d): %v", entry.Sink, entry.Attempts, err)
+			pending++
+			continue
+		}
+		if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+			return delivered, pending, fmt.Errorf("failed to remove queued delivery: %w", err)
+		}
+		delivered++
+	}
+	return delivered, pending, nil
+}
+
+// runFlush implements the flush subcommand, which retries the deliveries
+// queued by earlier runs without generating a worklog.
+func runFlush(args []string) error {
+	fs := flag.NewFlagSet("flush", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to a YAML config file (default: worklog.yaml in the vault root, the Obsidian plugin's settings in the vault, or ~/.config/worklog-gen/config.yaml)")
+	vault := fs.String("vault", "", "Obsidian vault whose worklog-gen plugin settings to use")
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory for the run history and other state")
+	force := fs.Bool("force", false, "Flush even if the state directory is locked by another run, e.g. one that crashed")
+	sinkOpts := registerSinkFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := loadConfig(resolveConfigPath(*configPath, *vault, ""))
+	if err != nil {
+		return err
+	}
+	if err := cfg.applyFlags(fs, false); err != nil {
+		return err
+	}
+
+	configSecrets = secretStore{values: cfg.Secrets, stateDir: *stateDir}
+	sinkOpts.copies = cfg.Copies
+	sinkOpts.modelPrices = cfg.ModelPrices
+	sinks, err := sinkOpts.build()
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireLock(*stateDir, "flush", *force)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	delivered, pending, err := flushQueue(context.Background(), *stateDir, sinks)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		queue, _ := loadQueue(*stateDir)
+		var waiting []string
+		for _, entry := range queue {
+			waiting = append(waiting, fmt.Sprintf("%s (week %d %d)", entry.Sink, entry.Report.Doc.Week, entry.Report.Doc.Year))
+		}
+		return fmt.Errorf("delivered %d, %d still queued: %s", delivered, pending, strings.Join(waiting, ", "))
+	}
+	log.Printf("SUCCESS: Delivered %d queued %s", delivered, worklog.Pluralize(delivered, "report", "reports"))
+	return nil
+}
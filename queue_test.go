@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// fakeSink records the reports it receives and fails while err is set.
+type fakeSink struct {
+	name     string
+	err      error
+	received []*Report
+}
+
+func (s *fakeSink) Name() string {
+	return s.name
+}
+
+func (s *fakeSink) Send(ctx context.Context, report *Report) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.received = append(s.received, report)
+	return nil
+}
+
+// TestQueueAndFlush follows a delivery that fails for one sink: it is
+// queued for that sink only, stays queued while the sink is down or not
+// configured, and is delivered and removed once the sink is back.
+func TestQueueAndFlush(t *testing.T) {
+	stateDir := t.TempDir()
+	report := &Report{Doc: &output.Document{Year: 2026, Week: 42}, Format: "md", Content: "## Week 42 2026\n"}
+	matrix := &fakeSink{name: "matrix", err: errors.New("502 Bad Gateway")}
+	slack := &fakeSink{name: "slack"}
+
+	results, err := deliver(context.Background(), []Sink{matrix, slack}, report)
+	if err == nil {
+		t.Fatal("deliver() succeeded although matrix is down")
+	}
+	if err := queueFailures(stateDir, results, report); err != nil {
+		t.Fatal(err)
+	}
+	queue, err := loadQueue(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].Sink != "matrix" || queue[0].LastError != "502 Bad Gateway" {
+		t.Fatalf("queue = %+v, want the delivery to matrix", queue)
+	}
+
+	if delivered, pending, err := flushQueue(context.Background(), stateDir, []Sink{slack}); err != nil || delivered != 0 || pending != 1 {
+		t.Errorf("flushing without matrix = %d delivered, %d pending, %v; want 0, 1", delivered, pending, err)
+	}
+	if delivered, pending, err := flushQueue(context.Background(), stateDir, []Sink{matrix}); err != nil || delivered != 0 || pending != 1 {
+		t.Errorf("flushing while matrix is down = %d delivered, %d pending, %v; want 0, 1", delivered, pending, err)
+	}
+	if queue, _ := loadQueue(stateDir); len(queue) != 1 || queue[0].Attempts != 2 {
+		t.Errorf("queue after a failed retry = %+v, want 2 attempts", queue)
+	}
+
+	matrix.err = nil
+	if delivered, pending, err := flushQueue(context.Background(), stateDir, []Sink{matrix}); err != nil || delivered != 1 || pending != 0 {
+		t.Errorf("flushing once matrix is up = %d delivered, %d pending, %v; want 1, 0", delivered, pending, err)
+	}
+	if len(matrix.received) != 1 || matrix.received[0].Content != report.Content {
+		t.Errorf("matrix received %+v, want the queued report", matrix.received)
+	}
+	if queue, _ := loadQueue(stateDir); len(queue) != 0 {
+		t.Errorf("queue after delivery = %+v, want it empty", queue)
+	}
+}
+
+// TestQueueFailuresDropsDelivered checks that delivering a newer worklog of
+// a week drops the older one still queued for the sink.
+func TestQueueFailuresDropsDelivered(t *testing.T) {
+	stateDir := t.TempDir()
+	report := &Report{Doc: &output.Document{Year: 2026, Week: 42}, Format: "md"}
+	if err := queueDelivery(stateDir, "matrix", report, errors.New("timeout")); err != nil {
+		t.Fatal(err)
+	}
+	if err := queueFailures(stateDir, []sinkResult{{Name: "matrix"}}, report); err != nil {
+		t.Fatal(err)
+	}
+	if queue, _ := loadQueue(stateDir); len(queue) != 0 {
+		t.Errorf("queue = %+v, want it empty", queue)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/board"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// activityFile records when the columns of boards last changed, for remind.
+const activityFile = "board-activity.json"
+
+// columnActivity is the last known state of a board column.
+type columnActivity struct {
+	// Fingerprint is a hash of the column's cards.
+	Fingerprint string    `json:"fingerprint"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// runRemind implements the remind subcommand, which is meant to be scheduled
+// shortly before the weekly run. It warns when the column hasn't changed in
+// a while, so the board can be brought up to date before an empty worklog
+// is generated from it.
+func runRemind(args []string) error {
+	fs := flag.NewFlagSet("remind", flag.ExitOnError)
+	boardPath := fs.String("board", "", "Path to the Kanban board markdown file")
+	var columns listFlag
+	fs.Var(&columns, "column", "Column holding the completed cards; repeat it or give a comma-separated list to check several")
+	staleDays := fs.Int("stale-days", 3, "Remind when a column hasn't changed in this many days")
+	desktop := fs.Bool("desktop", false, "Also show the reminder as a desktop notification")
+	configPath := fs.String("config", "", "Path to a YAML config file (default: worklog.yaml in the vault root, the Obsidian plugin's settings in the vault, or ~/.config/worklog-gen/config.yaml)")
+	vault := fs.String("vault", "", "Obsidian vault whose worklog-gen plugin settings to use (default: the vault containing the board)")
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory for the run history and other state")
+	alertOpts := registerAlertFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := loadConfig(resolveConfigPath(*configPath, *vault, filepath.Dir(*boardPath)))
+	if err != nil {
+		return err
+	}
+	if err := cfg.applyFlags(fs, false); err != nil {
+		return err
+	}
+	if *boardPath == "" || len(columns) == 0 {
+		return fmt.Errorf("board and column flags are required")
+	}
+	if *staleDays < 1 {
+		return fmt.Errorf("--stale-days must be at least 1")
+	}
+
+	content, err := readBoard(*boardPath, "")
+	if err != nil {
+		return fmt.Errorf("failed to read board file: %w", err)
+	}
+	byColumn, err := board.ExtractColumns(content, board.Options{}, columns...)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(*boardPath)
+	if err != nil {
+		return err
+	}
+
+	activity, err := loadActivity(*stateDir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var stale []string
+	for _, column := range columns {
+		titles := make([]string, len(byColumn[column]))
+		latest := time.Time{}
+		for i, item := range byColumn[column] {
+			titles[i] = item.Title
+			if item.Date.After(latest) {
+				latest = item.Date
+			}
+		}
+		sum := sha256.Sum256([]byte(strings.Join(titles, "\n")))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		key := activityKey(*boardPath, column)
+		record, known := activity[key]
+		if !known || record.Fingerprint != fingerprint {
+			// The column changed since the last check, at the latest when
+			// the board was saved, or when its newest card was completed.
+			changedAt := info.ModTime()
+			if !latest.IsZero() && latest.Before(changedAt) {
+				changedAt = latest
+			}
+			record = columnActivity{Fingerprint: fingerprint, ChangedAt: changedAt}
+			activity[key] = record
+		}
+
+		days := int(now.Sub(record.ChangedAt).Hours() / 24)
+		if days < *staleDays {
+			log.Printf("INFO: Column '%s' changed %s", column, daysAgo(days))
+			continue
+		}
+		log.Printf("WARNING: Column '%s' hasn't changed in %d %s; update the board before the worklog is generated", column, days, worklog.Pluralize(days, "day", "days"))
+		stale = append(stale, fmt.Sprintf("'%s' in %d %s", column, days, worklog.Pluralize(days, "day", "days")))
+	}
+	if err := saveActivity(*stateDir, activity); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+
+	if len(stale) == 0 {
+		log.Printf("SUCCESS: The board is up to date")
+		return nil
+	}
+
+	subject := fmt.Sprintf("Update %s before the worklog is generated", filepath.Base(*boardPath))
+	message := fmt.Sprintf("No changes to %s. Move the finished cards there so the worklog isn't empty.", strings.Join(stale, " and "))
+	var errs []error
+	if err := alertOpts.send(context.Background(), subject, fmt.Sprintf(":memo: *%s*\n%s", subject, message), message); err != nil {
+		errs = append(errs, err)
+	}
+	if *desktop {
+		if err := notifyDesktop(subject, message); err != nil {
+			errs = append(errs, fmt.Errorf("desktop notification: %w", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to send the reminder: %w", err)
+	}
+	return nil
+}
+
+// daysAgo describes a number of days in the past.
+func daysAgo(days int) string {
+	switch days {
+	case 0:
+		return "today"
+	case 1:
+		return "yesterday"
+	}
+	return fmt.Sprintf("%d days ago", days)
+}
+
+// activityKey identifies a column of a board in the activity file.
+func activityKey(boardPath string, column string) string {
+	if abs, err := filepath.Abs(boardPath); err == nil {
+		boardPath = abs
+	}
+	return boardPath + "#" + column
+}
+
+func loadActivity(stateDir string) (map[string]columnActivity, error) {
+	activity := make(map[string]columnActivity)
+	data, err := os.ReadFile(filepath.Join(stateDir, activityFile))
+	if os.IsNotExist(err) {
+		return activity, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read board activity: %w", err)
+	}
+	if err := json.Unmarshal(data, &activity); err != nil {
+		return nil, fmt.Errorf("failed to parse board activity: %w", err)
+	}
+	return activity, nil
+}
+
+func saveActivity(stateDir string, activity map[string]columnActivity) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(activity, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode board activity: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(stateDir, activityFile), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write board activity: %w", err)
+	}
+	return nil
+}
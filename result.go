@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// runResult is the outcome of a generation run. In quiet mode it is the only
+// output, as a single line or as JSON.
+type runResult struct {
+	Worklog    string         `json:"worklog"`
+	Year       int            `json:"year"`
+	Week       int            `json:"week"`
+	Items      int            `json:"items"`
+	Categories map[string]int `json:"categories"`
+	Delivered  []string       `json:"delivered,omitempty"`
+	Draft      bool           `json:"draft,omitempty"`
+	Digest     string         `json:"digest,omitempty"`
+}
+
+func (r runResult) String() string {
+	s := fmt.Sprintf("%s: %d items in %d %s", r.Worklog, r.Items, len(r.Categories), worklog.Pluralize(len(r.Categories), "category", "categories"))
+	switch {
+	case r.Draft:
+		s += ", draft"
+	case len(r.Delivered) > 0:
+		s += ", delivered to " + strings.Join(r.Delivered, ", ")
+	}
+	return s
+}
+
+// quietWriter drops INFO and SUCCESS log lines, leaving warnings and errors.
+type quietWriter struct {
+	w io.Writer
+}
+
+func (q quietWriter) Write(p []byte) (int, error) {
+	for _, level := range []string{"INFO:", "SUCCESS:"} {
+		if bytes.Contains(p, []byte(log.Prefix()+level)) {
+			return len(p), nil
+		}
+	}
+	return q.w.Write(p)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// rollupFilename names the rollup note of a period, e.g.
+// worklog-month-2026-10.md, so it is not mistaken for a weekly worklog.
+func rollupFilename(outputFolder string, extension string, period worklog.Period) string {
+	return filepath.Join(outputFolder, fmt.Sprintf("worklog-%s.%s", period.Slug, extension))
+}
+
+// writeRollup summarizes the weekly worklogs in outputFolder that belong to
+// period into a note next to them in the format of renderer. Only worklogs
+// of that format are read, so a week generated in several formats counts
+// once. The cards the run history in stateDir records for several of the
+// weeks, such as a card that stayed in the Done column for another week,
+// are pointed out to the model, so the rollup doesn't count them twice.
+func writeRollup(outputFolder string, stateDir string, renderer output.Renderer, period worklog.Period, opts summarize.Options) (string, error) {
+	extension := renderer.Extension
+	files, err := listWorklogs(outputFolder)
+	if err != nil {
+		return "", err
+	}
+
+	found := make(map[[2]int]bool)
+	var worklogs []string
+	var weeks []string
+	// listWorklogs returns the newest week first.
+	for i := len(files) - 1; i >= 0; i-- {
+		file := files[i]
+		if file.Extension != extension || !period.Contains(file.Year, file.Week) {
+			continue
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read worklog: %w", err)
+		}
+		text, err := withoutRawSections(string(content), extension, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		worklogs = append(worklogs, strings.TrimSpace(text))
+		weeks = append(weeks, fmt.Sprint(file.Week))
+		found[[2]int{file.Year, file.Week}] = true
+	}
+	if len(worklogs) == 0 {
+		return "", fmt.Errorf("no .%s worklogs of %s found in %s", extension, period.Name, outputFolder)
+	}
+
+	var missing []string
+	for day := period.Start; day.Before(period.End) && day.Before(time.Now()); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Thursday {
+			continue
+		}
+		year, week := day.ISOWeek()
+		if !found[[2]int{year, week}] {
+			missing = append(missing, fmt.Sprint(week))
+		}
+	}
+	if len(missing) > 0 {
+		log.Printf("WARNING: No worklog found for %s %s of %s", worklog.Pluralize(len(missing), "week", "weeks"), strings.Join(missing, ", "), period.Name)
+	}
+
+	repeated, err := repeatedCards(stateDir, found, opts.Raw)
+	if err != nil {
+		log.Printf("WARNING: Failed to read the run history, so cards reported in several weeks may be counted more than once: %v", err)
+	} else if len(repeated) > 0 {
+		log.Printf("INFO: Asking to count %d %s reported in several weeks once", len(repeated), worklog.Pluralize(len(repeated), "card", "cards"))
+	}
+
+	log.Printf("INFO: Rolling up %d weekly %s into %s", len(worklogs), worklog.Pluralize(len(worklogs), "worklog", "worklogs"), period.Name)
+	body, err := summarize.Rollup(worklogs, repeated, period.Name, period.Kind, opts)
+	if err != nil {
+		return "", err
+	}
+
+	note := fmt.Sprintf("## %s\n\n_Rolled up from %s %s._\n\n%s\n", period.Name, worklog.Pluralize(len(weeks), "week", "weeks"), strings.Join(weeks, ", "), body)
+	filename, err := writeFileSafely(rollupFilename(outputFolder, extension, period), []byte(renderer.Convert(note)))
+	if err != nil {
+		return "", fmt.Errorf("failed to write rollup: %w", err)
+	}
+	return filename, nil
+}
+
+// withoutRawSections returns a weekly worklog without the sections of the raw
+// categories of opts, which must not reach the LLM. Only markdown worklogs
+// can be read back into their sections.
+func withoutRawSections(content string, extension string, opts summarize.Options) (string, error) {
+	if len(opts.RawCategories) == 0 {
+		return content, nil
+	}
+	if extension != "md" {
+		return "", fmt.Errorf("raw_categories can only be left out of markdown worklogs; roll up the .md worklogs instead")
+	}
+	doc, err := parseWorklog(content)
+	if err != nil {
+		return "", err
+	}
+	doc.Sections = slices.DeleteFunc(doc.Sections, func(section output.Section) bool {
+		return opts.Raw(section.Category)
+	})
+	return output.RenderMarkdown(doc), nil
+}
+
+// repeatedCards returns the cards the run history in stateDir records for
+// more than one of weeks, in the order they were first reported, with the
+// weeks reporting them, e.g. "Fix login (weeks 41, 42)". Cards are the same
+// when they have the same item ID. Cards of raw categories, which must not
+// reach the LLM, are left out.
+func repeatedCards(stateDir string, weeks map[[2]int]bool, raw func(category string) bool) ([]string, error) {
+	records, err := loadHistory(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	titles := make(map[string]string)
+	reported := make(map[string][]string)
+	for _, record := range records {
+		if !weeks[[2]int{record.Year, record.Week}] {
+			continue
+		}
+		for _, item := range record.Items {
+			if item.ID == "" || raw(item.Category) || slices.Contains(reported[item.ID], fmt.Sprint(record.Week)) {
+				continue
+			}
+			if _, ok := titles[item.ID]; !ok {
+				order = append(order, item.ID)
+				titles[item.ID] = item.Title
+			}
+			reported[item.ID] = append(reported[item.ID], fmt.Sprint(record.Week))
+		}
+	}
+
+	var repeated []string
+	for _, id := range order {
+		if weeks := reported[id]; len(weeks) > 1 {
+			repeated = append(repeated, fmt.Sprintf("%s (weeks %s)", titles[id], strings.Join(weeks, ", ")))
+		}
+	}
+	return repeated, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const runReportFile = "run-report.json"
+
+// runReport describes a single invocation for monitoring scheduled runs. It
+// is written to the state directory whether the run succeeds or fails.
+type runReport struct {
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Inputs     struct {
+		Board        string `json:"board"`
+		Column       string `json:"column"`
+		OutputFolder string `json:"output_folder"`
+		Format       string `json:"format"`
+		AIAssisted   bool   `json:"ai_assisted"`
+		Draft        bool   `json:"draft,omitempty"`
+	} `json:"inputs"`
+	Worklog    string         `json:"worklog,omitempty"`
+	Items      int            `json:"items"`
+	Categories map[string]int `json:"categories,omitempty"`
+	Sinks      []sinkReport   `json:"sinks,omitempty"`
+	// DurationsMS holds the time spent per stage in milliseconds.
+	DurationsMS map[string]int64 `json:"durations_ms"`
+	Tokens      tokenUsage       `json:"tokens"`
+	// CostUSD is the estimated cost of the LLM calls, as recorded in the
+	// cost ledger.
+	CostUSD float64 `json:"cost_usd,omitempty"`
+	// APIKeys breaks the usage down by key when several are rotated.
+	APIKeys []keyUsage `json:"api_keys,omitempty"`
+	Errors  []string   `json:"errors,omitempty"`
+}
+
+type sinkReport struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func newRunReport() *runReport {
+	return &runReport{Status: "running", StartedAt: time.Now(), DurationsMS: make(map[string]int64)}
+}
+
+// stage records the time spent in a stage that began at start.
+func (r *runReport) stage(name string, start time.Time) {
+	r.DurationsMS[name] += time.Since(start).Milliseconds()
+}
+
+func (r *runReport) addSinks(results []sinkResult) {
+	for _, result := range results {
+		sink := sinkReport{Name: result.Name, Status: "success", DurationMS: result.Duration.Milliseconds()}
+		if result.Err != nil {
+			sink.Status = "failure"
+			sink.Error = result.Err.Error()
+		}
+		r.Sinks = append(r.Sinks, sink)
+	}
+}
+
+// finish completes the report with the outcome of the run.
+func (r *runReport) finish(err error) {
+	r.FinishedAt = time.Now()
+	r.DurationsMS["total"] = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	r.Tokens = totalUsage()
+	r.CostUSD = activeLedger.record()
+	r.APIKeys = keyUsages()
+	r.Status = "success"
+	if err != nil {
+		r.Status = "failure"
+		r.Errors = append(r.Errors, err.Error())
+	}
+}
+
+// writeRunReport replaces the run report in stateDir.
+func writeRunReport(stateDir string, report *runReport) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	path := filepath.Join(stateDir, runReportFile)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"gopkg.in/yaml.v3"
+)
+
+// Secrets in the config file are encrypted, so the file can live in a synced
+// vault without exposing them. A value is secretPrefix, the kind of key it
+// is encrypted with, and the base64 of the salt, nonce, and AES-256-GCM
+// ciphertext, e.g. enc:v1:machine:AbC...
+const secretPrefix = "enc:v1:"
+
+// Kinds of keys secrets are encrypted with: a random key kept in the state
+// directory, so the secret can only be read on this machine, or a key derived
+// from the passphrase in secretPassphraseEnv.
+const (
+	secretKeyMachine    = "machine"
+	secretKeyPassphrase = "passphrase"
+)
+
+// secretKeyFile holds the key of machine-bound secrets in the state
+// directory.
+const secretKeyFile = "secret.key"
+
+// secretPassphraseEnv holds the passphrase of secrets encrypted with one.
+const secretPassphraseEnv = "WORKLOG_SECRET_PASSPHRASE"
+
+// secretNamePattern matches the names of secrets, such as openai or jira.
+var secretNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// secretStore decrypts the secrets of the config file.
+type secretStore struct {
+	values   map[string]string
+	stateDir string
+}
+
+// configSecrets are the secrets of the config file of the run, set with the
+// config.
+var configSecrets secretStore
+
+// lookup returns the decrypted secret name, or an empty string if the
+// config file has none.
+func (s secretStore) lookup(name string) (string, error) {
+	value, ok := s.values[name]
+	if !ok {
+		return "", nil
+	}
+	secret, err := decryptSecret(value, s.stateDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the secret '%s' of the config file: %w", name, err)
+	}
+	return secret, nil
+}
+
+// encryptSecret encrypts secret with a key of the given kind.
+func encryptSecret(secret string, kind string, stateDir string) (string, error) {
+	salt := make([]byte, 16)
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	gcm, err := secretCipher(kind, salt, stateDir, true)
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, nonce, []byte(secret), []byte(kind))
+	data := append(append(salt, nonce...), sealed...)
+	return secretPrefix + kind + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decryptSecret decrypts a value written by encryptSecret.
+func decryptSecret(value string, stateDir string) (string, error) {
+	kind, encoded, ok := strings.Cut(strings.TrimPrefix(value, secretPrefix), ":")
+	if !strings.HasPrefix(value, secretPrefix) || !ok {
+		return "", fmt.Errorf("not an encrypted secret; store it with config set-secret")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < 16+12 {
+		return "", fmt.Errorf("malformed encrypted secret")
+	}
+	gcm, err := secretCipher(kind, data[:16], stateDir, false)
+	if err != nil {
+		return "", err
+	}
+	secret, err := gcm.Open(nil, data[16:28], data[28:], []byte(kind))
+	if err != nil {
+		if kind == secretKeyPassphrase {
+			return "", fmt.Errorf("wrong passphrase")
+		}
+		return "", fmt.Errorf("it was encrypted on another machine or with another state directory")
+	}
+	return string(secret), nil
+}
+
+// secretCipher returns the cipher of a kind of key. The machine key is
+// created on first use if create is set.
+func secretCipher(kind string, salt []byte, stateDir string, create bool) (cipher.AEAD, error) {
+	var key []byte
+	switch kind {
+	case secretKeyMachine:
+		var err error
+		if key, err = machineKey(stateDir, create); err != nil {
+			return nil, err
+		}
+	case secretKeyPassphrase:
+		passphrase := os.Getenv(secretPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("the secret is encrypted with a passphrase; set it in %s", secretPassphraseEnv)
+		}
+		key = pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, 32, sha256.New)
+	default:
+		return nil, fmt.Errorf("unknown kind of key '%s'", kind)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// machineKey reads the key of machine-bound secrets from the state
+// directory, creating it if create is set and there is none.
+func machineKey(stateDir string, create bool) ([]byte, error) {
+	path := filepath.Join(stateDir, secretKeyFile)
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if err == nil {
+		return nil, fmt.Errorf("the key in %s is damaged", path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read the secret key: %w", err)
+	}
+	if !create {
+		return nil, fmt.Errorf("no secret key in %s; the secret was encrypted on another machine or with another state directory", stateDir)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate the secret key: %w", err)
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write the secret key: %w", err)
+	}
+	log.Printf("INFO: Created the key of machine-bound secrets in %s; keep it out of synced folders", path)
+	return key, nil
+}
+
+// runConfig implements the config subcommand, which so far only has
+// set-secret.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "set-secret" {
+		return fmt.Errorf("usage: config set-secret NAME [flags]")
+	}
+	return runSetSecret(args[1:])
+}
+
+// runSetSecret reads a secret from the terminal or standard input, encrypts
+// it, and stores it in the secrets of the config file.
+func runSetSecret(args []string) error {
+	fs := flag.NewFlagSet("config set-secret", flag.ExitOnError)
+	usePassphrase := fs.Bool("passphrase", false, "Encrypt with the passphrase in "+secretPassphraseEnv+" instead of a key bound to this machine, so other machines with the passphrase can read the secret")
+	configPath := fs.String("config", "", "Path to the YAML config file to store the secret in (default: worklog.yaml in the vault root, or ~/.config/worklog-gen/config.yaml)")
+	vault := fs.String("vault", "", "Obsidian vault whose worklog.yaml to store the secret in")
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory for the run history and other state, which holds the key of machine-bound secrets")
+
+	// The name comes first, but may also follow the flags.
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("usage: config set-secret NAME, where NAME is e.g. openai, anthropic, or jira")
+	}
+
+	path := resolveConfigPath(*configPath, *vault, ".")
+	if path == "" {
+		path = userConfigPath()
+	}
+	if strings.HasSuffix(path, pluginDataPath) {
+		return fmt.Errorf("secrets can't be stored in the plugin's settings; pass --config with a YAML config file")
+	}
+
+	kind := secretKeyMachine
+	if *usePassphrase {
+		kind = secretKeyPassphrase
+		if os.Getenv(secretPassphraseEnv) == "" {
+			return fmt.Errorf("--passphrase needs the passphrase in %s", secretPassphraseEnv)
+		}
+	}
+	secret, err := readSecret(fmt.Sprintf("Secret '%s': ", name))
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return fmt.Errorf("no secret given")
+	}
+	value, err := encryptSecret(secret, kind, *stateDir)
+	if err != nil {
+		return err
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	updated, err := setConfigSecret(string(text), name, value)
+	if err != nil {
+		return err
+	}
+	if _, err := parseConfig(path, []byte(updated)); err != nil {
+		return fmt.Errorf("the config file would be invalid after storing the secret: %w", err)
+	}
+
+	// A new config file holding secrets is only readable by you; an existing
+	// one keeps its permissions.
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config folder: %w", err)
+	}
+	if err := writeFileAtomic(path, []byte(updated)); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		return fmt.Errorf("failed to keep the permissions of the config file: %w", err)
+	}
+	log.Printf("SUCCESS: Stored the secret '%s' in %s, encrypted with a %s key", name, path, kind)
+	return nil
+}
+
+// readSecret reads a line from standard input, prompting for it without
+// echo on a terminal.
+func readSecret(prompt string) (string, error) {
+	info, err := os.Stdin.Stat()
+	terminal := err == nil && info.Mode()&os.ModeCharDevice != 0
+	if terminal {
+		fmt.Fprint(os.Stderr, prompt)
+		if runtime.GOOS != "windows" {
+			if echo(false) == nil {
+				defer func() {
+					echo(true)
+					fmt.Fprintln(os.Stderr)
+				}()
+			}
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read the secret: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// echo turns the echo of the terminal on standard input on or off.
+func echo(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// setConfigSecret stores value as the secret name in the text of a config
+// file, replacing the secret's previous value or adding it to the secrets,
+// and keeps the rest of the text, including comments, as it is.
+func setConfigSecret(text string, name string, value string) (string, error) {
+	entry := fmt.Sprintf("%s: %q", name, value)
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		// An empty file, or one of nothing but comments, which are kept.
+		config := fmt.Sprintf("version: %d\nsecrets:\n  %s\n", configVersion, entry)
+		if strings.TrimSpace(text) == "" {
+			return config, nil
+		}
+		return strings.TrimRight(text, "\n") + "\n" + config, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("the config file is not a mapping of settings")
+	}
+	if root.Style&yaml.FlowStyle != 0 {
+		// Appending YAML to JSON would turn it into a file neither is happy
+		// with.
+		return "", fmt.Errorf("the config file is JSON or YAML in flow style, which set-secret doesn't edit; add \"secrets\": {%q: %q} to it yourself", name, value)
+	}
+
+	var secrets *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "secrets" {
+			secrets = root.Content[i+1]
+		}
+	}
+	lines := strings.Split(text, "\n")
+	switch {
+	case secrets == nil:
+		return strings.TrimRight(text, "\n") + "\nsecrets:\n  " + entry + "\n", nil
+	case secrets.Kind != yaml.MappingNode || secrets.Style&yaml.FlowStyle != 0 || len(secrets.Content) == 0:
+		return "", fmt.Errorf("secrets on line %d of the config file must be a block mapping of names to values to add to it", secrets.Line)
+	}
+
+	last := 0
+	for i := 0; i+1 < len(secrets.Content); i += 2 {
+		key, current := secrets.Content[i], secrets.Content[i+1]
+		if key.Value == name {
+			if current.Line != key.Line || current.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+				return "", fmt.Errorf("the secret '%s' on line %d of the config file must be on a single line to replace it", name, key.Line)
+			}
+			lines[key.Line-1] = lines[key.Line-1][:key.Column-1] + entry
+			return strings.Join(lines, "\n"), nil
+		}
+		last = max(last, current.Line)
+	}
+	indent := strings.Repeat(" ", secrets.Content[0].Column-1)
+	lines = slices.Insert(lines, last, indent+entry)
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSetConfigSecret checks that set-secret edits config files in place,
+// keeping their comments, and refuses those it can't edit safely.
+func TestSetConfigSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "empty file",
+			config: "",
+			want:   "version: 2\nsecrets:\n  jira: \"enc:abc\"\n",
+		},
+		{
+			name:   "only comments",
+			config: "# Work board\n",
+			want:   "# Work board\nversion: 2\nsecrets:\n  jira: \"enc:abc\"\n",
+		},
+		{
+			name:   "no secrets",
+			config: "version: 2\nmodel: gpt-4o # the default\n",
+			want:   "version: 2\nmodel: gpt-4o # the default\nsecrets:\n  jira: \"enc:abc\"\n",
+		},
+		{
+			name:   "added to the secrets",
+			config: "secrets:\n    openai: \"enc:key\"\nmodel: gpt-4o\n",
+			want:   "secrets:\n    openai: \"enc:key\"\n    jira: \"enc:abc\"\nmodel: gpt-4o\n",
+		},
+		{
+			name:   "replaced",
+			config: "secrets:\n  jira: \"enc:old\" # rotated monthly\n  openai: \"enc:key\"\n",
+			want:   "secrets:\n  jira: \"enc:abc\"\n  openai: \"enc:key\"\n",
+		},
+		{
+			name:    "json",
+			config:  `{"model": "gpt-4o"}`,
+			wantErr: true,
+		},
+		{
+			name:    "secrets in flow style",
+			config:  "secrets: {openai: key}\n",
+			wantErr: true,
+		},
+		{
+			name:    "multi-line secret",
+			config:  "secrets:\n  jira: >\n    enc:old\n",
+			wantErr: true,
+		},
+		{
+			name:    "not a mapping",
+			config:  "- jira\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setConfigSecret(tt.config, "jira", "enc:abc")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setConfigSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("setConfigSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSecretRoundTrip encrypts a secret with both kinds of keys and checks
+// that it only decrypts with the same key.
+func TestSecretRoundTrip(t *testing.T) {
+	stateDir := t.TempDir()
+	t.Setenv(secretPassphraseEnv, "correct horse")
+
+	for _, kind := range []string{secretKeyMachine, secretKeyPassphrase} {
+		value, err := encryptSecret("sk-test", kind, stateDir)
+		if err != nil {
+			t.Fatalf("encryptSecret(%s): %v", kind, err)
+		}
+		if !strings.HasPrefix(value, secretPrefix+kind+":") || strings.Contains(value, "sk-test") {
+			t.Errorf("encrypted %s secret = %q", kind, value)
+		}
+		secret, err := (secretStore{values: map[string]string{"openai": value}, stateDir: stateDir}).lookup("openai")
+		if err != nil || secret != "sk-test" {
+			t.Errorf("lookup of the %s secret = %q, %v; want sk-test", kind, secret, err)
+		}
+	}
+
+	machine, err := encryptSecret("sk-test", secretKeyMachine, stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decryptSecret(machine, t.TempDir()); err == nil {
+		t.Error("a machine-bound secret decrypted with another state directory")
+	}
+	passphrase, err := encryptSecret("sk-test", secretKeyPassphrase, stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(secretPassphraseEnv, "wrong horse")
+	if _, err := decryptSecret(passphrase, stateDir); err == nil || err.Error() != "wrong passphrase" {
+		t.Errorf("decrypting with the wrong passphrase = %v, want wrong passphrase", err)
+	}
+	if _, err := decryptSecret("sk-plain", stateDir); err == nil {
+		t.Error("a plain text secret was accepted")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// pbkdf2Iterations matches current OWASP guidance for PBKDF2-HMAC-SHA256.
+// The browser derives the same key with WebCrypto before decrypting.
+const pbkdf2Iterations = 600000
+
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Worklog</title>
+<style>body{font-family:system-ui,sans-serif;max-width:48rem;margin:2rem auto;padding:0 1rem;line-height:1.5;color:#222}
+input,button{font-size:1rem;padding:.5rem}#error{color:#b00020}</style>
+</head>
+<body>
+<form id="unlock">
+<p>This worklog is encrypted. Enter the passphrase to read it.</p>
+<input id="passphrase" type="password" autofocus> <button type="submit">Decrypt</button>
+<p id="error"></p>
+</form>
+<main id="content"></main>
+<script>
+const payload = {{.}};
+function decode(value) { return Uint8Array.from(atob(value), function (c) { return c.charCodeAt(0); }); }
+document.getElementById("unlock").addEventListener("submit", async function (event) {
+  event.preventDefault();
+  try {
+    const material = await crypto.subtle.importKey("raw", new TextEncoder().encode(document.getElementById("passphrase").value), "PBKDF2", false, ["deriveKey"]);
+    const key = await crypto.subtle.deriveKey({name: "PBKDF2", salt: decode(payload.salt), iterations: payload.iterations, hash: "SHA-256"}, material, {name: "AES-GCM", length: 256}, false, ["decrypt"]);
+    const plain = await crypto.subtle.decrypt({name: "AES-GCM", iv: decode(payload.iv)}, key, decode(payload.data));
+    document.getElementById("content").innerHTML = new TextDecoder().decode(plain);
+    document.getElementById("unlock").remove();
+  } catch (e) {
+    document.getElementById("error").textContent = "Wrong passphrase.";
+  }
+});
+</script>
+</body>
+</html>
+`))
+
+type sharePayload struct {
+	Salt       string `json:"salt"`
+	IV         string `json:"iv"`
+	Iterations int    `json:"iterations"`
+	Data       string `json:"data"`
+}
+
+// encryptHTML encrypts content with AES-256-GCM under a key derived from the
+// passphrase and wraps it in a page that decrypts it in the browser, in the
+// style of staticrypt.
+func encryptHTML(content []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+
+	key := pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	payload := sharePayload{
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		IV:         base64.StdEncoding.EncodeToString(iv),
+		Iterations: pbkdf2Iterations,
+		Data:       base64.StdEncoding.EncodeToString(gcm.Seal(nil, iv, content, nil)),
+	}
+
+	var page bytes.Buffer
+	if err := sharePageTemplate.Execute(&page, payload); err != nil {
+		return nil, fmt.Errorf("failed to render share page: %w", err)
+	}
+	return page.Bytes(), nil
+}
+
+// shareSink publishes the worklog as a passphrase-protected HTML page, either
+// into a local folder (e.g. a synced or served directory) or by uploading it
+// with an HTTP PUT, and logs the resulting shareable link.
+type shareSink struct {
+	dest       string
+	baseURL    string
+	passphrase string
+}
+
+func newShareSink(dest string, baseURL string, passphrase string) (*shareSink, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("sharing requires a passphrase (--share-passphrase or WORKLOG_SHARE_PASSPHRASE)")
+	}
+	return &shareSink{dest: dest, baseURL: baseURL, passphrase: passphrase}, nil
+}
+
+func (s *shareSink) Name() string {
+	return "share"
+}
+
+func (s *shareSink) Send(ctx context.Context, report *Report) error {
+	var html bytes.Buffer
+	if err := goldmark.Convert([]byte(report.Markdown()), &html); err != nil {
+		return fmt.Errorf("failed to convert worklog to HTML: %w", err)
+	}
+
+	page, err := encryptHTML(html.Bytes(), s.passphrase)
+	if err != nil {
+		return err
+	}
+
+	// A random name keeps the link unguessable even if the destination is
+	// listable by week.
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate file name: %w", err)
+	}
+	name := fmt.Sprintf("worklog-%d-%d-%s.html", report.Doc.Year, report.Doc.Week, hex.EncodeToString(token))
+
+	link := ""
+	if strings.HasPrefix(s.dest, "http://") || strings.HasPrefix(s.dest, "https://") {
+		link = strings.TrimRight(s.dest, "/") + "/" + name
+		if err := sendRequest(ctx, http.MethodPut, link, "text/html; charset=utf-8", page, nil); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(s.dest, 0755); err != nil {
+			return fmt.Errorf("failed to create share folder: %w", err)
+		}
+		link = filepath.Join(s.dest, name)
+		if err := os.WriteFile(link, page, 0644); err != nil {
+			return fmt.Errorf("failed to write shared page: %w", err)
+		}
+	}
+
+	if s.baseURL != "" {
+		link = strings.TrimRight(s.baseURL, "/") + "/" + name
+	}
+	log.Printf("INFO: Shareable link: %s", link)
+	return nil
+}
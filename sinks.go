@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// Sink delivers a generated worklog to an external destination.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, report *Report) error
+}
+
+// Report is a worklog rendered in its output format, ready for delivery.
+type Report struct {
+	Doc     *output.Document `json:"doc"`
+	Format  string           `json:"format"`
+	Content string           `json:"content"`
+}
+
+// Markdown returns the markdown version of the report. Content is used as-is
+// when it already is markdown, so hand edits survive publishing.
+func (r *Report) Markdown() string {
+	if r.Format == "md" {
+		return r.Content
+	}
+	return output.RenderMarkdown(r.Doc)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// sinkOptions collects the command-line settings of all sinks.
+type sinkOptions struct {
+	webhookURL        string
+	webhookTemplate   string
+	matrixHomeserver  string
+	matrixRoom        string
+	matrixToken       string
+	mattermostWebhook string
+	mattermostChannel string
+	slackWebhook      string
+	confluenceURL     string
+	confluenceSpace   string
+	confluenceParent  string
+	confluenceUser    string
+	confluenceToken   string
+	confluenceTitle   string
+	jiraURL           string
+	jiraIssue         string
+	jiraUser          string
+	jiraToken         string
+	pushgatewayURL    string
+	pushgatewayJob    string
+	shareDest         string
+	shareBaseURL      string
+	sharePassphrase   string
+	copyTo            listFlag
+	anonymize         bool
+	templates         string
+	// copies are the copies set up in the config file, on top of the
+	// folders of --copy-to.
+	copies []CopyConfig
+	// modelPrices prices the tokens pushed to the Pushgateway.
+	modelPrices map[string]modelPrice
+}
+
+func registerSinkFlags(fs *flag.FlagSet) *sinkOptions {
+	opts := &sinkOptions{}
+	fs.StringVar(&opts.webhookURL, "webhook", "", "URL to POST the generated worklog to as JSON")
+	fs.StringVar(&opts.webhookTemplate, "webhook-template", "", "Path to a text/template file rendering the webhook JSON payload")
+	fs.StringVar(&opts.matrixHomeserver, "matrix-homeserver", "https://matrix.org", "Matrix homeserver base URL")
+	fs.StringVar(&opts.matrixRoom, "matrix-room", "", "Matrix room ID to post the worklog to (e.g. !abc123:matrix.org)")
+	fs.StringVar(&opts.matrixToken, "matrix-token", "", "Matrix access token (can also be set via MATRIX_ACCESS_TOKEN env var)")
+	fs.StringVar(&opts.mattermostWebhook, "mattermost-webhook", "", "Mattermost incoming webhook URL to post the worklog to")
+	fs.StringVar(&opts.mattermostChannel, "mattermost-channel", "", "Override the Mattermost webhook's default channel")
+	fs.StringVar(&opts.slackWebhook, "slack-webhook", "", "Slack incoming webhook URL to post the worklog to, with a section per category")
+	fs.StringVar(&opts.confluenceURL, "confluence-url", "", "Confluence base URL to publish the worklog to as a page (e.g. https://example.atlassian.net/wiki)")
+	fs.StringVar(&opts.confluenceSpace, "confluence-space", "", "Key of the Confluence space the page is created in")
+	fs.StringVar(&opts.confluenceParent, "confluence-parent", "", "ID of the Confluence page new pages are created under (default: the space's top level)")
+	fs.StringVar(&opts.confluenceUser, "confluence-user", "", "Confluence Cloud account email for the API token; leave empty for a personal access token of Server or Data Center")
+	fs.StringVar(&opts.confluenceToken, "confluence-token", "", "Confluence API token (can also be set via CONFLUENCE_API_TOKEN env var or the secret 'confluence' of the config file)")
+	fs.StringVar(&opts.confluenceTitle, "confluence-title", defaultConfluenceTitle, "Title of the Confluence page, a text/template with .Year and .Week; the page with this title is updated when it exists")
+	fs.StringVar(&opts.jiraURL, "jira-url", "", "Jira base URL to post the worklog to as a comment on --jira-issue (e.g. https://example.atlassian.net)")
+	fs.StringVar(&opts.jiraIssue, "jira-issue", "", "Key of the Jira issue to comment on, e.g. a weekly status epic")
+	fs.StringVar(&opts.jiraUser, "jira-user", "", "Jira Cloud account email for the API token; leave empty for a personal access token of Server or Data Center")
+	fs.StringVar(&opts.jiraToken, "jira-token", "", "Jira API token (can also be set via JIRA_API_TOKEN env var or the secret 'jira' of the config file)")
+	fs.StringVar(&opts.pushgatewayURL, "pushgateway", "", "Prometheus Pushgateway URL to push the items per category and the tokens used to")
+	fs.StringVar(&opts.pushgatewayJob, "pushgateway-job", "worklog_gen", "Job name of the metrics pushed to the Pushgateway")
+	fs.StringVar(&opts.shareDest, "share-dest", "", "Folder or HTTP(S) URL (uploaded with PUT) receiving a passphrase-encrypted HTML copy of the worklog")
+	fs.StringVar(&opts.shareBaseURL, "share-base-url", "", "Public URL under which --share-dest is served, used to print the shareable link")
+	fs.StringVar(&opts.sharePassphrase, "share-passphrase", "", "Passphrase for the encrypted copy (can also be set via WORKLOG_SHARE_PASSPHRASE env var)")
+	fs.Var(&opts.copyTo, "copy-to", "Folder receiving a copy of the worklog, e.g. a mounted team share; repeat it or give a comma-separated list for several")
+	fs.StringVar(&opts.templates, "sink-template", "", "Comma-separated sink=template pairs rendering the worklog differently per sink; a template is 'short' or a text/template file")
+	fs.BoolVar(&opts.anonymize, "anonymize", false, "Replace names and configured terms with placeholders in delivered output (the local file is kept intact)")
+	return opts
+}
+
+// build creates the sinks enabled by the options, in a stable order.
+func (o *sinkOptions) build() ([]Sink, error) {
+	var sinks []Sink
+
+	if o.webhookURL != "" {
+		sink, err := newWebhookSink(o.webhookURL, o.webhookTemplate)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if o.matrixRoom != "" {
+		token := o.matrixToken
+		if token == "" {
+			token = os.Getenv("MATRIX_ACCESS_TOKEN")
+		}
+		sink, err := newMatrixSink(o.matrixHomeserver, o.matrixRoom, token)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if o.mattermostWebhook != "" {
+		sinks = append(sinks, &mattermostSink{webhookURL: o.mattermostWebhook, channel: o.mattermostChannel})
+	}
+
+	if o.slackWebhook != "" {
+		sinks = append(sinks, &slackSink{webhookURL: o.slackWebhook})
+	}
+
+	if o.confluenceURL != "" {
+		token, err := sinkToken(o.confluenceToken, "CONFLUENCE_API_TOKEN", "confluence")
+		if err != nil {
+			return nil, err
+		}
+		sink, err := newConfluenceSink(o.confluenceURL, o.confluenceSpace, o.confluenceParent, o.confluenceUser, token, o.confluenceTitle)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if o.jiraURL != "" {
+		token, err := sinkToken(o.jiraToken, "JIRA_API_TOKEN", "jira")
+		if err != nil {
+			return nil, err
+		}
+		sink, err := newJiraSink(o.jiraURL, o.jiraIssue, o.jiraUser, token)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if o.pushgatewayURL != "" {
+		sinks = append(sinks, &pushgatewaySink{url: o.pushgatewayURL, job: o.pushgatewayJob, prices: o.modelPrices})
+	}
+
+	if o.shareDest != "" {
+		passphrase := o.sharePassphrase
+		if passphrase == "" {
+			passphrase = os.Getenv("WORKLOG_SHARE_PASSPHRASE")
+		}
+		sink, err := newShareSink(o.shareDest, o.shareBaseURL, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	copies, err := newCopySinks(o.copyTo, o.copies)
+	if err != nil {
+		return nil, err
+	}
+	sinks = append(sinks, copies...)
+
+	return applySinkTemplates(sinks, o.templates)
+}
+
+// applySinkTemplates wraps the sinks that have a template in spec.
+func applySinkTemplates(sinks []Sink, spec string) ([]Sink, error) {
+	templates, err := parseSinkTemplates(spec)
+	if err != nil {
+		return nil, err
+	}
+	for i, sink := range sinks {
+		if tmpl, ok := templates[sink.Name()]; ok {
+			sinks[i] = &templatedSink{Sink: sink, template: tmpl}
+			delete(templates, sink.Name())
+		}
+	}
+	for name := range templates {
+		return nil, fmt.Errorf("template given for sink '%s', which is not configured", name)
+	}
+	return sinks, nil
+}
+
+// selectSinks narrows sinks down to the comma-separated names in filter. An
+// empty filter selects all sinks.
+func selectSinks(sinks []Sink, filter string) ([]Sink, error) {
+	if strings.TrimSpace(filter) == "" {
+		return sinks, nil
+	}
+
+	available := make(map[string]Sink, len(sinks))
+	var names []string
+	for _, sink := range sinks {
+		available[sink.Name()] = sink
+		names = append(names, sink.Name())
+	}
+
+	var selected []Sink
+	for _, name := range strings.Split(filter, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		sink, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("sink '%s' is not configured (configured: %s)", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, sink)
+	}
+	return selected, nil
+}
+
+// sinkToken returns the API token of a sink: the flag's value, the
+// environment variable env, or the secret of the config file named secret,
+// whichever is set first.
+func sinkToken(flag string, env string, secret string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	if token := os.Getenv(env); token != "" {
+		return token, nil
+	}
+	return configSecrets.lookup(secret)
+}
+
+// sinkResult is the outcome of delivering a report to a single sink.
+type sinkResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// deliver sends the worklog to every sink and reports all failures at once,
+// so one broken destination doesn't prevent delivery to the others.
+func deliver(ctx context.Context, sinks []Sink, report *Report) ([]sinkResult, error) {
+	var results []sinkResult
+	var errs []error
+	for _, sink := range sinks {
+		log.Printf("INFO: Delivering worklog to %s", sink.Name())
+		start := time.Now()
+		err := sink.Send(ctx, report)
+		results = append(results, sinkResult{Name: sink.Name(), Duration: time.Since(start), Err: err})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// postJSON sends a JSON body to url and treats any non-2xx response as an
+// error.
+func postJSON(ctx context.Context, method string, url string, body []byte, headers map[string]string) error {
+	return sendRequest(ctx, method, url, "application/json", body, headers)
+}
+
+func sendRequest(ctx context.Context, method string, url string, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// webhookPayload is both the default JSON body of the webhook sink and the
+// data passed to custom payload templates.
+type webhookPayload struct {
+	Year       int              `json:"year"`
+	Week       int              `json:"week"`
+	AIAssisted bool             `json:"ai_assisted"`
+	Content    string           `json:"content"`
+	Sections   []webhookSection `json:"sections"`
+	// Collaboration maps each mentioned person to the cards they appear on.
+	Collaboration map[string][]string `json:"collaboration,omitempty"`
+	Notes         string              `json:"notes,omitempty"`
+	Digest        string              `json:"digest,omitempty"`
+	Comparison    string              `json:"comparison,omitempty"`
+}
+
+type webhookSection struct {
+	// Column is the board column of the section in worklogs covering
+	// several columns.
+	Column    string   `json:"column,omitempty"`
+	Category  string   `json:"category"`
+	Title     string   `json:"title"`
+	Summary   string   `json:"summary,omitempty"`
+	KeyPoints []string `json:"key_points,omitempty"`
+	Items     []string `json:"items,omitempty"`
+	// PlainSummary is the jargon-free summary for stakeholders, if any.
+	PlainSummary string `json:"plain_summary,omitempty"`
+	// ItemCount is the number of items of the section, also when it is
+	// summarized.
+	ItemCount int `json:"item_count"`
+	// Manual is the verbatim markdown of a section the user locked with a
+	// <!-- manual --> comment, for output templates to write back as is.
+	Manual string `json:"-"`
+}
+
+func newWebhookPayload(report *Report) webhookPayload {
+	payload := webhookPayload{
+		Year:       report.Doc.Year,
+		Week:       report.Doc.Week,
+		AIAssisted: report.Doc.AIAssisted,
+		Content:    report.Content,
+		Sections:   []webhookSection{},
+		Notes:      report.Doc.Notes,
+		Digest:     report.Doc.Digest,
+		Comparison: report.Doc.Comparison,
+	}
+	for _, collaborator := range report.Doc.Collaboration {
+		if payload.Collaboration == nil {
+			payload.Collaboration = make(map[string][]string)
+		}
+		payload.Collaboration[collaborator.Name] = collaborator.Items
+	}
+	for _, section := range report.Doc.Sections {
+		itemCount := section.ItemCount
+		if itemCount == 0 {
+			// Worklogs read back from a file, e.g. by publish, only know
+			// the items they list.
+			itemCount = len(section.Items)
+		}
+		payload.Sections = append(payload.Sections, webhookSection{
+			Column:       section.Column,
+			Category:     section.Category,
+			Title:        section.Title(),
+			Summary:      section.Summary,
+			KeyPoints:    section.KeyPoints,
+			Items:        section.Items,
+			PlainSummary: section.PlainSummary,
+			ItemCount:    itemCount,
+			Manual:       section.Manual,
+		})
+	}
+	return payload
+}
+
+type webhookSink struct {
+	url      string
+	template *template.Template
+}
+
+// newWebhookSink creates a sink posting to url. If templatePath is set, the
+// file is parsed as a text/template that must render to a JSON document.
+func newWebhookSink(url string, templatePath string) (*webhookSink, error) {
+	sink := &webhookSink{url: url}
+	if templatePath == "" {
+		return sink, nil
+	}
+
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook template: %w", err)
+	}
+
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+	}
+
+	sink.template = tmpl
+	return sink, nil
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Send(ctx context.Context, report *Report) error {
+	payload := newWebhookPayload(report)
+
+	var body []byte
+	if s.template == nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode payload: %w", err)
+		}
+		body = encoded
+	} else {
+		var buf bytes.Buffer
+		if err := s.template.Execute(&buf, payload); err != nil {
+			return fmt.Errorf("failed to render payload template: %w", err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			return fmt.Errorf("payload template did not produce valid JSON")
+		}
+		body = buf.Bytes()
+	}
+
+	return postJSON(ctx, http.MethodPost, s.url, body, nil)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelectSinks(t *testing.T) {
+	sinks := []Sink{&fakeSink{name: "webhook"}, &fakeSink{name: "matrix"}, &fakeSink{name: "archive"}}
+	names := func(selected []Sink) []string {
+		var names []string
+		for _, sink := range selected {
+			names = append(names, sink.Name())
+		}
+		return names
+	}
+
+	if selected, err := selectSinks(sinks, " "); err != nil || len(selected) != 3 {
+		t.Errorf("selectSinks() without a filter = %q, %v; want every sink", names(selected), err)
+	}
+	// Sinks go in the order of the filter, whatever its spelling.
+	selected, err := selectSinks(sinks, "Archive, matrix,")
+	if want := []string{"archive", "matrix"}; err != nil || !slices.Equal(names(selected), want) {
+		t.Errorf("selectSinks() = %q, %v; want %q", names(selected), err, want)
+	}
+	_, err = selectSinks(sinks, "matrix,slack")
+	if want := "sink 'slack' is not configured (configured: webhook, matrix, archive)"; err == nil || err.Error() != want {
+		t.Errorf("selectSinks() error = %v, want %q", err, want)
+	}
+}
+
+// TestSinkToken checks that a token from the flag wins over the environment,
+// which wins over the secrets of the config file.
+func TestSinkToken(t *testing.T) {
+	stateDir := t.TempDir()
+	secret, err := encryptSecret("from-config", secretKeyMachine, stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := configSecrets
+	configSecrets = secretStore{values: map[string]string{"confluence": secret}, stateDir: stateDir}
+	defer func() { configSecrets = secrets }()
+
+	t.Setenv("WORKLOG_TEST_TOKEN", "from-env")
+	if token, err := sinkToken("from-flag", "WORKLOG_TEST_TOKEN", "confluence"); err != nil || token != "from-flag" {
+		t.Errorf("sinkToken() with a flag = %q, %v; want from-flag", token, err)
+	}
+	if token, err := sinkToken("", "WORKLOG_TEST_TOKEN", "confluence"); err != nil || token != "from-env" {
+		t.Errorf("sinkToken() with an environment variable = %q, %v; want from-env", token, err)
+	}
+	t.Setenv("WORKLOG_TEST_TOKEN", "")
+	if token, err := sinkToken("", "WORKLOG_TEST_TOKEN", "confluence"); err != nil || token != "from-config" {
+		t.Errorf("sinkToken() with a config secret = %q, %v; want from-config", token, err)
+	}
+	if token, err := sinkToken("", "WORKLOG_TEST_TOKEN", "jira"); err != nil || token != "" {
+		t.Errorf("sinkToken() without a token = %q, %v; want none", token, err)
+	}
+}
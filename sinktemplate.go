@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// builtinSinkTemplates are the templates that can be selected by name with
+// --sink-template instead of a file.
+var builtinSinkTemplates = map[string]string{
+	// short is a digest for chat: one line per category.
+	"short": bundledTemplate("short.tmpl"),
+}
+
+// firstSentence returns text up to and including its first full stop,
+// question mark, or exclamation mark.
+func firstSentence(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	for i, r := range text {
+		if (r == '.' || r == '!' || r == '?') && (i == len(text)-1 || text[i+1] == ' ') {
+			return text[:i+1]
+		}
+	}
+	return text
+}
+
+// parseSinkTemplates parses the comma-separated name=template pairs of
+// --sink-template. A template is the name of a built-in template or the path
+// of a text/template file.
+func parseSinkTemplates(spec string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
+	for _, pair := range splitList(spec) {
+		name, source, ok := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		source = strings.TrimSpace(source)
+		if !ok || name == "" || source == "" {
+			return nil, fmt.Errorf("invalid sink template '%s', expected sink=template", pair)
+		}
+
+		text, builtin := builtinSinkTemplates[source]
+		if !builtin {
+			data, err := os.ReadFile(source)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template for sink '%s': %w", name, err)
+			}
+			text = string(data)
+		}
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template for sink '%s': %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// templatedSink renders the worklog with its own template before handing it
+// to the wrapped sink, so e.g. a chat room gets a short digest while the
+// shared copy has the full worklog. The template receives the same data as
+// webhook payload templates.
+type templatedSink struct {
+	Sink
+	template *template.Template
+}
+
+func (s *templatedSink) Send(ctx context.Context, report *Report) error {
+	var buf bytes.Buffer
+	if err := s.template.Execute(&buf, newWebhookPayload(report)); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+	return s.Sink.Send(ctx, &Report{Doc: report.Doc, Format: "md", Content: buf.String()})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+)
+
+const siteStyle = `body{font-family:system-ui,sans-serif;max-width:48rem;margin:2rem auto;padding:0 1rem;line-height:1.5;color:#222}
+a{color:#0b5fb0}nav{margin-bottom:1.5rem}input{width:100%;padding:.5rem;font-size:1rem;margin-bottom:1rem}
+li.hidden{display:none}pre{white-space:pre-wrap}`
+
+var siteIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<input id="search" type="search" placeholder="Search worklogs..." autofocus>
+<ul id="weeks">
+{{range .Pages}}<li data-week="{{.File}}"><a href="{{.File}}">Week {{.Week}} {{.Year}}</a></li>
+{{end}}</ul>
+<script>
+const pages = {{.Index}};
+document.getElementById("search").addEventListener("input", function (event) {
+  const terms = event.target.value.toLowerCase().split(/\s+/).filter(Boolean);
+  for (const item of document.querySelectorAll("#weeks li")) {
+    const text = pages[item.dataset.week] || "";
+    item.classList.toggle("hidden", !terms.every(function (term) { return text.includes(term); }));
+  }
+});
+</script>
+</body>
+</html>
+`))
+
+var sitePageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Week {{.Week}} {{.Year}} – {{.Title}}</title>
+<style>{{.Style}}</style>
+</head>
+<body>
+<nav><a href="index.html">← All weeks</a>{{if .Previous}} · <a href="{{.Previous}}">Older</a>{{end}}{{if .Next}} · <a href="{{.Next}}">Newer</a>{{end}}</nav>
+{{.Content}}
+</body>
+</html>
+`))
+
+type sitePage struct {
+	Title    string
+	Style    template.CSS
+	Year     int
+	Week     int
+	File     string
+	Previous string
+	Next     string
+	Content  template.HTML
+	text     string
+}
+
+// worklogHTML converts a worklog file to HTML. Only markdown is converted;
+// other formats are shown preformatted.
+func worklogHTML(file worklogFile, data []byte) (template.HTML, error) {
+	if file.Extension != "md" {
+		return template.HTML("<pre>" + template.HTMLEscapeString(string(data)) + "</pre>"), nil
+	}
+
+	var html bytes.Buffer
+	if err := goldmark.Convert(data, &html); err != nil {
+		return "", fmt.Errorf("failed to convert %s to HTML: %w", file.Path, err)
+	}
+	return template.HTML(html.String()), nil
+}
+
+// runSite implements the site subcommand, which renders all worklogs of an
+// output folder into a small static website with an index, client-side
+// search, and one page per week.
+func runSite(args []string) error {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	input := fs.String("input", "", "Folder containing the generated worklogs")
+	out := fs.String("out", "public", "Folder to write the static site to")
+	title := fs.String("title", "Worklog", "Title of the site")
+	fs.Parse(args)
+
+	if *input == "" {
+		return fmt.Errorf("input flag is required")
+	}
+
+	files, err := listWorklogs(*input)
+	if err != nil {
+		return err
+	}
+
+	var pages []*sitePage
+	seen := make(map[[2]int]bool)
+	for _, file := range files {
+		key := [2]int{file.Year, file.Week}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read worklog: %w", err)
+		}
+		content, err := worklogHTML(file, data)
+		if err != nil {
+			return err
+		}
+
+		pages = append(pages, &sitePage{
+			Title:   *title,
+			Style:   template.CSS(siteStyle),
+			Year:    file.Year,
+			Week:    file.Week,
+			File:    fmt.Sprintf("week-%d-%02d.html", file.Year, file.Week),
+			Content: content,
+			text:    strings.ToLower(string(data)),
+		})
+	}
+
+	if len(pages) == 0 {
+		return fmt.Errorf("no worklogs found in %s", *input)
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return fmt.Errorf("failed to create site folder: %w", err)
+	}
+
+	index := make(map[string]string, len(pages))
+	for i, page := range pages {
+		// Pages are sorted newest first.
+		if i > 0 {
+			page.Next = pages[i-1].File
+		}
+		if i < len(pages)-1 {
+			page.Previous = pages[i+1].File
+		}
+		index[page.File] = page.text
+
+		if err := writeTemplate(filepath.Join(*out, page.File), sitePageTemplate, page); err != nil {
+			return err
+		}
+	}
+
+	err = writeTemplate(filepath.Join(*out, "index.html"), siteIndexTemplate, map[string]any{
+		"Title": *title,
+		"Style": template.CSS(siteStyle),
+		"Pages": pages,
+		"Index": index,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("SUCCESS: Rendered %d weeks to %s", len(pages), *out)
+	return nil
+}
+
+func writeTemplate(path string, tmpl *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to render %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
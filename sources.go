@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Source provides work items beyond the cards on the board, such as
+// transcribed voice memos. Items returned by a source are merged into the
+// week's items before deduplication and categorization.
+type Source interface {
+	Name() string
+	// Items returns the items of the period from start (inclusive) to end
+	// (exclusive).
+	Items(ctx context.Context, start time.Time, end time.Time) ([]worklog.Item, error)
+}
+
+type sourceOptions struct {
+	voiceMemos     string
+	boardPhotos    string
+	browserHistory string
+	historyDomains string
+}
+
+func registerSourceFlags(fs *flag.FlagSet) *sourceOptions {
+	opts := &sourceOptions{}
+	fs.StringVar(&opts.voiceMemos, "voice-memos", "", "Folder of voice memos (m4a, mp3, wav, ...) to transcribe and extract work items from")
+	fs.StringVar(&opts.boardPhotos, "board-photos", "", "Folder of photos of a physical Kanban board to read the column's cards from (experimental)")
+	fs.StringVar(&opts.browserHistory, "browser-history", "", "Browser history CSV export to add pages visited on --history-domains from, as learning items")
+	fs.StringVar(&opts.historyDomains, "history-domains", "", "Comma-separated domains whose pages count as research, e.g. arxiv.org,docs.example.com (default: history_domains from the config file)")
+	return opts
+}
+
+// needsLLM reports whether any enabled source calls the LLM API.
+func (o *sourceOptions) needsLLM() bool {
+	return o.voiceMemos != "" || o.boardPhotos != ""
+}
+
+// build creates the enabled sources, in a stable order. Sources that read a
+// board take the items of columns. apiKey is only used by sources that need
+// the LLM API; stateDir holds their caches.
+func (o *sourceOptions) build(columns []string, apiKey string, stateDir string) ([]Source, error) {
+	var sources []Source
+
+	if o.voiceMemos != "" {
+		if apiKey == "" {
+			return nil, fmt.Errorf("voice memos require an OpenAI API key for transcription")
+		}
+		sources = append(sources, &voiceSource{dir: o.voiceMemos, apiKey: apiKey, stateDir: stateDir})
+	}
+
+	if o.boardPhotos != "" {
+		if apiKey == "" {
+			return nil, fmt.Errorf("board photos require an API key")
+		}
+		sources = append(sources, &whiteboardSource{dir: o.boardPhotos, columns: columns, apiKey: apiKey, stateDir: stateDir})
+	}
+
+	if o.browserHistory != "" {
+		domains := splitList(o.historyDomains)
+		if len(domains) == 0 {
+			return nil, fmt.Errorf("--browser-history requires --history-domains or history_domains in the config file")
+		}
+		sources = append(sources, &historySource{path: o.browserHistory, domains: domains})
+	}
+
+	return sources, nil
+}
+
+// collectSourceItems gathers the items of all sources for the period.
+func collectSourceItems(ctx context.Context, sources []Source, start time.Time, end time.Time) ([]worklog.Item, error) {
+	var items []worklog.Item
+	for _, source := range sources {
+		sourceItems, err := source.Items(ctx, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source.Name(), err)
+		}
+		log.Printf("INFO: Found %d items in %s", len(sourceItems), source.Name())
+		activeTrace.include(sourceItems, "from "+source.Name()+" of the week")
+		items = append(items, sourceItems...)
+	}
+	return items, nil
+}
+
+// sourceFile is a file a source reads items from.
+type sourceFile struct {
+	path string
+	info os.FileInfo
+}
+
+// filesInPeriod lists the files in dir with one of extensions that were last
+// modified in the period from start to end, such as the memos recorded or
+// the photos taken this week.
+func filesInPeriod(dir string, extensions []string, start time.Time, end time.Time) ([]sourceFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []sourceFile
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(extensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(start) || !info.ModTime().Before(end) {
+			continue
+		}
+		files = append(files, sourceFile{path: filepath.Join(dir, entry.Name()), info: info})
+	}
+	return files, nil
+}
+
+// day returns midnight of the day the file was last modified.
+func (f sourceFile) day() time.Time {
+	modified := f.info.ModTime()
+	return time.Date(modified.Year(), modified.Month(), modified.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// cachedResult returns what compute derives from the file, such as a
+// transcript, from the cache in stateDir/kind if this version of the file was
+// processed before, so files aren't sent to the API again on every run.
+func (f sourceFile) cachedResult(stateDir string, kind string, compute func() (string, error)) (string, error) {
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", f.path, f.info.Size(), f.info.ModTime().UnixNano())))
+	cachePath := filepath.Join(stateDir, kind, fmt.Sprintf("%x.txt", key[:12]))
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		return string(cached), nil
+	}
+
+	result, err := compute()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s cache: %w", kind, err)
+	}
+	if err := os.WriteFile(cachePath, []byte(result), 0644); err != nil {
+		return "", fmt.Errorf("failed to cache %s: %w", kind, err)
+	}
+	return result, nil
+}
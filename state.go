@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultStateDir follows the XDG base directory spec, so the run history
+// survives independently of any vault or output folder.
+func defaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "worklog-gen")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".worklog-gen"
+	}
+	return filepath.Join(home, ".local", "state", "worklog-gen")
+}
+
+// HistoryRecord is one generation run as stored in the run history.
+type HistoryRecord struct {
+	Year        int           `json:"year"`
+	Week        int           `json:"week"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Items       []HistoryItem `json:"items"`
+}
+
+// HistoryItem is a card that was part of a run. ID is the Item ID; records
+// written before items had IDs leave it empty.
+type HistoryItem struct {
+	ID       string `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Source   string `json:"source,omitempty"`
+	Category string `json:"category"`
+}
+
+const historyFile = "history.jsonl"
+
+// appendHistory adds a record to the run history in stateDir.
+func appendHistory(stateDir string, record HistoryRecord) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(stateDir, historyFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// loadHistory returns the latest record of every week in the run history,
+// in chronological order. A missing history is not an error.
+func loadHistory(stateDir string) ([]HistoryRecord, error) {
+	f, err := os.Open(filepath.Join(stateDir, historyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	latest := make(map[[2]int]HistoryRecord)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse history: %w", err)
+		}
+		latest[[2]int{record.Year, record.Week}] = record
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	records := make([]HistoryRecord, 0, len(latest))
+	for _, record := range latest {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Year != records[j].Year {
+			return records[i].Year < records[j].Year
+		}
+		return records[i].Week < records[j].Week
+	})
+	return records, nil
+}
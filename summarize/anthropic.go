@@ -0,0 +1,201 @@
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// anthropicURL is the Messages API; a base URL replaces everything
+	// before /messages.
+	anthropicURL     = "https://api.anthropic.com/v1/messages"
+	anthropicVersion = "2023-06-01"
+)
+
+// anthropicModels resolves the short model names people use, such as
+// claude-3-5-sonnet, to the aliases the API accepts. Full model IDs pass
+// through unchanged.
+var anthropicModels = map[string]string{
+	"claude-3-5-sonnet": "claude-3-5-sonnet-latest",
+	"claude-3-5-haiku":  "claude-3-5-haiku-latest",
+	"claude-3-7-sonnet": "claude-3-7-sonnet-latest",
+	"claude-3-opus":     "claude-3-opus-latest",
+}
+
+// anthropicProvider sends completions to the Anthropic Messages API.
+type anthropicProvider struct {
+	apiKey      string
+	url         string
+	httpClient  *http.Client
+	temperature *float32
+}
+
+// NewAnthropicClient creates a client for the Anthropic API. A nil
+// httpClient uses the default one.
+func NewAnthropicClient(apiKey string, httpClient *http.Client) *Client {
+	return &Client{Provider: newAnthropicProvider(Config{Provider: ProviderAnthropic, APIKey: apiKey, HTTPClient: httpClient})}
+}
+
+func newAnthropicProvider(config Config) *anthropicProvider {
+	provider := &anthropicProvider{apiKey: config.APIKey, url: anthropicURL, httpClient: config.HTTPClient, temperature: config.Temperature}
+	if provider.httpClient == nil {
+		provider.httpClient = http.DefaultClient
+	}
+	if config.BaseURL != "" {
+		provider.url = strings.TrimSuffix(config.BaseURL, "/") + "/messages"
+	}
+	return provider
+}
+
+func (p *anthropicProvider) Name() string {
+	return "Anthropic"
+}
+
+func (p *anthropicProvider) DefaultModel() string {
+	return "claude-3-5-sonnet-latest"
+}
+
+type anthropicContent struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+	// Input is the input of a tool_use block in a response.
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// anthropicMessage converts a chat message to the content blocks of an
+// Anthropic message. Images given as data URLs are sent inline.
+func anthropicMessage(message openai.ChatCompletionMessage) []anthropicContent {
+	if len(message.MultiContent) == 0 {
+		return []anthropicContent{{Type: "text", Text: message.Content}}
+	}
+	var content []anthropicContent
+	for _, part := range message.MultiContent {
+		if part.Type != openai.ChatMessagePartTypeImageURL || part.ImageURL == nil {
+			content = append(content, anthropicContent{Type: "text", Text: part.Text})
+			continue
+		}
+		source := &anthropicImageSource{Type: "url", URL: part.ImageURL.URL}
+		if header, data, ok := strings.Cut(strings.TrimPrefix(part.ImageURL.URL, "data:"), ","); ok && strings.HasPrefix(part.ImageURL.URL, "data:") {
+			source = &anthropicImageSource{Type: "base64", MediaType: strings.TrimSuffix(header, ";base64"), Data: data}
+		}
+		content = append(content, anthropicContent{Type: "image", Source: source})
+	}
+	return content
+}
+
+func (p *anthropicProvider) CreateCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int) (string, openai.Usage, error) {
+	return p.complete(ctx, model, message, maxTokens, nil)
+}
+
+// CreateStructuredCompletion has the model call a tool whose input is the
+// schema, which is the Messages API's way of getting JSON out of it, and
+// returns the input of the call.
+func (p *anthropicProvider) CreateStructuredCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int, schema Schema) (string, openai.Usage, error) {
+	return p.complete(ctx, model, message, maxTokens, &schema)
+}
+
+func (p *anthropicProvider) complete(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int, schema *Schema) (string, openai.Usage, error) {
+	if alias, ok := anthropicModels[model]; ok {
+		model = alias
+	}
+	if maxTokens <= 0 {
+		// The Messages API requires a limit.
+		maxTokens = 4096
+	}
+	request := map[string]any{
+		"model":      model,
+		"max_tokens": maxTokens,
+		"messages": []map[string]any{
+			{"role": "user", "content": anthropicMessage(message)},
+		},
+	}
+	if p.temperature != nil {
+		request["temperature"] = *p.temperature
+	}
+	if schema != nil {
+		request["tools"] = []map[string]any{
+			{"name": schema.Name, "description": schema.Description, "input_schema": schema.Schema},
+		}
+		request["tool_choice"] = map[string]any{"type": "tool", "name": schema.Name}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", p.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", openai.Usage{}, fmt.Errorf("failed to read Anthropic API response: %w", err)
+	}
+
+	var parsed struct {
+		Content []anthropicContent `json:"content"`
+		Usage   struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", openai.Usage{}, fmt.Errorf("unexpected Anthropic API response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if parsed.Error != nil {
+			return "", openai.Usage{}, &statusError{status: resp.StatusCode, message: fmt.Sprintf("Anthropic API error (status %d, %s): %s", resp.StatusCode, parsed.Error.Type, parsed.Error.Message)}
+		}
+		return "", openai.Usage{}, &statusError{status: resp.StatusCode, message: fmt.Sprintf("Anthropic API error (status %d)", resp.StatusCode)}
+	}
+
+	usage := openai.Usage{
+		PromptTokens:     parsed.Usage.InputTokens,
+		CompletionTokens: parsed.Usage.OutputTokens,
+		TotalTokens:      parsed.Usage.InputTokens + parsed.Usage.OutputTokens,
+	}
+	var text strings.Builder
+	for _, block := range parsed.Content {
+		if schema != nil {
+			if block.Type == "tool_use" && len(block.Input) > 0 {
+				return string(block.Input), usage, nil
+			}
+			continue
+		}
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", usage, fmt.Errorf("no response from Anthropic API")
+	}
+	return text.String(), usage, nil
+}
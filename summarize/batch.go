@@ -0,0 +1,167 @@
+package summarize
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// batchPrompt asks for the summaries of several categories in one request,
+// as a JSON object keyed by category.
+//
+//go:embed prompts/batch.tmpl
+var batchPrompt string
+
+var batchTemplate = template.Must(template.New("batch").Parse(batchPrompt))
+
+// batches groups categories by the model summarizing them, since a request
+// goes to a single model, in the order of the models.
+func batches(categories []string, opts Options) [][]string {
+	byModel := make(map[string][]string)
+	for _, category := range categories {
+		model := opts.ModelFor(category)
+		byModel[model] = append(byModel[model], category)
+	}
+	var groups [][]string
+	for _, model := range slices.Sorted(maps.Keys(byModel)) {
+		group := byModel[model]
+		slices.Sort(group)
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// BatchPrompts returns the prompts ByCategory sends with Options.Batch, one
+// per model, e.g. to show them without calling the model. Raw-only
+// categories are left out.
+func BatchPrompts(categories map[string][]worklog.Item, opts Options) ([]string, error) {
+	var prompts []string
+	for _, group := range batches(summarizedCategories(categories, opts), opts) {
+		prompt, err := batchPromptFor(group, categories, opts)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+func batchPromptFor(group []string, categories map[string][]worklog.Item, opts Options) (string, error) {
+	data := make([]PromptData, len(group))
+	for i, category := range group {
+		data[i] = PromptData{Category: category, Items: sanitizeTitles(worklog.Titles(categories[category]))}
+	}
+	var sb strings.Builder
+	if err := batchTemplate.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render the batched prompt: %w", err)
+	}
+	return WithContext(opts.Context, InLanguage(opts.Language, WithBudget(opts.Budget, sb.String()))), nil
+}
+
+// summarizeBatched summarizes the given categories with one request per
+// model and stores their bullet points in result. It returns the categories
+// left to summarize one by one: those of a request that failed or whose
+// response wasn't the expected JSON, and those missing from a response or
+// whose summary strays from their items or exceeds the budget.
+func summarizeBatched(ctx context.Context, pending []string, categories map[string][]worklog.Item, result map[string][]string, opts Options) []string {
+	var left []string
+	for _, group := range batches(pending, opts) {
+		summaries, err := requestBatch(ctx, group, categories, opts)
+		if err != nil {
+			log.Printf("WARNING: Summarizing the categories one by one instead: %v", err)
+			left = append(left, group...)
+			continue
+		}
+		for _, category := range group {
+			bullets, ok := summaries[strings.ToLower(category)]
+			if !ok {
+				log.Printf("WARNING: The batched response has no summary for category '%s', summarizing it on its own", category)
+				left = append(left, category)
+				continue
+			}
+			if len(bullets) == 0 {
+				log.Printf("WARNING: Empty summary received for category '%s'", category)
+			}
+			if err := checkSummary(category, bullets, categories[category]); err != nil {
+				log.Printf("WARNING: Summarizing the category on its own instead: %v", err)
+				left = append(left, category)
+				continue
+			}
+			if len(bullets) > 0 {
+				if problems := opts.Budget.violations(bullets[1:], len(categories[category])); len(problems) > 0 {
+					log.Printf("INFO: The batched summary of category '%s' exceeds the budget (%s), summarizing it on its own", category, strings.Join(problems, "; "))
+					left = append(left, category)
+					continue
+				}
+			}
+			result[category] = bullets
+			cache(category, categories[category], bullets, opts)
+		}
+	}
+	return left
+}
+
+// requestBatch sends the batched prompt of group and returns the bullet
+// points of every category in the response, keyed by the lowercase
+// category.
+func requestBatch(ctx context.Context, group []string, categories map[string][]worklog.Item, opts Options) (map[string][]string, error) {
+	prompt, err := batchPromptFor(group, categories, opts)
+	if err != nil {
+		return nil, err
+	}
+	model, maxTokens := opts.ModelFor(group[0]), opts.maxTokens()*len(group)
+	responseText, err := opts.Client.CompleteStructured(ctx, model, prompt, maxTokens, batchSchema(group))
+	if errors.Is(err, errStructuredUnsupported) {
+		responseText, err = opts.Client.Complete(ctx, model, prompt, maxTokens)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error calling LLM API for categories '%s': %w", strings.Join(group, "', '"), err)
+	}
+	return parseBatchResponse(responseText)
+}
+
+// parseBatchResponse reads the JSON object of a batched response. Models not
+// held to the schema sometimes answer with a list of key points or a
+// Markdown summary per category instead, which are read as well. Categories
+// whose object has neither a summary nor key points are left out.
+func parseBatchResponse(text string) (map[string][]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimSpace(StripCodeFence(text))), &raw); err != nil {
+		return nil, fmt.Errorf("the batched response is not a JSON object: %w", err)
+	}
+	summaries := make(map[string][]string, len(raw))
+	for category, value := range raw {
+		var bullets []string
+		var summary structuredSummary
+		var markdown string
+		switch {
+		case json.Unmarshal(value, &summary) == nil:
+			if summary.Summary == nil && summary.KeyPoints == nil {
+				// An empty or unrelated object: summarize the category
+				// on its own rather than caching an empty summary.
+				continue
+			}
+			bullets = summary.bullets()
+		case json.Unmarshal(value, &bullets) == nil:
+		case json.Unmarshal(value, &markdown) == nil:
+			bullets = ParseSummary(markdown)
+		default:
+			return nil, fmt.Errorf("the batched summary of category '%s' is neither a summary, a list, nor text", category)
+		}
+		bullets = slices.DeleteFunc(bullets, func(bullet string) bool { return bullet == "" })
+		for i, bullet := range bullets {
+			bullets[i] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(bullet), "- "))
+		}
+		summaries[strings.ToLower(strings.TrimSpace(category))] = bullets
+	}
+	return summaries, nil
+}
@@ -0,0 +1,69 @@
+package summarize
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseBatchResponse checks the shapes of batched responses models
+// answer with, and that a category without a summary is left out to be
+// summarized on its own.
+func TestParseBatchResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "objects",
+			text: `{"bugs": {"summary": "Fixed  crashes.", "key_points": ["- Draft crash", ""]}, "Features": {"summary": "Shipped login.", "key_points": []}}`,
+			want: map[string][]string{
+				"bugs":     {"Fixed crashes.", "Draft crash"},
+				"features": {"Shipped login."},
+			},
+		},
+		{
+			name: "code fence",
+			text: "```json\n{\"bugs\": {\"summary\": \"Fixed crashes.\"}}\n```",
+			want: map[string][]string{"bugs": {"Fixed crashes."}},
+		},
+		{
+			name: "list of key points",
+			text: `{"bugs": ["- Draft crash", "", "Save crash"]}`,
+			want: map[string][]string{"bugs": {"Draft crash", "Save crash"}},
+		},
+		{
+			name: "markdown",
+			text: `{"bugs": "Fixed crashes.\n\n- Draft crash\n- Save crash"}`,
+			want: map[string][]string{"bugs": {"Fixed crashes.", "Draft crash", "Save crash"}},
+		},
+		{
+			name: "empty object",
+			text: `{"bugs": {}, "features": {"summary": "Shipped login."}}`,
+			want: map[string][]string{"features": {"Shipped login."}},
+		},
+		{
+			name:    "not an object",
+			text:    `["Fixed crashes."]`,
+			wantErr: true,
+		},
+		{
+			name:    "number",
+			text:    `{"bugs": 3}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBatchResponse(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBatchResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBatchResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Budget keeps summaries tight by limiting their key points: each may cover
+// at most ItemsPerBullet items in at most Words words. Summaries exceeding it
+// are asked for again, see enforceBudget. A zero limit is no limit.
+type Budget struct {
+	Words          int
+	ItemsPerBullet int
+}
+
+// budgetRetries is how often a summary exceeding the budget is asked for
+// again before it is used as it is.
+const budgetRetries = 1
+
+// budgetRetryPrompt follows the original prompt when asking again for a
+// summary exceeding the budget.
+const budgetRetryPrompt = `
+
+Your previous response was:
+<response>
+%s
+</response>
+
+It exceeds the limits: %s. Rewrite it to stay within them, keeping the facts and the format.`
+
+// WithBudget adds the limits of budget to prompt, if it has any.
+func WithBudget(budget Budget, prompt string) string {
+	var limits []string
+	if budget.ItemsPerBullet > 0 {
+		limits = append(limits, fmt.Sprintf("draw on at most %d %s", budget.ItemsPerBullet, worklog.Pluralize(budget.ItemsPerBullet, "item", "items")))
+	}
+	if budget.Words > 0 {
+		limits = append(limits, fmt.Sprintf("be at most %d words long", budget.Words))
+	}
+	if len(limits) == 0 {
+		return prompt
+	}
+	return prompt + fmt.Sprintf("\n\nEach key point must %s.", strings.Join(limits, " and "))
+}
+
+// violations describes how the key points of a summary of the given number
+// of items exceed the budget; there are none if they don't. Covering at most
+// ItemsPerBullet items each takes at least one key point per that many
+// items.
+func (b Budget) violations(keyPoints []string, items int) []string {
+	var problems []string
+	if b.Words > 0 {
+		for i, point := range keyPoints {
+			if words := len(strings.Fields(point)); words > b.Words {
+				problems = append(problems, fmt.Sprintf("key point %d has %d words instead of at most %d", i+1, words, b.Words))
+			}
+		}
+	}
+	if b.ItemsPerBullet > 0 && len(keyPoints) > 0 {
+		if needed := (items + b.ItemsPerBullet - 1) / b.ItemsPerBullet; len(keyPoints) < needed {
+			problems = append(problems, fmt.Sprintf("%d items need at least %d key points to draw on at most %d %s each, not %d",
+				items, needed, b.ItemsPerBullet, worklog.Pluralize(b.ItemsPerBullet, "item", "items"), len(keyPoints)))
+		}
+	}
+	return problems
+}
+
+// enforceBudget asks the model again, up to budgetRetries times, for the
+// summary of category while its bullet points, the summary first, exceed
+// opts.Budget, and returns the last summary. A summary still exceeding the
+// budget is used with a warning, as is the previous one when asking again
+// fails.
+func enforceBudget(ctx context.Context, category string, prompt string, bullets []string, items int, opts Options) []string {
+	for range budgetRetries {
+		if len(bullets) == 0 {
+			return bullets
+		}
+		problems := opts.Budget.violations(bullets[1:], items)
+		if len(problems) == 0 {
+			return bullets
+		}
+		log.Printf("INFO: The summary of category '%s' exceeds the budget (%s), asking again", category, strings.Join(problems, "; "))
+		previous := bullets[0] + "\n\n- " + strings.Join(bullets[1:], "\n- ")
+		retried, err := requestSummary(ctx, category, prompt+fmt.Sprintf(budgetRetryPrompt, previous, strings.Join(problems, "; ")), opts)
+		if err != nil {
+			log.Printf("WARNING: Keeping the summary of category '%s' exceeding the budget: %v", category, err)
+			return bullets
+		}
+		bullets = retried
+	}
+	if problems := opts.Budget.violations(bullets[min(1, len(bullets)):], items); len(problems) > 0 {
+		log.Printf("WARNING: The summary of category '%s' still exceeds the budget: %s", category, strings.Join(problems, "; "))
+	}
+	return bullets
+}
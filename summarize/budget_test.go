@@ -0,0 +1,77 @@
+package summarize
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestBudgetViolations checks which key points exceed a budget; a summary
+// without key points exceeds none.
+func TestBudgetViolations(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    Budget
+		keyPoints []string
+		items     int
+		want      []string
+	}{
+		{
+			name:      "no limits",
+			keyPoints: []string{"one two three four five six"},
+			items:     10,
+		},
+		{
+			name:      "within the limits",
+			budget:    Budget{Words: 5, ItemsPerBullet: 2},
+			keyPoints: []string{"Fixed the draft crash", "Documented retries"},
+			items:     4,
+		},
+		{
+			name:      "too many words",
+			budget:    Budget{Words: 3},
+			keyPoints: []string{"Fixed crashes", "Fixed the draft crash"},
+			items:     2,
+			want:      []string{"key point 2 has 4 words instead of at most 3"},
+		},
+		{
+			name:      "too few key points",
+			budget:    Budget{ItemsPerBullet: 2},
+			keyPoints: []string{"Fixed crashes"},
+			items:     3,
+			want:      []string{"3 items need at least 2 key points to draw on at most 2 items each, not 1"},
+		},
+		{
+			name:   "no key points",
+			budget: Budget{ItemsPerBullet: 1},
+			items:  3,
+		},
+		{
+			name:      "both",
+			budget:    Budget{Words: 1, ItemsPerBullet: 1},
+			keyPoints: []string{"Fixed crashes"},
+			items:     2,
+			want: []string{
+				"key point 1 has 2 words instead of at most 1",
+				"2 items need at least 2 key points to draw on at most 1 item each, not 1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.budget.violations(tt.keyPoints, tt.items); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	if got := WithBudget(Budget{}, "Summarize."); got != "Summarize." {
+		t.Errorf("prompt without a budget = %q", got)
+	}
+	want := "Summarize.\n\nEach key point must draw on at most 1 item and be at most 12 words long."
+	if got := WithBudget(Budget{Words: 12, ItemsPerBullet: 1}, "Summarize."); got != want {
+		t.Errorf("prompt with a budget = %q, want %q", got, want)
+	}
+}
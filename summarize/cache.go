@@ -0,0 +1,57 @@
+package summarize
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Cache stores the summaries of categories by a hash of everything that
+// goes into them, so a category whose items didn't change isn't summarized
+// again, e.g. when regenerating a week after fixing a typo in another
+// category. Its methods may be called concurrently.
+type Cache interface {
+	// Get returns the bullet points stored under key.
+	Get(key string) ([]string, bool)
+	// Put stores the bullet points of a summary under key.
+	Put(key string, bullets []string)
+}
+
+// cacheKey identifies the summary of the items of category: the provider
+// and model, the limit on its length, and the prompt with the items, the
+// context, and the language. Summaries of a batched request are stored under the same key.
+func cacheKey(category string, items []worklog.Item, opts Options) (string, error) {
+	prompt, err := SummaryPrompt(category, items, opts)
+	if err != nil {
+		return "", err
+	}
+	provider := ""
+	if opts.Client != nil {
+		provider = opts.Client.Provider.Name()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", provider, opts.ModelFor(category), opts.maxTokens(), prompt)))
+	return fmt.Sprintf("%x", sum), nil
+}
+
+// cached returns the cached summary of category, if any.
+func cached(category string, items []worklog.Item, opts Options) ([]string, bool) {
+	if opts.Cache == nil {
+		return nil, false
+	}
+	key, err := cacheKey(category, items, opts)
+	if err != nil {
+		return nil, false
+	}
+	return opts.Cache.Get(key)
+}
+
+// cache stores the summary of category.
+func cache(category string, items []worklog.Item, bullets []string, opts Options) {
+	if opts.Cache == nil || len(bullets) == 0 {
+		return
+	}
+	if key, err := cacheKey(category, items, opts); err == nil {
+		opts.Cache.Put(key, bullets)
+	}
+}
@@ -0,0 +1,149 @@
+// Package summarize turns the items of a worklog into summaries with a
+// language model, and provides the client every model call goes through.
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultModel is used when no other model is configured. Providers other
+// than OpenAI replace it with their own default.
+const DefaultModel = "gpt-4o-mini"
+
+// Client talks to the API of a model provider. Its hooks let the caller
+// limit, meter, and trace all model calls of a run in one place.
+type Client struct {
+	// Client gives access to OpenAI-only APIs, such as transcription. It is
+	// nil for other providers.
+	*openai.Client
+	// Provider sends the completions.
+	Provider Provider
+	// Limiter spaces out requests; a nil limiter doesn't limit.
+	Limiter *RateLimiter
+	// OnUsage, if set, receives the token usage of every completion with the
+	// model that used it.
+	OnUsage func(model string, usage openai.Usage)
+	// OnPrompt, if set, receives every prompt with the model's response.
+	OnPrompt func(model string, prompt string, response string)
+
+	// structuredRefused is set once the provider refused a structured
+	// completion.
+	structuredRefused atomic.Bool
+}
+
+// NewClient creates a client for apiKey. A nil httpClient uses the default
+// one; a custom client can e.g. record or replay the API responses.
+func NewClient(apiKey string, httpClient *http.Client) *Client {
+	return newOpenAIClient(Config{Provider: ProviderOpenAI, APIKey: apiKey, HTTPClient: httpClient}, "OpenAI", DefaultModel)
+}
+
+// Complete sends a single-message prompt and returns the text of the first
+// choice.
+func (c *Client) Complete(ctx context.Context, model string, prompt string, maxTokens int) (string, error) {
+	return c.CompleteMessage(ctx, model, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: prompt,
+	}, maxTokens)
+}
+
+// CompleteMessage is Complete for messages with several parts, such as a
+// prompt and an image.
+func (c *Client) CompleteMessage(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int) (string, error) {
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	if model == "" || model == DefaultModel {
+		model = c.Provider.DefaultModel()
+	}
+	text, usage, err := c.Provider.CreateCompletion(ctx, model, message, maxTokens)
+	if c.OnUsage != nil {
+		c.OnUsage(model, usage)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if c.OnPrompt != nil {
+		c.OnPrompt(model, MessageText(message), text)
+	}
+	return text, nil
+}
+
+// MessageText returns the text of a message, with placeholders for images.
+func MessageText(message openai.ChatCompletionMessage) string {
+	if len(message.MultiContent) == 0 {
+		return message.Content
+	}
+	var parts []string
+	for _, part := range message.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeImageURL {
+			parts = append(parts, "[image]")
+		} else {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// WithContext prepends the user's glossary/context file to a prompt, so the
+// model knows the team's projects and acronyms.
+func WithContext(background string, prompt string) string {
+	if strings.TrimSpace(background) == "" {
+		return prompt
+	}
+	return fmt.Sprintf(`Background information about the author's team, projects, and terminology. Use it to interpret and spell names and acronyms correctly; do not summarize it.
+<context>
+%s
+</context>
+
+%s`, strings.TrimSpace(background), prompt)
+}
+
+// RateLimiter spaces out requests evenly, however many goroutines make them.
+// A nil limiter doesn't limit.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter allows perMinute requests per minute, or any number if
+// perMinute is not positive.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the next request may be made.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
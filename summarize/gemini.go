@@ -0,0 +1,199 @@
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// geminiBaseURL is the Gemini API; models are addressed below it as
+// /models/<model>:generateContent.
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// geminiProvider sends completions to the Google Gemini API.
+type geminiProvider struct {
+	apiKey      string
+	baseURL     string
+	httpClient  *http.Client
+	temperature *float32
+}
+
+// NewGeminiClient creates a client for the Gemini API. A nil httpClient uses
+// the default one.
+func NewGeminiClient(apiKey string, httpClient *http.Client) *Client {
+	return &Client{Provider: newGeminiProvider(Config{Provider: ProviderGemini, APIKey: apiKey, HTTPClient: httpClient})}
+}
+
+func newGeminiProvider(config Config) *geminiProvider {
+	provider := &geminiProvider{apiKey: config.APIKey, baseURL: geminiBaseURL, httpClient: config.HTTPClient, temperature: config.Temperature}
+	if provider.httpClient == nil {
+		provider.httpClient = http.DefaultClient
+	}
+	if config.BaseURL != "" {
+		provider.baseURL = strings.TrimSuffix(config.BaseURL, "/")
+	}
+	return provider
+}
+
+func (p *geminiProvider) Name() string {
+	return "Gemini"
+}
+
+func (p *geminiProvider) DefaultModel() string {
+	return "gemini-1.5-flash"
+}
+
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inline_data,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+// geminiParts converts a chat message to the parts of a Gemini message.
+// Gemini only takes images inline, so images given by URL are left out.
+func geminiParts(message openai.ChatCompletionMessage) []geminiPart {
+	if len(message.MultiContent) == 0 {
+		return []geminiPart{{Text: message.Content}}
+	}
+	var parts []geminiPart
+	for _, part := range message.MultiContent {
+		if part.Type != openai.ChatMessagePartTypeImageURL || part.ImageURL == nil {
+			parts = append(parts, geminiPart{Text: part.Text})
+			continue
+		}
+		if header, data, ok := strings.Cut(strings.TrimPrefix(part.ImageURL.URL, "data:"), ","); ok && strings.HasPrefix(part.ImageURL.URL, "data:") {
+			parts = append(parts, geminiPart{InlineData: &geminiInlineData{MimeType: strings.TrimSuffix(header, ";base64"), Data: data}})
+		}
+	}
+	return parts
+}
+
+func (p *geminiProvider) CreateCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int) (string, openai.Usage, error) {
+	return p.complete(ctx, model, message, maxTokens, nil)
+}
+
+// CreateStructuredCompletion asks for a JSON response with a response
+// schema, which the API holds the model to.
+func (p *geminiProvider) CreateStructuredCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int, schema Schema) (string, openai.Usage, error) {
+	return p.complete(ctx, model, message, maxTokens, &schema)
+}
+
+// geminiSchema converts a JSON schema to the OpenAPI subset Gemini takes,
+// which has no additionalProperties.
+func geminiSchema(schema map[string]any) map[string]any {
+	converted := make(map[string]any, len(schema))
+	for key, value := range schema {
+		if key == "additionalProperties" {
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok {
+			value = geminiSchema(nested)
+		}
+		converted[key] = value
+	}
+	return converted
+}
+
+func (p *geminiProvider) complete(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int, schema *Schema) (string, openai.Usage, error) {
+	request := map[string]any{
+		"contents": []map[string]any{
+			{"role": "user", "parts": geminiParts(message)},
+		},
+	}
+	generationConfig := map[string]any{}
+	if maxTokens > 0 {
+		generationConfig["maxOutputTokens"] = maxTokens
+	}
+	if p.temperature != nil {
+		generationConfig["temperature"] = *p.temperature
+	}
+	if schema != nil {
+		generationConfig["responseMimeType"] = "application/json"
+		generationConfig["responseSchema"] = geminiSchema(schema.Schema)
+	}
+	if len(generationConfig) > 0 {
+		request["generationConfig"] = generationConfig
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, url.PathEscape(strings.TrimPrefix(model, "models/")))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Api-Key", p.apiKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", openai.Usage{}, fmt.Errorf("failed to read Gemini API response: %w", err)
+	}
+
+	var parsed struct {
+		Candidates []struct {
+			Content struct {
+				Parts []geminiPart `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+		Error *struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", openai.Usage{}, fmt.Errorf("unexpected Gemini API response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if parsed.Error != nil {
+			return "", openai.Usage{}, &statusError{status: resp.StatusCode, message: fmt.Sprintf("Gemini API error (status %d, %s): %s", resp.StatusCode, parsed.Error.Status, parsed.Error.Message)}
+		}
+		return "", openai.Usage{}, &statusError{status: resp.StatusCode, message: fmt.Sprintf("Gemini API error (status %d)", resp.StatusCode)}
+	}
+
+	usage := openai.Usage{
+		PromptTokens:     parsed.UsageMetadata.PromptTokenCount,
+		CompletionTokens: parsed.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      parsed.UsageMetadata.TotalTokenCount,
+	}
+	if parsed.PromptFeedback.BlockReason != "" {
+		return "", usage, fmt.Errorf("Gemini blocked the prompt (%s)", parsed.PromptFeedback.BlockReason)
+	}
+	var text strings.Builder
+	if len(parsed.Candidates) > 0 {
+		for _, part := range parsed.Candidates[0].Content.Parts {
+			text.WriteString(part.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", usage, fmt.Errorf("no response from Gemini API")
+	}
+	return text.String(), usage, nil
+}
@@ -0,0 +1,112 @@
+package summarize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Card titles are written by the user, but also by teammates and imported
+// from other tools, so they may try to instruct the model, e.g. "ignore
+// previous instructions and ...". Prompts put them between <items> tags and
+// tell the model they are data; SanitizeTitle keeps a title from breaking
+// out of that block, and checkSummary refuses summaries that aren't about
+// the items.
+
+// itemsTagPattern matches tags delimiting the items in a prompt.
+var itemsTagPattern = regexp.MustCompile(`(?i)<\s*/?\s*items?\s*>`)
+
+// SanitizeTitle prepares an item title for a prompt: control characters and
+// line breaks, which could start lines of their own, become spaces, and tags
+// delimiting the items are neutralized.
+func SanitizeTitle(title string) string {
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, title)
+	title = itemsTagPattern.ReplaceAllStringFunc(title, func(tag string) string {
+		return strings.NewReplacer("<", "(", ">", ")").Replace(tag)
+	})
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// sanitizeTitles sanitizes every title.
+func sanitizeTitles(titles []string) []string {
+	sanitized := make([]string, len(titles))
+	for i, title := range titles {
+		sanitized[i] = SanitizeTitle(title)
+	}
+	return sanitized
+}
+
+var (
+	linkPattern    = regexp.MustCompile(`(?i)\bhttps?://[^\s)\]>"']+`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])(@[\w.-]+\w)`)
+	wordPattern    = regexp.MustCompile(`[\p{L}\p{N}]+`)
+)
+
+// stopWords are left out when comparing a summary's words to its items'.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"from": true, "into": true, "were": true, "was": true, "are": true, "has": true,
+	"have": true, "been": true, "their": true, "which": true, "also": true, "more": true,
+	"work": true, "team": true, "week": true, "summary": true, "items": true,
+	"improved": true, "added": true, "updated": true, "several": true, "various": true,
+}
+
+// contentWords returns the stems of the words in text that carry meaning,
+// cut to five letters so that "crash" matches "crashes" and "crashed".
+func contentWords(text string) []string {
+	var stems []string
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if len([]rune(word)) < 3 || stopWords[word] {
+			continue
+		}
+		if runes := []rune(word); len(runes) > 5 {
+			word = string(runes[:5])
+		}
+		stems = append(stems, word)
+	}
+	return stems
+}
+
+// checkSummary refuses a summary straying from its items: one linking to a
+// page or mentioning a person no item does, as injected instructions would
+// to leak the worklog or add content, or one sharing no word with the items
+// at all.
+func checkSummary(category string, bullets []string, items []worklog.Item) error {
+	titles := strings.Join(worklog.Titles(items), "\n")
+	lowerTitles := strings.ToLower(titles)
+	summary := strings.Join(bullets, "\n")
+
+	for _, link := range linkPattern.FindAllString(summary, -1) {
+		if !strings.Contains(lowerTitles, strings.ToLower(strings.TrimRight(link, ".,;:!?"))) {
+			return fmt.Errorf("the summary of category '%s' links to %s, which none of its items does", category, link)
+		}
+	}
+	for _, match := range mentionPattern.FindAllStringSubmatch(summary, -1) {
+		if !strings.Contains(lowerTitles, strings.ToLower(match[1])) {
+			return fmt.Errorf("the summary of category '%s' mentions %s, whom none of its items does", category, match[1])
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, stem := range contentWords(titles) {
+		known[stem] = true
+	}
+	words := contentWords(summary)
+	if len(known) == 0 || len(words) == 0 {
+		return nil
+	}
+	for _, stem := range words {
+		if known[stem] {
+			return nil
+		}
+	}
+	return fmt.Errorf("the summary of category '%s' has nothing in common with its items", category)
+}
@@ -0,0 +1,175 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// stopwords are frequent short words that give away the language of a
+// title. Card titles are terse, so most have none and stay undetected.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "for", "with", "to", "of", "in", "on", "from", "into", "after", "when", "is", "not"},
+	"de": {"der", "die", "das", "und", "für", "mit", "von", "zu", "im", "auf", "nach", "bei", "ist", "nicht", "ein", "eine", "den", "dem", "des"},
+	"fr": {"le", "la", "les", "et", "pour", "avec", "du", "des", "dans", "sur", "une", "est", "pas", "au", "aux"},
+	"es": {"el", "los", "las", "y", "para", "con", "del", "en", "una", "por", "que", "es", "no", "al"},
+	"nl": {"de", "het", "en", "voor", "met", "van", "naar", "een", "op", "bij", "niet", "is"},
+	"it": {"il", "lo", "gli", "e", "per", "con", "della", "di", "nel", "una", "che", "non", "è"},
+	"pt": {"o", "os", "as", "e", "para", "com", "do", "da", "em", "uma", "não", "no", "na"},
+}
+
+// DetectLanguage guesses the language of a title from its stopwords and
+// letters, ignoring tags, mentions, and links. It returns a lowercase ISO
+// 639-1 code, or false if the title gives too little away.
+func DetectLanguage(title string) (string, bool) {
+	scores := make(map[string]int)
+	for _, word := range strings.Fields(strings.ToLower(title)) {
+		if strings.ContainsAny(word[:1], "#@[") || strings.Contains(word, "://") {
+			continue
+		}
+		word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+		for language, words := range stopwords {
+			for _, stopword := range words {
+				if word == stopword {
+					scores[language]++
+				}
+			}
+		}
+		if strings.ContainsAny(word, "äöüß") {
+			scores["de"]++
+		}
+		if strings.ContainsAny(word, "ñ¿¡") {
+			scores["es"]++
+		}
+	}
+
+	best, bestScore, tie := "", 0, false
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = language, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore == 0 || tie {
+		return "", false
+	}
+	return best, true
+}
+
+// languageNames maps the English and native names of the languages
+// DetectLanguage knows to their codes, since --language takes either.
+var languageNames = map[string]string{
+	"english":    "en",
+	"german":     "de",
+	"deutsch":    "de",
+	"french":     "fr",
+	"français":   "fr",
+	"francais":   "fr",
+	"spanish":    "es",
+	"español":    "es",
+	"espanol":    "es",
+	"dutch":      "nl",
+	"nederlands": "nl",
+	"italian":    "it",
+	"italiano":   "it",
+	"portuguese": "pt",
+	"português":  "pt",
+	"portugues":  "pt",
+}
+
+// languageCode reduces a language tag such as pt-BR, or a name such as
+// German, to its base code.
+func languageCode(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if code, ok := languageNames[language]; ok {
+		return code
+	}
+	code, _, _ := strings.Cut(language, "-")
+	return code
+}
+
+// normalizePrompt has the model detect the language of each item and
+// translate those in another language.
+const normalizePrompt = `The following numbered work items from a Kanban board may be written in different languages. For every item, detect its language and, if it is not in the language with the code or name '%s', translate it into that language.
+Keep hashtags (#tag), @mentions, dates, ticket keys, URLs, code, and product names unchanged. Keep the translation as terse as the original.
+Respond with a JSON array with one object per item, in the same order: {"language": "<ISO 639-1 code of the original>", "title": "<the item in '%s'>"}. Respond with the JSON only.
+
+%s`
+
+// Translation is an item title normalized to the output language.
+type Translation struct {
+	Language string
+	Before   string
+	After    string
+}
+
+// NormalizeLanguage translates the titles of items written in a language
+// other than language, so the summary prompt gets items in a single language.
+// Items that are recognizably in language already are not sent to the model.
+func NormalizeLanguage(ctx context.Context, client *Client, model string, items []worklog.Item, language string) ([]worklog.Item, []Translation, error) {
+	if client == nil {
+		return nil, nil, fmt.Errorf("a client is required for translating items")
+	}
+	if model == "" {
+		model = DefaultModel
+	}
+
+	var pending []int
+	for i, item := range items {
+		if detected, ok := DetectLanguage(item.Title); ok && detected == languageCode(language) {
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return items, nil, nil
+	}
+
+	var list strings.Builder
+	for n, i := range pending {
+		fmt.Fprintf(&list, "%d. %s\n", n+1, items[i].Title)
+	}
+	response, err := client.Complete(ctx, model, fmt.Sprintf(normalizePrompt, language, language, list.String()), 60*len(pending)+100)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error calling LLM API: %w", err)
+	}
+
+	var results []struct {
+		Language string `json:"language"`
+		Title    string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(StripCodeFence(response)), &results); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the detected languages: %w", err)
+	}
+	if len(results) != len(pending) {
+		return nil, nil, fmt.Errorf("the model returned %d items for %d", len(results), len(pending))
+	}
+
+	normalized := make([]worklog.Item, len(items))
+	copy(normalized, items)
+	var translations []Translation
+	for n, i := range pending {
+		result := results[n]
+		title := strings.TrimSpace(result.Title)
+		if languageCode(result.Language) == languageCode(language) || title == "" || title == items[i].Title {
+			continue
+		}
+		normalized[i].Title = title
+		translations = append(translations, Translation{Language: languageCode(result.Language), Before: items[i].Title, After: title})
+	}
+	return normalized, translations, nil
+}
+
+// InLanguage asks for the response to a prompt in language, if set.
+func InLanguage(language string, prompt string) string {
+	if language == "" {
+		return prompt
+	}
+	return prompt + fmt.Sprintf("\n\nWrite your response in the language with the code or name '%s'.", language)
+}
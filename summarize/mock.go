@@ -0,0 +1,39 @@
+package summarize
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+	"github.com/sashabaranov/go-openai"
+)
+
+// mockProvider answers every prompt locally without calling a model, e.g.
+// for demos and screenshots on real data. Its answer lists the items of the
+// prompt, so summaries look like the real thing without revealing anything
+// a model wrote.
+type mockProvider struct{}
+
+// NewMockClient creates a client whose completions never leave the process.
+func NewMockClient() *Client {
+	return &Client{Provider: mockProvider{}}
+}
+
+func (mockProvider) Name() string {
+	return "mock"
+}
+
+func (mockProvider) DefaultModel() string {
+	return "mock"
+}
+
+func (mockProvider) CreateCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int) (string, openai.Usage, error) {
+	var sb strings.Builder
+	sb.WriteString("Placeholder summary written by the mock provider, which doesn't call a model.\n\n")
+	for _, line := range strings.Split(MessageText(message), "\n") {
+		if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok {
+			sb.WriteString("- " + worklog.CleanTitle(item) + "\n")
+		}
+	}
+	return sb.String(), openai.Usage{}, nil
+}
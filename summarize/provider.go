@@ -0,0 +1,240 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Providers of language models. Ollama runs models locally and is talked to
+// through its OpenAI-compatible API; Azure serves OpenAI models from
+// deployments in an Azure OpenAI resource. The mock provider calls no model
+// at all.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+	ProviderOllama    = "ollama"
+	ProviderAzure     = "azure"
+	ProviderMock      = "mock"
+)
+
+// Providers lists the supported providers.
+var Providers = []string{ProviderOpenAI, ProviderAnthropic, ProviderGemini, ProviderOllama, ProviderAzure, ProviderMock}
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used when none is
+// configured.
+const DefaultAzureAPIVersion = "2024-06-01"
+
+const (
+	ollamaBaseURL = "http://localhost:11434/v1"
+	ollamaModel   = "llama3"
+)
+
+// Config selects and sets up the provider of a client.
+type Config struct {
+	Provider string
+	APIKey   string
+	// BaseURL replaces the provider's API endpoint, e.g. a proxy or, for
+	// openai, any server with an OpenAI-compatible API.
+	BaseURL string
+	// HTTPClient sends the requests; nil uses the default client. A custom
+	// client can e.g. record or replay the API responses.
+	HTTPClient *http.Client
+	// Temperature is the sampling temperature of every completion; nil
+	// leaves it to the provider. Lower values give more predictable
+	// summaries.
+	Temperature *float32
+	// Azure sets up the azure provider, whose BaseURL is the endpoint of
+	// the resource, e.g. https://my-resource.openai.azure.com.
+	Azure AzureConfig
+}
+
+// AzureConfig sets up an Azure OpenAI client.
+type AzureConfig struct {
+	// APIVersion is the version of the API; empty uses
+	// DefaultAzureAPIVersion.
+	APIVersion string
+	// Deployments maps models to the deployments serving them.
+	Deployments map[string]string
+	// Deployment serves the models without an entry in Deployments; empty
+	// uses the model name, without dots, as the deployment name.
+	Deployment string
+	// AD authenticates with a Microsoft Entra ID (Azure AD) access token as
+	// the API key instead of a key of the resource.
+	AD bool
+}
+
+// deployment returns the deployment serving model.
+func (c AzureConfig) deployment(model string) string {
+	if deployment := c.Deployments[model]; deployment != "" {
+		return deployment
+	}
+	if c.Deployment != "" {
+		return c.Deployment
+	}
+	return strings.NewReplacer(".", "", ":", "").Replace(model)
+}
+
+// NewProviderClient creates a client for the provider of config.
+func NewProviderClient(config Config) (*Client, error) {
+	switch config.Provider {
+	case ProviderOpenAI, "":
+		return newOpenAIClient(config, "OpenAI", DefaultModel), nil
+	case ProviderOllama:
+		if config.BaseURL == "" {
+			config.BaseURL = ollamaBaseURL
+		}
+		if config.APIKey == "" {
+			// Ollama ignores the key, but the client always sends one.
+			config.APIKey = ProviderOllama
+		}
+		return newOpenAIClient(config, "Ollama", ollamaModel), nil
+	case ProviderAnthropic:
+		return &Client{Provider: newAnthropicProvider(config)}, nil
+	case ProviderGemini:
+		return &Client{Provider: newGeminiProvider(config)}, nil
+	case ProviderAzure:
+		return newAzureClient(config)
+	case ProviderMock:
+		return NewMockClient(), nil
+	}
+	return nil, fmt.Errorf("unsupported provider '%s' (expected %s)", config.Provider, strings.Join(Providers, ", "))
+}
+
+// newAzureClient creates a client for the deployments of an Azure OpenAI
+// resource.
+func newAzureClient(config Config) (*Client, error) {
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("the azure provider needs the endpoint of the resource as the base URL")
+	}
+	openaiConfig := openai.DefaultAzureConfig(config.APIKey, strings.TrimSuffix(config.BaseURL, "/"))
+	if config.Azure.AD {
+		openaiConfig.APIType = openai.APITypeAzureAD
+	}
+	openaiConfig.APIVersion = config.Azure.APIVersion
+	if openaiConfig.APIVersion == "" {
+		openaiConfig.APIVersion = DefaultAzureAPIVersion
+	}
+	openaiConfig.AzureModelMapperFunc = config.Azure.deployment
+	if config.HTTPClient != nil {
+		openaiConfig.HTTPClient = config.HTTPClient
+	}
+	client := openai.NewClientWithConfig(openaiConfig)
+	return &Client{Client: client, Provider: &openAIProvider{client: client, name: "Azure OpenAI", defaultModel: DefaultModel, temperature: config.Temperature}}, nil
+}
+
+// NeedsAPIKey reports whether the provider needs an API key; local providers
+// don't.
+func NeedsAPIKey(provider string) bool {
+	return provider != ProviderOllama && provider != ProviderMock
+}
+
+func newOpenAIClient(config Config, name string, defaultModel string) *Client {
+	openaiConfig := openai.DefaultConfig(config.APIKey)
+	if config.BaseURL != "" {
+		openaiConfig.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	}
+	if config.HTTPClient != nil {
+		openaiConfig.HTTPClient = config.HTTPClient
+	}
+	client := openai.NewClientWithConfig(openaiConfig)
+	return &Client{Client: client, Provider: &openAIProvider{client: client, name: name, defaultModel: defaultModel, temperature: config.Temperature}}
+}
+
+// Provider sends completions to the API of one model vendor. Messages and
+// usage use the OpenAI types, which other providers translate from and to.
+type Provider interface {
+	// Name returns the provider's name for log and error messages.
+	Name() string
+	// DefaultModel returns the model used when none is configured.
+	DefaultModel() string
+	// CreateCompletion sends a single message and returns the text of the
+	// response with the tokens used.
+	CreateCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int) (string, openai.Usage, error)
+}
+
+// APIKeyEnv returns the environment variable holding the provider's API key.
+func APIKeyEnv(provider string) string {
+	switch provider {
+	case ProviderAnthropic:
+		return "ANTHROPIC_API_KEY"
+	case ProviderGemini:
+		return "GEMINI_API_KEY"
+	case ProviderAzure:
+		return "AZURE_OPENAI_API_KEY"
+	}
+	return "OPENAI_API_KEY"
+}
+
+// openAIProvider sends completions to the OpenAI chat completions API, or to
+// a server compatible with it.
+type openAIProvider struct {
+	client       *openai.Client
+	name         string
+	defaultModel string
+	temperature  *float32
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) DefaultModel() string {
+	return p.defaultModel
+}
+
+func (p *openAIProvider) CreateCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int) (string, openai.Usage, error) {
+	return p.complete(ctx, openai.ChatCompletionRequest{
+		Model:     model,
+		Messages:  []openai.ChatCompletionMessage{message},
+		MaxTokens: maxTokens,
+	})
+}
+
+// CreateStructuredCompletion asks for a response in JSON mode with a strict
+// schema, which the API guarantees the response matches.
+func (p *openAIProvider) CreateStructuredCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int, schema Schema) (string, openai.Usage, error) {
+	encoded, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	return p.complete(ctx, openai.ChatCompletionRequest{
+		Model:     model,
+		Messages:  []openai.ChatCompletionMessage{message},
+		MaxTokens: maxTokens,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        schema.Name,
+				Description: schema.Description,
+				Schema:      json.RawMessage(encoded),
+				Strict:      true,
+			},
+		},
+	})
+}
+
+func (p *openAIProvider) complete(ctx context.Context, request openai.ChatCompletionRequest) (string, openai.Usage, error) {
+	if p.temperature != nil {
+		request.Temperature = *p.temperature
+		if request.Temperature == 0 {
+			// A zero temperature is omitted from the request, leaving the
+			// API's default of 1.
+			request.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	resp, err := p.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return "", resp.Usage, fmt.Errorf("no response from %s API", p.name)
+	}
+	return resp.Choices[0].Message.Content, resp.Usage, nil
+}
@@ -0,0 +1,214 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Schema is the JSON schema a structured completion's response must match.
+type Schema struct {
+	// Name identifies the schema to the API, e.g. as the name of a tool.
+	Name        string
+	Description string
+	Schema      map[string]any
+}
+
+// StructuredProvider is implemented by providers whose API can constrain a
+// response to JSON matching a schema, so it can be parsed without guessing.
+type StructuredProvider interface {
+	// CreateStructuredCompletion is CreateCompletion returning the JSON
+	// text of a response matching schema.
+	CreateStructuredCompletion(ctx context.Context, model string, message openai.ChatCompletionMessage, maxTokens int, schema Schema) (string, openai.Usage, error)
+}
+
+// errStructuredUnsupported is returned by CompleteStructured when the
+// provider can't constrain its responses or rejected the schema, which
+// callers answer by asking for text instead.
+var errStructuredUnsupported = errors.New("structured responses are not supported")
+
+// statusError is an error response of an API with its HTTP status.
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// structuredRejected reports whether err is an API rejecting the request
+// for a structured response itself, such as a model that doesn't support
+// the schema or response format. Rate limits, server errors, and timeouts
+// are not, and fail the same way the next request would.
+func structuredRejected(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		status = requestErr.HTTPStatusCode
+	case errors.As(err, &statusErr):
+		status = statusErr.status
+	}
+	return status == http.StatusBadRequest || status == http.StatusUnprocessableEntity
+}
+
+// summarySchema is the response of a category summary: a paragraph and its
+// key points.
+var summarySchema = Schema{
+	Name:        "category_summary",
+	Description: "The summary of the items of a category.",
+	Schema:      summaryObject,
+}
+
+var summaryObject = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"summary":    map[string]any{"type": "string", "description": "A brief technical summary paragraph."},
+		"key_points": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Key points, if needed, without list markers."},
+	},
+	"required":             []string{"summary", "key_points"},
+	"additionalProperties": false,
+}
+
+// batchSchema is the response of a batched prompt: the summary of every
+// category of group, by category.
+func batchSchema(group []string) Schema {
+	properties := make(map[string]any, len(group))
+	for _, category := range group {
+		properties[category] = summaryObject
+	}
+	return Schema{
+		Name:        "category_summaries",
+		Description: "The summaries of the items of several categories, by category.",
+		Schema: map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             group,
+			"additionalProperties": false,
+		},
+	}
+}
+
+// CompleteStructured is Complete for a response matching schema. Callers
+// fall back to Complete when it fails with errStructuredUnsupported, which
+// it does without a request if the provider doesn't support structured
+// responses or rejected an earlier one. Other errors are those of Complete.
+func (c *Client) CompleteStructured(ctx context.Context, model string, prompt string, maxTokens int, schema Schema) (string, error) {
+	provider, ok := c.Provider.(StructuredProvider)
+	if !ok || c.structuredRefused.Load() {
+		return "", errStructuredUnsupported
+	}
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	if model == "" || model == DefaultModel {
+		model = c.Provider.DefaultModel()
+	}
+	text, usage, err := provider.CreateStructuredCompletion(ctx, model, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: prompt,
+	}, maxTokens, schema)
+	if c.OnUsage != nil {
+		c.OnUsage(model, usage)
+	}
+	if err != nil {
+		if !structuredRejected(err) {
+			return "", err
+		}
+		// Older models and OpenAI-compatible servers reject the schema;
+		// don't ask them again for every category.
+		if c.structuredRefused.CompareAndSwap(false, true) {
+			log.Printf("WARNING: The %s API refused a structured response, asking for text instead: %v", c.Provider.Name(), err)
+		}
+		return "", fmt.Errorf("%w: %v", errStructuredUnsupported, err)
+	}
+
+	if c.OnPrompt != nil {
+		c.OnPrompt(model, prompt, text)
+	}
+	return text, nil
+}
+
+// structuredSummary is the JSON of a summary matching summarySchema.
+type structuredSummary struct {
+	Summary   *string  `json:"summary"`
+	KeyPoints []string `json:"key_points"`
+}
+
+// bullets returns the summary followed by the key points, as the bullet
+// points of a category.
+func (s structuredSummary) bullets() []string {
+	var bullets []string
+	if s.Summary != nil {
+		if summary := strings.Join(strings.Fields(*s.Summary), " "); summary != "" {
+			bullets = append(bullets, summary)
+		}
+	}
+	for _, point := range s.KeyPoints {
+		if point = strings.TrimSpace(bulletPattern.ReplaceAllString(strings.TrimSpace(point), "")); point != "" {
+			bullets = append(bullets, point)
+		}
+	}
+	return bullets
+}
+
+// parseStructuredSummary reads a response matching summarySchema.
+func parseStructuredSummary(text string) ([]string, error) {
+	var summary structuredSummary
+	if err := json.Unmarshal([]byte(strings.TrimSpace(StripCodeFence(text))), &summary); err != nil {
+		return nil, fmt.Errorf("the structured response is not a JSON object: %w", err)
+	}
+	if summary.Summary == nil && summary.KeyPoints == nil {
+		return nil, fmt.Errorf("the structured response has neither a summary nor key points")
+	}
+	return summary.bullets(), nil
+}
+
+// bulletPattern matches the list marker of a line: -, *, +, or •, or a
+// number followed by a period or parenthesis.
+var bulletPattern = regexp.MustCompile(`^(?:[-*+•]|\d{1,3}[.)])\s+`)
+
+// ParseSummary reads the summary of a free-form model response: its first
+// paragraph that isn't a list or heading, followed by its list items. A
+// response of only a paragraph is a summary without key points, and one of
+// only a list starts with its first item.
+func ParseSummary(text string) []string {
+	text = StripCodeFence(text)
+	var paragraph []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || isLabel(line) || bulletPattern.MatchString(line) {
+			if len(paragraph) > 0 {
+				break
+			}
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	bullets := ExtractBulletPoints(text)
+	if len(paragraph) == 0 {
+		return bullets
+	}
+	return append([]string{strings.Join(paragraph, " ")}, bullets...)
+}
+
+// isLabel reports whether a line only introduces what follows, such as
+// "Key points:" or "**Summary**".
+func isLabel(line string) bool {
+	bold := strings.HasPrefix(line, "**") && strings.HasSuffix(line, "**")
+	line = strings.Trim(line, "*_ ")
+	return line == "" || bold || strings.HasSuffix(line, ":") && len(strings.Fields(line)) <= 4
+}
@@ -0,0 +1,364 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// Options controls how ByCategory talks to the model.
+type Options struct {
+	// Client is required for AI-assisted summaries.
+	Client     *Client
+	AIAssisted bool
+	// CategoryModels overrides the model per category.
+	CategoryModels map[string]string
+	// Model replaces the default model for categories without an override.
+	Model string
+	// Context is injected into every prompt, see WithContext.
+	Context string
+	// Prompt replaces the default summary prompt.
+	Prompt *template.Template
+	// Attribution annotates raw items with their source, see
+	// worklog.AttributedTitles.
+	Attribution string
+	// Language is the language to write in, as a code or name; empty leaves
+	// it to the model.
+	Language string
+	// RawCategories are listed as raw items even with AI assistance and
+	// never sent to the model.
+	RawCategories []string
+	// MaxTokens limits the response of each category summary; zero uses
+	// DefaultMaxTokens.
+	MaxTokens int
+	// CleanTitles lists raw items by worklog.CleanTitle, without their
+	// tags and dates, when summarizing without AI assistance.
+	CleanTitles bool
+	// Concurrency is how many categories are summarized at the same time;
+	// zero summarizes one at a time.
+	Concurrency int
+	// Batch summarizes all categories in one request per model, asking for
+	// a JSON object keyed by category, instead of one request per category.
+	// It ignores Prompt.
+	Batch bool
+	// Budget limits the length of key points and how many items each may
+	// cover.
+	Budget Budget
+	// Cache, if set, provides the summaries of categories whose items
+	// didn't change, and stores new ones.
+	Cache Cache
+}
+
+// CategoryErrors holds the error of every category whose summary failed.
+// ByCategory returns it with the summaries of the other categories.
+type CategoryErrors map[string]error
+
+func (e CategoryErrors) Error() string {
+	categories := slices.Sorted(maps.Keys(e))
+	messages := make([]string, len(categories))
+	for i, category := range categories {
+		messages[i] = e[category].Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// DefaultMaxTokens limits the response of a category summary unless
+// Options.MaxTokens is set. Busy weeks may need more.
+const DefaultMaxTokens = 500
+
+// Raw reports whether category is listed as raw items only.
+func (o Options) Raw(category string) bool {
+	return slices.ContainsFunc(o.RawCategories, func(raw string) bool {
+		return strings.EqualFold(raw, category)
+	})
+}
+
+func (o Options) maxTokens() int {
+	if o.MaxTokens > 0 {
+		return o.MaxTokens
+	}
+	return DefaultMaxTokens
+}
+
+// cleanTitles returns a copy of items with their titles cleaned.
+func cleanTitles(items []worklog.Item) []worklog.Item {
+	cleaned := slices.Clone(items)
+	for i := range cleaned {
+		cleaned[i].Title = worklog.CleanTitle(cleaned[i].Title)
+	}
+	return cleaned
+}
+
+// ModelFor returns the model used to summarize category.
+func (o Options) ModelFor(category string) string {
+	if model := o.CategoryModels[category]; model != "" {
+		return model
+	}
+	if o.Model != "" {
+		return o.Model
+	}
+	return DefaultModel
+}
+
+// ByCategory summarizes the items of every category: with AI assistance as
+// a summary followed by key points, otherwise, and for raw-only categories,
+// as the raw item titles. Up to opts.Concurrency categories are summarized at
+// the same time, or with opts.Batch, all in one request, falling back to a
+// request per category for those the batched response lacks. A category
+// whose summary fails, or strays from its items as injected instructions
+// would make it, is listed as its raw item titles instead, and the failures
+// are returned as CategoryErrors.
+func ByCategory(categories map[string][]worklog.Item, opts Options) (map[string][]string, error) {
+	result := make(map[string][]string)
+
+	if !opts.AIAssisted {
+		for category, items := range categories {
+			if len(items) == 0 {
+				continue
+			}
+
+			if opts.CleanTitles {
+				items = cleanTitles(items)
+			}
+			result[category] = worklog.AttributedTitles(items, opts.Attribution)
+		}
+		return result, nil
+	}
+
+	if opts.Client == nil {
+		return nil, fmt.Errorf("a client is required for AI-assisted summarization")
+	}
+	ctx := context.Background()
+
+	var pending []string
+	for category, items := range categories {
+		if len(items) == 0 {
+			continue
+		}
+		if opts.Raw(category) {
+			result[category] = worklog.AttributedTitles(items, opts.Attribution)
+			continue
+		}
+		if bullets, ok := cached(category, items, opts); ok {
+			result[category] = bullets
+			continue
+		}
+		pending = append(pending, category)
+	}
+	if opts.Batch {
+		pending = summarizeBatched(ctx, pending, categories, result, opts)
+	}
+
+	var mu sync.Mutex
+	failed := make(CategoryErrors)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for category := range jobs {
+				bullets, err := summarizeCategory(ctx, category, categories[category], opts)
+				mu.Lock()
+				if err != nil {
+					failed[category] = err
+					bullets = worklog.AttributedTitles(categories[category], opts.Attribution)
+				}
+				result[category] = bullets
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, category := range pending {
+		jobs <- category
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return result, failed
+	}
+	return result, nil
+}
+
+// summarizedCategories returns the categories ByCategory asks the model to
+// summarize, i.e. those with items that aren't raw-only.
+func summarizedCategories(categories map[string][]worklog.Item, opts Options) []string {
+	var summarized []string
+	for category, items := range categories {
+		if len(items) > 0 && !opts.Raw(category) {
+			summarized = append(summarized, category)
+		}
+	}
+	return summarized
+}
+
+// summarizeCategory asks the model for the summary of one category and
+// returns its bullet points.
+func summarizeCategory(ctx context.Context, category string, items []worklog.Item, opts Options) ([]string, error) {
+	prompt, err := SummaryPrompt(category, items, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bullets, err := requestSummary(ctx, category, prompt, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error calling LLM API for category '%s': %w", category, err)
+	}
+
+	bullets = enforceBudget(ctx, category, prompt, bullets, len(items), opts)
+
+	if len(bullets) == 0 {
+		log.Printf("WARNING: Empty summary received for category '%s'", category)
+	}
+	if err := checkSummary(category, bullets, items); err != nil {
+		return nil, err
+	}
+	cache(category, items, bullets, opts)
+	return bullets, nil
+}
+
+// requestSummary sends the prompt of a category and returns the bullet points
+// of the response, the summary first. Providers supporting it answer with
+// JSON matching summarySchema; the others, and those refusing the schema,
+// with text that is parsed heuristically.
+func requestSummary(ctx context.Context, category string, prompt string, opts Options) ([]string, error) {
+	model := opts.ModelFor(category)
+	responseText, err := opts.Client.CompleteStructured(ctx, model, prompt, opts.maxTokens(), summarySchema)
+	if err == nil {
+		bullets, err := parseStructuredSummary(responseText)
+		if err == nil {
+			return bullets, nil
+		}
+		log.Printf("WARNING: Reading the summary of category '%s' as text: %v", category, err)
+		return ParseSummary(responseText), nil
+	}
+	if !errors.Is(err, errStructuredUnsupported) {
+		return nil, err
+	}
+
+	responseText, err = opts.Client.Complete(ctx, model, prompt, opts.maxTokens())
+	if err != nil {
+		return nil, err
+	}
+	return ParseSummary(responseText), nil
+}
+
+// SummaryPrompt returns the prompt ByCategory sends to summarize the items of
+// category, e.g. to show it without calling the model.
+func SummaryPrompt(category string, items []worklog.Item, opts Options) (string, error) {
+	prompt, err := Prompt(opts.Prompt, category, worklog.Titles(items))
+	if err != nil {
+		return "", err
+	}
+	return WithContext(opts.Context, InLanguage(opts.Language, WithBudget(opts.Budget, prompt))), nil
+}
+
+// PlainLanguage writes a jargon-free summary of every category for readers
+// outside engineering, next to the technical summaries. Raw-only categories
+// get none, and neither do those whose summary strays from their items.
+func PlainLanguage(categories map[string][]worklog.Item, opts Options) (map[string]string, error) {
+	if opts.Client == nil {
+		return nil, fmt.Errorf("a client is required for plain-language summaries")
+	}
+	ctx := context.Background()
+
+	result := make(map[string]string)
+	for category, items := range categories {
+		if len(items) == 0 || opts.Raw(category) {
+			continue
+		}
+
+		prompt, err := Prompt(plainLanguageTemplate, category, worklog.Titles(items))
+		if err != nil {
+			return nil, err
+		}
+
+		responseText, err := opts.Client.Complete(ctx, opts.ModelFor(category), WithContext(opts.Context, InLanguage(opts.Language, prompt)), 300)
+		if err != nil {
+			return nil, fmt.Errorf("error calling LLM API for category '%s': %w", category, err)
+		}
+		summary := strings.Join(strings.Fields(responseText), " ")
+		if err := checkSummary(category, []string{summary}, items); err != nil {
+			log.Printf("WARNING: Leaving out the plain-language summary: %v", err)
+			continue
+		}
+		result[category] = summary
+	}
+	return result, nil
+}
+
+// Digest compresses a rendered worklog into the given number of sentences
+// in a final pass, for places where the full worklog doesn't fit.
+func Digest(document string, sentences int, opts Options) (string, error) {
+	if opts.Client == nil {
+		return "", fmt.Errorf("a client is required for the digest")
+	}
+
+	unit := "sentences"
+	if sentences == 1 {
+		unit = "sentence"
+	}
+	prompt := fmt.Sprintf(digestPrompt, sentences, unit, document)
+	responseText, err := opts.Client.Complete(context.Background(), opts.ModelFor(""), WithContext(opts.Context, InLanguage(opts.Language, prompt)), 60*sentences+40)
+	if err != nil {
+		return "", fmt.Errorf("error calling LLM API: %w", err)
+	}
+	return strings.Join(strings.Fields(responseText), " "), nil
+}
+
+// Rollup summarizes the weekly worklogs of a longer period, such as a month,
+// into a single Markdown note. Each worklog should start with its week.
+// repeated lists the cards reported in several of the weeks, which the
+// rollup should count once.
+func Rollup(worklogs []string, repeated []string, period string, kind string, opts Options) (string, error) {
+	if opts.Client == nil {
+		return "", fmt.Errorf("a client is required for the rollup")
+	}
+
+	prompt := fmt.Sprintf(rollupPrompt, period, kind, strings.Join(worklogs, "\n\n---\n\n"))
+	if len(repeated) > 0 {
+		prompt += fmt.Sprintf(rollupRepeatedPrompt, "- "+strings.Join(repeated, "\n- "))
+	}
+	responseText, err := opts.Client.Complete(context.Background(), opts.ModelFor(""), WithContext(opts.Context, InLanguage(opts.Language, prompt)), 2000)
+	if err != nil {
+		return "", fmt.Errorf("error calling LLM API: %w", err)
+	}
+	return strings.TrimSpace(StripCodeFence(responseText)), nil
+}
+
+// StripCodeFence removes a code fence the model wrapped its whole answer in.
+func StripCodeFence(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") {
+		return s
+	}
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) < 2 {
+		return s
+	}
+	return strings.Join(lines[1:len(lines)-1], "\n")
+}
+
+// ExtractBulletPoints returns the list items of a model response, without
+// their list markers.
+func ExtractBulletPoints(text string) []string {
+	var bullets []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if marker := bulletPattern.FindString(line); marker != "" {
+			if bullet := strings.TrimSpace(line[len(marker):]); bullet != "" {
+				bullets = append(bullets, bullet)
+			}
+		}
+	}
+	return bullets
+}
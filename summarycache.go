@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// summaryCacheFile holds the summaries of recent runs by the hash of what
+// went into them, so regenerating a week only summarizes the categories
+// whose items changed.
+const summaryCacheFile = "summaries.json"
+
+// summaryCacheTTL is how long a summary that isn't reused stays cached.
+const summaryCacheTTL = 90 * 24 * time.Hour
+
+// summaryCache implements summarize.Cache on a file in the state directory.
+type summaryCache struct {
+	path string
+	// refresh ignores the cached summaries, replacing them with new ones.
+	refresh bool
+
+	mu      sync.Mutex
+	entries map[string]summaryCacheEntry
+	hits    int
+	changed bool
+}
+
+// summaryCacheEntry is a cached summary and when it was last used.
+type summaryCacheEntry struct {
+	Bullets []string  `json:"bullets"`
+	Used    time.Time `json:"used"`
+}
+
+// loadSummaryCache reads the summary cache in stateDir. A missing or broken
+// cache is empty, since it only saves LLM calls. With refresh, every
+// category is summarized again.
+func loadSummaryCache(stateDir string, refresh bool) *summaryCache {
+	c := &summaryCache{path: filepath.Join(stateDir, summaryCacheFile), refresh: refresh, entries: make(map[string]summaryCacheEntry)}
+	if data, err := os.ReadFile(c.path); err == nil {
+		json.Unmarshal(data, &c.entries)
+	}
+	return c
+}
+
+func (c *summaryCache) Get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.refresh {
+		return nil, false
+	}
+	entry.Used = time.Now()
+	c.entries[key] = entry
+	c.hits++
+	c.changed = true
+	return entry.Bullets, true
+}
+
+func (c *summaryCache) Put(key string, bullets []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = summaryCacheEntry{Bullets: bullets, Used: time.Now()}
+	c.changed = true
+}
+
+// save writes the cache back if it changed, dropping the summaries no run
+// used within summaryCacheTTL.
+func (c *summaryCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.changed {
+		return nil
+	}
+	for key, entry := range c.entries {
+		if time.Since(entry.Used) > summaryCacheTTL {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Writing a note while a sync client (Obsidian Sync, iCloud, Syncthing) is
+// working on the same file can leave it half-written or interleaved. Files are
+// therefore written atomically, and writes wait while a sync client signals
+// that it is busy with the target.
+var (
+	syncRetries    = 5
+	syncRetryDelay = 2 * time.Second
+)
+
+// syncBusyMarkers returns the names of the files sync clients create next to
+// a file while transferring it.
+func syncBusyMarkers(name string) []string {
+	return []string{
+		"." + name + ".icloud",        // iCloud: not downloaded yet
+		".syncthing." + name + ".tmp", // Syncthing
+		"~syncthing~" + name + ".tmp", // Syncthing on Windows
+		"." + name + ".tmp",           // generic temp copy
+		"." + name + ".lock",          // generic lock file
+		name + ".lock",
+	}
+}
+
+// syncBusy reports which marker of an ongoing sync of path exists, if any.
+func syncBusy(path string) (string, bool) {
+	dir := filepath.Dir(path)
+	for _, marker := range syncBusyMarkers(filepath.Base(path)) {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return marker, true
+		}
+	}
+	return "", false
+}
+
+// conflictCopyPattern matches the copies sync clients create on conflicts:
+// "name (conflict ...).md", "name.sync-conflict-....md", "name (... conflicted
+// copy ...).md", and iCloud's "name 2.md".
+func conflictCopyPattern(path string) *regexp.Regexp {
+	extension := filepath.Ext(path)
+	stem := regexp.QuoteMeta(strings.TrimSuffix(filepath.Base(path), extension))
+	return regexp.MustCompile(`(?i)^` + stem + `(?:.*conflict.*| \d+)` + regexp.QuoteMeta(extension) + `$`)
+}
+
+// conflictCopies returns the sync conflict copies of path.
+func conflictCopies(path string) []string {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+	pattern := conflictCopyPattern(path)
+	var copies []string
+	for _, entry := range entries {
+		if pattern.MatchString(entry.Name()) {
+			copies = append(copies, entry.Name())
+		}
+	}
+	return copies
+}
+
+// writeFileSafely writes data to path atomically, waiting while a sync client
+// is busy with the file. If the sync doesn't finish in time, the data goes to
+// a conflict-suffixed file next to it instead, and that path is returned.
+// The previous content is kept in the run's backup for undo.
+func writeFileSafely(path string, data []byte) (string, error) {
+	for _, copy := range conflictCopies(path) {
+		log.Printf("WARNING: Sync conflict copy '%s' exists next to %s", copy, filepath.Base(path))
+	}
+
+	for attempt := 0; ; attempt++ {
+		marker, busy := syncBusy(path)
+		if !busy {
+			break
+		}
+		if attempt == syncRetries {
+			extension := filepath.Ext(path)
+			conflictPath := fmt.Sprintf("%s (worklog-gen conflict %s)%s",
+				strings.TrimSuffix(path, extension), time.Now().Format("2006-01-02 150405"), extension)
+			log.Printf("WARNING: %s is still being synced (%s), writing %s instead", path, marker, conflictPath)
+			path = conflictPath
+			break
+		}
+		log.Printf("INFO: %s is being synced (%s), retrying in %s", path, marker, syncRetryDelay)
+		time.Sleep(syncRetryDelay)
+	}
+
+	if err := activeBackup.save(path); err != nil {
+		return path, err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return path, err
+	}
+	return path, activeBackup.wrote(path, data)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path,
+// so readers, including sync clients, never see a partial file. A file
+// rewritten in place keeps its permissions; a new one is readable by all.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".worklog-gen-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWriteFileAtomicKeepsMode checks that notes rewritten in place keep
+// their permissions, e.g. a private note stays private.
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	for _, mode := range []os.FileMode{0600, 0664} {
+		path := filepath.Join(dir, "note.md")
+		if err := os.WriteFile(path, []byte("old"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileAtomic(path, []byte("new")); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("mode after rewriting a %v file = %v", mode, info.Mode().Perm())
+		}
+	}
+
+	path := filepath.Join(dir, "new.md")
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("mode of a new file = %v, %v; want 0644", info.Mode().Perm(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("the folder holds %d files, want no temporary files left", len(entries))
+	}
+}
+
+func TestConflictCopyPattern(t *testing.T) {
+	pattern := conflictCopyPattern("/vault/worklog-week-42-2026.md")
+	for name, want := range map[string]bool{
+		"worklog-week-42-2026 (conflict 2026-10-16).md":                true,
+		"worklog-week-42-2026.sync-conflict-20261016-101010-ABCDEF.md": true,
+		"worklog-week-42-2026 (Ben's conflicted copy 2026-10-16).md":   true,
+		"worklog-week-42-2026 2.md":                                    true,
+		"worklog-week-42-2026.md":                                      false,
+		"worklog-week-42-2026 2.rst":                                   false,
+		"worklog-week-43-2026.md":                                      false,
+	} {
+		if got := pattern.MatchString(name); got != want {
+			t.Errorf("conflict copy %q = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestWriteFileSafelyWhileSyncing checks that a note a sync client doesn't
+// finish with is left alone and the worklog written next to it.
+func TestWriteFileSafelyWhileSyncing(t *testing.T) {
+	retries := syncRetries
+	syncRetries = 0
+	defer func() { syncRetries = retries }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".note.md.icloud"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := writeFileSafely(path, []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(written), "note (worklog-gen conflict ") || filepath.Ext(written) != ".md" {
+		t.Errorf("wrote %s, want a conflict copy of note.md", written)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("note.md = %q, want it left alone", data)
+	}
+}
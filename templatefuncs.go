@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// templateFuncs are available in every template users write: output, sink,
+// and webhook payload templates and commit messages. They cover what custom
+// report layouts commonly need, so those don't require changing the
+// renderer.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+	"firstSentence": firstSentence,
+
+	// Dates, e.g. {{weekStart .Year .Week | addDays 4 | formatDate "Jan 2"}}.
+	"now":        time.Now,
+	"weekStart":  worklog.WeekStart,
+	"weekEnd":    func(year int, week int) time.Time { return worklog.WeekStart(year, week).AddDate(0, 0, 6) },
+	"addDays":    func(days int, t time.Time) time.Time { return t.AddDate(0, 0, days) },
+	"formatDate": func(layout string, t time.Time) string { return t.Format(layout) },
+	"isoWeek": func(t time.Time) int {
+		_, week := t.ISOWeek()
+		return week
+	},
+
+	// Text.
+	"pluralize":      worklog.Pluralize,
+	"truncate":       truncate,
+	"escapeMarkdown": escapeMarkdown,
+
+	// Tags.
+	"tags":      worklog.ExtractTags,
+	"stripTags": worklog.CleanTitle,
+	"hashtag":   hashtag,
+
+	// Categories.
+	"categoryStats": categoryStats,
+	"totalItems":    totalItems,
+	"orderSections": orderSections,
+}
+
+// truncate shortens text to at most length characters, ending in an
+// ellipsis when it was cut, at a word boundary if there is one.
+func truncate(length int, text string) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	if length <= 1 {
+		return string(runes[:max(length, 0)])
+	}
+	cut := string(runes[:length-1])
+	if space := strings.LastIndex(cut, " "); space > len(cut)/2 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,;:.") + "…"
+}
+
+// markdownEscaper escapes the characters markdown could read as markup.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "~", `\~`,
+)
+
+// escapeMarkdown makes text appear literally in markdown, e.g. a card title
+// with underscores or brackets.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// hashtag formats a name as an Obsidian tag, e.g. "Planning / design"
+// becomes #planning/design.
+func hashtag(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	name = strings.ReplaceAll(name, " / ", "/")
+	return "#" + strings.Join(strings.Fields(name), "-")
+}
+
+// categoryStat is the share of a category in the items of a worklog.
+type categoryStat struct {
+	Category string
+	Title    string
+	Items    int
+	// Percent is the share of the items, rounded to whole percent.
+	Percent int
+}
+
+// categoryStats returns the number of items of every section and its share
+// of all items, in the order of the sections. Sections of the same
+// category in several columns count together.
+func categoryStats(sections []webhookSection) []categoryStat {
+	total := totalItems(sections)
+	var stats []categoryStat
+	index := make(map[string]int)
+	for _, section := range sections {
+		i, ok := index[section.Category]
+		if !ok {
+			i = len(stats)
+			index[section.Category] = i
+			stats = append(stats, categoryStat{Category: section.Category, Title: section.Title})
+		}
+		stats[i].Items += section.ItemCount
+	}
+	for i := range stats {
+		if total > 0 {
+			stats[i].Percent = (stats[i].Items*100 + total/2) / total
+		}
+	}
+	return stats
+}
+
+// totalItems counts the items of all sections.
+func totalItems(sections []webhookSection) int {
+	total := 0
+	for _, section := range sections {
+		total += section.ItemCount
+	}
+	return total
+}
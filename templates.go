@@ -0,0 +1,127 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// bundledTemplates holds the defaults users customize, so a release binary
+// works on its own and the templates subcommand can write them out.
+//
+//go:embed templates
+var bundledTemplates embed.FS
+
+// bundledTemplate returns the content of a file in bundledTemplates.
+func bundledTemplate(name string) string {
+	data, err := bundledTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		panic(fmt.Sprintf("missing bundled template %s: %v", name, err))
+	}
+	return string(data)
+}
+
+// exportableTemplate is a default that can be exported and customized.
+type exportableTemplate struct {
+	name    string
+	usage   string
+	content func() string
+}
+
+// exportableTemplates are the defaults written by templates export, in the
+// order they are listed.
+var exportableTemplates = []exportableTemplate{
+	{
+		name:    "summary-prompt.tmpl",
+		usage:   "the prompt summarizing a category; pass the edited file as --prompt",
+		content: func() string { return summarize.DefaultSummaryPrompt },
+	},
+	{
+		name:    "short.tmpl",
+		usage:   "the short sink template; pass the edited file as --sink-template <sink>=short.tmpl",
+		content: func() string { return bundledTemplate("short.tmpl") },
+	},
+	{
+		name:    "worklog.tmpl",
+		usage:   "an example layout of the worklog; pass the edited file as --output-template",
+		content: func() string { return bundledTemplate("worklog.tmpl") },
+	},
+	{
+		name:    "worklog.yaml",
+		usage:   "an example config file; save it as worklog.yaml in the vault root or pass it as --config",
+		content: func() string { return bundledTemplate("worklog.yaml") },
+	},
+	{
+		name:    "config.schema.json",
+		usage:   "the JSON schema of the config file, for completion and checks in editors",
+		content: func() string { return bundledTemplate("config.schema.json") },
+	},
+}
+
+// runTemplates implements the templates subcommand, which lists the built-in
+// templates, prompts, and config files, or exports them for customization.
+func runTemplates(args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "export") {
+		return fmt.Errorf("usage: templates list | templates export [--dir folder] [--force] [name ...]")
+	}
+	if args[0] == "list" {
+		for _, tmpl := range exportableTemplates {
+			fmt.Printf("%-20s %s\n", tmpl.name, tmpl.usage)
+		}
+		return nil
+	}
+
+	fs := flag.NewFlagSet("templates export", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Folder to export the templates to")
+	force := fs.Bool("force", false, "Overwrite files that already exist")
+	fs.Parse(args[1:])
+
+	selected := exportableTemplates
+	if fs.NArg() > 0 {
+		selected = nil
+		for _, name := range fs.Args() {
+			found := false
+			for _, tmpl := range exportableTemplates {
+				if tmpl.name == name {
+					selected = append(selected, tmpl)
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown template '%s' (expected %s)", name, templateNames())
+			}
+		}
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("failed to create template folder: %w", err)
+	}
+	for _, tmpl := range selected {
+		path := filepath.Join(*dir, tmpl.name)
+		if _, err := os.Stat(path); err == nil && !*force {
+			return fmt.Errorf("%s already exists; pass --force to overwrite it", path)
+		}
+		if err := os.WriteFile(path, []byte(tmpl.content()), 0644); err != nil {
+			return fmt.Errorf("failed to export %s: %w", tmpl.name, err)
+		}
+		log.Printf("INFO: Exported %s, %s", path, tmpl.usage)
+	}
+	log.Printf("SUCCESS: Exported %d %s to %s", len(selected), worklog.Pluralize(len(selected), "template", "templates"), *dir)
+	return nil
+}
+
+// templateNames lists the names of the exportable templates.
+func templateNames() string {
+	names := make([]string, len(exportableTemplates))
+	for i, tmpl := range exportableTemplates {
+		names[i] = tmpl.name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// runTimeline implements the timeline subcommand, which collects every item
+// tagged #proj/<project> from the run history into a chronological account
+// of the project.
+func runTimeline(args []string) error {
+	fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+	project := fs.String("project", "", "Project to build the timeline for, matching #proj/<project> tags")
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory holding the run history")
+	outputPath := fs.String("output", "", "File to write the timeline to (default: stdout)")
+	apiKey := fs.String("api-key", "", "API key of the LLM provider, or a comma-separated list of keys to rotate across (can also be set via OPENAI_API_KEY, ANTHROPIC_API_KEY, or GEMINI_API_KEY env var)")
+	registerProviderFlags(fs)
+	model := fs.String("model", "", "Model used for the narrative (default "+summarize.DefaultModel+")")
+	aiAssisted := fs.Bool("ai-assisted", false, "Use AI to write the timeline as a narrative (requires an API key)")
+	contextPath := fs.String("context", "", "Path to a markdown file (team charter, project descriptions, glossary) injected into the AI prompt")
+	configPath := fs.String("config", "", "Path to a YAML config file (default: worklog.yaml in the vault root, the Obsidian plugin's settings in the vault, or ~/.config/worklog-gen/config.yaml)")
+	vault := fs.String("vault", "", "Obsidian vault whose worklog-gen plugin settings to use")
+	recordingOpts := registerRecordingFlags(fs)
+	fs.Parse(args)
+
+	if err := recordingOpts.apply(); err != nil {
+		return err
+	}
+
+	if *project == "" {
+		return fmt.Errorf("project flag is required")
+	}
+
+	cfg, err := loadConfig(resolveConfigPath(*configPath, *vault, ""))
+	if err != nil {
+		return err
+	}
+	if err := cfg.applyFlags(fs, false); err != nil {
+		return err
+	}
+	opts := summarize.Options{Model: *model, RawCategories: cfg.RawCategories}
+
+	records, err := loadHistory(*stateDir)
+	if err != nil {
+		return err
+	}
+
+	tag := "proj/" + strings.ToLower(*project)
+	var weeks []HistoryRecord
+	for _, record := range records {
+		var items []HistoryItem
+		for _, item := range record.Items {
+			if slices.Contains(worklog.ExtractTags(item.Title), tag) {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 {
+			weeks = append(weeks, HistoryRecord{Year: record.Year, Week: record.Week, Items: items})
+		}
+	}
+
+	if len(weeks) == 0 {
+		return fmt.Errorf("no items tagged #%s found in the history at %s", tag, *stateDir)
+	}
+	log.Printf("INFO: Found items tagged #%s in %d weeks", tag, len(weeks))
+
+	timeline := fmt.Sprintf("## Project timeline: %s\n\n%s", *project, timelineWeeks(weeks, nil))
+
+	if *aiAssisted {
+		key, err := resolveAPIKey(*apiKey)
+		if err != nil {
+			return err
+		}
+		background, err := loadContextFile(*contextPath)
+		if err != nil {
+			return err
+		}
+
+		// Items of raw categories are never sent to the LLM.
+		items := timelineWeeks(weeks, opts.Raw)
+		if items == "" {
+			return fmt.Errorf("every item tagged #%s is in a raw category, which is not sent to the LLM; leave out --ai-assisted", tag)
+		}
+		prompt := fmt.Sprintf(`As an expert software engineer, write a chronological narrative of the project '%s' based on the weekly work items below.
+Describe how the project evolved week by week: what was built, which problems came up, and which decisions were made. The text will be used as the background section of a design document, so write in a clear, factual tone and mention the weeks explicitly.
+
+%s`, *project, items)
+
+		narrative, err := newLLMClient(key).Complete(context.Background(), opts.ModelFor(""), summarize.WithContext(background, prompt), 1500)
+		if err != nil {
+			return fmt.Errorf("error calling LLM API: %w", err)
+		}
+		timeline = fmt.Sprintf("## Project timeline: %s\n\n%s\n", *project, strings.TrimSpace(narrative))
+	}
+
+	if *outputPath == "" {
+		fmt.Print(timeline)
+		return nil
+	}
+
+	if err := os.WriteFile(*outputPath, []byte(timeline), 0644); err != nil {
+		return fmt.Errorf("failed to write timeline: %w", err)
+	}
+	log.Printf("SUCCESS: Wrote timeline to %s", *outputPath)
+	return nil
+}
+
+// timelineWeeks lists the items of weeks by week, leaving out the items of
+// the categories skip reports, if any, and the weeks left without items.
+func timelineWeeks(weeks []HistoryRecord, skip func(category string) bool) string {
+	var sb strings.Builder
+	for _, week := range weeks {
+		var items []string
+		for _, item := range week.Items {
+			if skip == nil || !skip(item.Category) {
+				items = append(items, fmt.Sprintf("- %s (%s)\n", item.Title, item.Category))
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("### Week %d %d\n\n", week.Week, week.Year))
+		sb.WriteString(strings.Join(items, ""))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+)
+
+// TestTimelineWeeksRaw checks that the items of raw categories stay out of
+// the listing sent to the LLM, together with the weeks they leave empty.
+func TestTimelineWeeksRaw(t *testing.T) {
+	weeks := []HistoryRecord{
+		{Year: 2026, Week: 41, Items: []HistoryItem{
+			{Title: "Design the ledger #proj/payments", Category: "planning/design"},
+			{Title: "Review the ledger PR #proj/payments", Category: "reviews"},
+		}},
+		{Year: 2026, Week: 42, Items: []HistoryItem{
+			{Title: "Review the refund PR #proj/payments", Category: "reviews"},
+		}},
+	}
+
+	all := "### Week 41 2026\n\n" +
+		"- Design the ledger #proj/payments (planning/design)\n" +
+		"- Review the ledger PR #proj/payments (reviews)\n\n" +
+		"### Week 42 2026\n\n" +
+		"- Review the refund PR #proj/payments (reviews)\n\n"
+	if got := timelineWeeks(weeks, nil); got != all {
+		t.Errorf("timelineWeeks() = %q, want %q", got, all)
+	}
+
+	raw := summarize.Options{RawCategories: []string{"Reviews"}}.Raw
+	want := "### Week 41 2026\n\n- Design the ledger #proj/payments (planning/design)\n\n"
+	if got := timelineWeeks(weeks, raw); got != want {
+		t.Errorf("timelineWeeks() without raw categories = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+)
+
+const translatePrompt = `Translate the following %s document into the language with the code or name '%s'.
+Preserve the document structure exactly: keep every heading, list item, blank line, and formatting mark (such as **bold**, links, and code) in place, and leave hashtags (#tag), @mentions, dates, URLs, code, and HTML comments unchanged. Only translate the human-readable text.
+Respond with the translated document only, without any introduction or code fences.
+
+%s`
+
+// runTranslate implements the translate subcommand, which translates a
+// generated worklog while keeping its markup intact.
+func runTranslate(args []string) error {
+	fs := flag.NewFlagSet("translate", flag.ExitOnError)
+	file := fs.String("file", "", "Worklog file to translate")
+	to := fs.String("to", "", "Target language, as a code (fr, de, pt-BR) or name")
+	outputPath := fs.String("output", "", "File to write the translation to (default: the worklog's name with the language before the extension)")
+	apiKey := fs.String("api-key", "", "API key of the LLM provider, or a comma-separated list of keys to rotate across (can also be set via OPENAI_API_KEY, ANTHROPIC_API_KEY, or GEMINI_API_KEY env var)")
+	registerProviderFlags(fs)
+	model := fs.String("model", summarize.DefaultModel, "Model used for the translation")
+	contextPath := fs.String("context", "", "Path to a markdown file (e.g. a glossary) injected into the prompt")
+	recordingOpts := registerRecordingFlags(fs)
+	fs.Parse(args)
+
+	if err := recordingOpts.apply(); err != nil {
+		return err
+	}
+
+	if *file == "" || *to == "" {
+		return fmt.Errorf("file and to flags are required")
+	}
+
+	content, err := os.ReadFile(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read worklog: %w", err)
+	}
+
+	key, err := resolveAPIKey(*apiKey)
+	if err != nil {
+		return err
+	}
+	background, err := loadContextFile(*contextPath)
+	if err != nil {
+		return err
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(*file), ".")
+	if r, err := output.LookupRenderer(format); err == nil {
+		format = r.Format
+	}
+	markupName := map[string]string{"md": "Markdown", "rst": "reStructuredText", "adoc": "AsciiDoc"}[format]
+	if markupName == "" {
+		markupName = "text"
+	}
+
+	log.Printf("INFO: Translating %s to %s", *file, *to)
+	prompt := fmt.Sprintf(translatePrompt, markupName, *to, content)
+	translated, err := newLLMClient(key).Complete(context.Background(), *model, summarize.WithContext(background, prompt), 4000)
+	if err != nil {
+		return fmt.Errorf("error calling LLM API: %w", err)
+	}
+	translated = strings.TrimSpace(summarize.StripCodeFence(translated)) + "\n"
+
+	if got, want := structureLines(translated), structureLines(string(content)); got != want {
+		log.Printf("WARNING: The translation has %d headings and list items, the original %d; check its structure", got, want)
+	}
+
+	if *outputPath == "" {
+		extension := filepath.Ext(*file)
+		*outputPath = strings.TrimSuffix(*file, extension) + "." + *to + extension
+	}
+	if _, err := writeFileSafely(*outputPath, []byte(translated)); err != nil {
+		return fmt.Errorf("failed to write translation: %w", err)
+	}
+	log.Printf("SUCCESS: Wrote translation to %s", *outputPath)
+	return nil
+}
+
+// structureLines counts the lines that carry document structure, headings
+// and list items, in any of the supported markups.
+func structureLines(s string) int {
+	count := 0
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimSpace(line)
+		for _, prefix := range []string{"#", "=", "- ", "* "} {
+			if strings.HasPrefix(trimmed, prefix) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
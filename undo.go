@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/worklog"
+)
+
+// backupDir holds the pre-run copies of the files the last run replaced, in
+// the state directory, along with backupManifest describing them.
+const (
+	backupDir      = "last-run"
+	backupManifest = "manifest.json"
+)
+
+// runBackup keeps the content every file had before the run wrote it, so
+// undo can restore it. Only the last run that wrote files is kept.
+type runBackup struct {
+	mu       sync.Mutex
+	dir      string
+	started  bool
+	manifest backupRecord
+}
+
+// backupRecord is the manifest of a backup.
+type backupRecord struct {
+	Command   string       `json:"command"`
+	StartedAt time.Time    `json:"started_at"`
+	Files     []backupFile `json:"files"`
+}
+
+// backupFile is a file written by the run. Copy names its previous content
+// in the backup; files the run created have none. Written is the SHA-256 of
+// what the run wrote, to detect changes made since.
+type backupFile struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+	Copy    string `json:"copy,omitempty"`
+	Written string `json:"written,omitempty"`
+}
+
+// activeBackup is set for runs that can be undone.
+var activeBackup *runBackup
+
+// newRunBackup prepares the backup of a run in stateDir. The previous run's
+// backup is only replaced once this run writes its first file.
+func newRunBackup(stateDir string, command string) *runBackup {
+	return &runBackup{
+		dir:      filepath.Join(stateDir, backupDir),
+		manifest: backupRecord{Command: command, StartedAt: time.Now()},
+	}
+}
+
+// save copies the current content of path into the backup before the run
+// writes it. Only the first write of a file is backed up.
+func (b *runBackup) save(path string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if b.find(path) != nil {
+		return nil
+	}
+
+	if !b.started {
+		if err := os.RemoveAll(b.dir); err != nil {
+			return fmt.Errorf("failed to remove previous backup: %w", err)
+		}
+		if err := os.MkdirAll(b.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create backup folder: %w", err)
+		}
+		b.started = true
+	}
+
+	file := backupFile{Path: path}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		file.Existed = true
+		file.Copy = strconv.Itoa(len(b.manifest.Files)) + filepath.Ext(path)
+		if err := os.WriteFile(filepath.Join(b.dir, file.Copy), data, 0644); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+
+	b.manifest.Files = append(b.manifest.Files, file)
+	return b.writeManifest()
+}
+
+// wrote records what the run wrote to path, after save.
+func (b *runBackup) wrote(path string, data []byte) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	file := b.find(path)
+	if file == nil {
+		return nil
+	}
+	file.Written = contentHash(data)
+	return b.writeManifest()
+}
+
+func (b *runBackup) find(path string) *backupFile {
+	for i := range b.manifest.Files {
+		if b.manifest.Files[i].Path == path {
+			return &b.manifest.Files[i]
+		}
+	}
+	return nil
+}
+
+// writeManifest is called after every change, so the backup stays usable
+// if the run is killed halfway.
+func (b *runBackup) writeManifest() error {
+	data, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(b.dir, backupManifest), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// runUndo implements the undo subcommand, which restores the files the last
+// run wrote, such as the board and the worklog, to their content before the
+// run, and removes the files it created.
+func runUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	stateDir := fs.String("state-dir", defaultStateDir(), "Directory for the run history and other state")
+	force := fs.Bool("force", false, "Also restore files changed since the run, discarding those changes, and ignore a stale lock")
+	fs.Parse(args)
+
+	release, err := acquireLock(*stateDir, "undo", *force)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	dir := filepath.Join(*stateDir, backupDir)
+	data, err := os.ReadFile(filepath.Join(dir, backupManifest))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("nothing to undo: no backup of a previous run in %s", *stateDir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	var record backupRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+
+	log.Printf("INFO: Undoing the %s run of %s", record.Command, record.StartedAt.Format(time.RFC3339))
+
+	skipped := 0
+	for _, file := range record.Files {
+		current, err := os.ReadFile(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		if err == nil && file.Written != "" && contentHash(current) != file.Written && !*force {
+			log.Printf("WARNING: Skipping %s, which changed since the run; use --force to restore it anyway", file.Path)
+			skipped++
+			continue
+		}
+
+		if !file.Existed {
+			if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", file.Path, err)
+			}
+			fmt.Printf("Removed %s\n", file.Path)
+			continue
+		}
+		previous, err := os.ReadFile(filepath.Join(dir, file.Copy))
+		if err != nil {
+			return fmt.Errorf("failed to read backup of %s: %w", file.Path, err)
+		}
+		if err := writeFileAtomic(file.Path, previous); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+		fmt.Printf("Restored %s\n", file.Path)
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%d %s changed since the run and %s left alone", skipped, worklog.Pluralize(skipped, "file", "files"), worklog.Pluralize(skipped, "was", "were"))
+	}
+	// Undoing the same run twice could discard work done since.
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("WARNING: Failed to remove the used backup: %v", err)
+	}
+	log.Printf("SUCCESS: Undid the %s run of %s", record.Command, record.StartedAt.Format(time.RFC3339))
+	return nil
+}
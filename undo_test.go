@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// backedUpRun writes files as a run would, with a backup in stateDir: the
+// board is rewritten and the worklog created.
+func backedUpRun(t *testing.T, stateDir string, board string, worklogPath string) {
+	t.Helper()
+	activeBackup = newRunBackup(stateDir, "run")
+	defer func() { activeBackup = nil }()
+
+	for path, content := range map[string]string{board: "- [x] Ship ^abc123\n", worklogPath: "## Week 42 2026\n"} {
+		if _, err := writeFileSafely(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Writing a file again keeps the backup of its content before the run.
+	if _, err := writeFileSafely(board, []byte("- [x] Ship ^abc123 ^def456\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUndo checks that undo restores the files a run rewrote, removes those
+// it created, and can't be repeated.
+func TestUndo(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	board, worklogPath := filepath.Join(dir, "Board.md"), filepath.Join(dir, "worklog-week-42-2026.md")
+	if err := os.WriteFile(board, []byte("- [x] Ship\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backedUpRun(t, stateDir, board, worklogPath)
+
+	if err := runUndo([]string{"--state-dir", stateDir}); err != nil {
+		t.Fatalf("runUndo: %v", err)
+	}
+	if data, _ := os.ReadFile(board); string(data) != "- [x] Ship\n" {
+		t.Errorf("board = %q, want its content before the run", data)
+	}
+	if _, err := os.Stat(worklogPath); !os.IsNotExist(err) {
+		t.Errorf("the worklog created by the run still exists: %v", err)
+	}
+	if err := runUndo([]string{"--state-dir", stateDir}); err == nil {
+		t.Error("the run was undone twice")
+	}
+}
+
+// TestUndoKeepsLaterChanges checks that files edited since the run are left
+// alone unless forced.
+func TestUndoKeepsLaterChanges(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	board, worklogPath := filepath.Join(dir, "Board.md"), filepath.Join(dir, "worklog-week-42-2026.md")
+	if err := os.WriteFile(board, []byte("- [x] Ship\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backedUpRun(t, stateDir, board, worklogPath)
+	if err := os.WriteFile(worklogPath, []byte("## Week 42 2026\n\nEdited by hand.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runUndo([]string{"--state-dir", stateDir}); err == nil || err.Error() != "1 file changed since the run and was left alone" {
+		t.Errorf("runUndo() error = %v, want the edited worklog left alone", err)
+	}
+	if data, _ := os.ReadFile(worklogPath); string(data) != "## Week 42 2026\n\nEdited by hand.\n" {
+		t.Errorf("worklog = %q, want the edits kept", data)
+	}
+	if data, _ := os.ReadFile(board); string(data) != "- [x] Ship\n" {
+		t.Errorf("board = %q, want it restored", data)
+	}
+
+	if err := runUndo([]string{"--state-dir", stateDir, "--force"}); err != nil {
+		t.Fatalf("runUndo with --force: %v", err)
+	}
+	if _, err := os.Stat(worklogPath); !os.IsNotExist(err) {
+		t.Errorf("the worklog still exists after a forced undo: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// Destinations of the usage report besides the log.
+const (
+	usageInWorklog = "worklog"
+	usageInSidecar = "sidecar"
+)
+
+// usageReportFlag is where --usage-report records the usage of a run.
+type usageReportFlag struct {
+	listFlag
+}
+
+func (f *usageReportFlag) Set(value string) error {
+	for _, destination := range splitList(value) {
+		if destination != usageInWorklog && destination != usageInSidecar {
+			return fmt.Errorf("unknown destination '%s' (expected %s or %s)", destination, usageInWorklog, usageInSidecar)
+		}
+		if !slices.Contains(f.listFlag, destination) {
+			f.listFlag = append(f.listFlag, destination)
+		}
+	}
+	return nil
+}
+
+func (f *usageReportFlag) has(destination string) bool {
+	return slices.Contains(f.listFlag, destination)
+}
+
+func registerUsageReportFlag(fs *flag.FlagSet) *usageReportFlag {
+	f := &usageReportFlag{}
+	fs.Var(f, "usage-report", "Also record the token usage and estimated cost of the run: "+usageInWorklog+" appends it to the worklog, "+usageInSidecar+" writes it to worklog-week-XX-YYYY.usage.json next to the worklog (comma-separated)")
+	return f
+}
+
+// runUsage returns the tokens used so far in the run and their estimated
+// cost, per model in alphabetical order.
+func runUsage(prices map[string]modelPrice) []output.ModelUsage {
+	byModel := usageByModel()
+	models := make([]string, 0, len(byModel))
+	for model := range byModel {
+		models = append(models, model)
+	}
+	slices.Sort(models)
+
+	usage := make([]output.ModelUsage, len(models))
+	for i, model := range models {
+		tokens := byModel[model]
+		cost, priced := usageCost(model, tokens, prices)
+		usage[i] = output.ModelUsage{Model: model, PromptTokens: tokens.Prompt, CompletionTokens: tokens.Completion, CostUSD: cost, Priced: priced}
+	}
+	return usage
+}
+
+// logUsage prints the usage of the run, one line per model.
+func logUsage(usage []output.ModelUsage) {
+	if len(usage) == 0 {
+		return
+	}
+	lines := make([]string, len(usage))
+	for i, u := range usage {
+		lines[i] = fmt.Sprintf("  %s: %s", u.Model, u.Summary())
+	}
+	if len(usage) > 1 {
+		lines = append(lines, "  Total: "+output.TotalUsage(usage).Summary())
+	}
+	log.Printf("INFO: LLM usage of this run:\n%s", strings.Join(lines, "\n"))
+}
+
+// usageSidecar is the usage report written next to the worklog, for
+// expense tracking.
+type usageSidecar struct {
+	Year             int                `json:"year"`
+	Week             int                `json:"week"`
+	Provider         string             `json:"provider"`
+	Models           []usageSidecarLine `json:"models"`
+	PromptTokens     int                `json:"prompt_tokens"`
+	CompletionTokens int                `json:"completion_tokens"`
+	CostUSD          float64            `json:"cost_usd"`
+	// Unpriced lists the models whose cost is unknown and left out.
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+type usageSidecarLine struct {
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+func newUsageSidecar(year int, week int, usage []output.ModelUsage) usageSidecar {
+	total := output.TotalUsage(usage)
+	sidecar := usageSidecar{Year: year, Week: week, Provider: llmProvider, Models: []usageSidecarLine{}, PromptTokens: total.PromptTokens, CompletionTokens: total.CompletionTokens, CostUSD: total.CostUSD}
+	for _, u := range usage {
+		sidecar.Models = append(sidecar.Models, usageSidecarLine{Model: u.Model, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, CostUSD: u.CostUSD})
+		if !u.Priced {
+			sidecar.Unpriced = append(sidecar.Unpriced, u.Model)
+		}
+	}
+	return sidecar
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// cassette is a VCR-style http.RoundTripper for LLM API calls. In record mode
+// it stores every response on disk, keyed by the request; in replay mode it
+// serves those responses without touching the network, which makes complete
+// runs reproducible offline.
+type cassette struct {
+	dir    string
+	replay bool
+	next   http.RoundTripper
+}
+
+// activeCassette is set when --record or --replay is used.
+var activeCassette *cassette
+
+type recordedExchange struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	RequestBody string `json:"request_body"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+type recordingOptions struct {
+	record string
+	replay string
+}
+
+func registerRecordingFlags(fs *flag.FlagSet) *recordingOptions {
+	opts := &recordingOptions{}
+	fs.StringVar(&opts.record, "record", "", "Folder to record all LLM API responses to")
+	fs.StringVar(&opts.replay, "replay", "", "Folder of recorded LLM API responses to serve instead of calling the API")
+	return opts
+}
+
+// apply installs the cassette for all LLM clients created afterwards.
+func (o *recordingOptions) apply() error {
+	switch {
+	case o.record != "" && o.replay != "":
+		return fmt.Errorf("record and replay flags are mutually exclusive")
+	case o.record != "":
+		if err := os.MkdirAll(o.record, 0755); err != nil {
+			return fmt.Errorf("failed to create recording folder: %w", err)
+		}
+		activeCassette = &cassette{dir: o.record, next: http.DefaultTransport}
+	case o.replay != "":
+		activeCassette = &cassette{dir: o.replay, replay: true}
+	}
+	return nil
+}
+
+// exchangeKey identifies a request by its method, URL path, and body. The
+// Authorization header is deliberately ignored so recordings work with any
+// API key and never contain one.
+func exchangeKey(method string, path string, body []byte) string {
+	sum := sha256.Sum256(append([]byte(method+" "+path+"\n"), body...))
+	return fmt.Sprintf("%x", sum[:12])
+}
+
+func (c *cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// Multipart bodies, such as audio uploads, use a random boundary that
+	// must not change the key.
+	keyBody := body
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		keyBody = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("boundary"))
+	}
+	path := filepath.Join(c.dir, exchangeKey(req.Method, req.URL.Path, keyBody)+".json")
+
+	if c.replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("no recorded response for %s %s (%s): %w", req.Method, req.URL.Path, filepath.Base(path), err)
+		}
+		var exchange recordedExchange
+		if err := json.Unmarshal(data, &exchange); err != nil {
+			return nil, fmt.Errorf("failed to parse recording %s: %w", path, err)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+			StatusCode:    exchange.Status,
+			Header:        http.Header{"Content-Type": []string{exchange.ContentType}},
+			Body:          io.NopCloser(bytes.NewReader([]byte(exchange.Body))),
+			ContentLength: int64(len(exchange.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	exchange := recordedExchange{
+		Method:      req.Method,
+		URL:         req.URL.Redacted(),
+		RequestBody: string(body),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(respBody),
+	}
+	data, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recording: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write recording: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+	"github.com/sashabaranov/go-openai"
+)
+
+const sourceVoice = "voice"
+
+var audioExtensions = []string{".m4a", ".mp3", ".mp4", ".mpeg", ".mpga", ".wav", ".webm", ".ogg", ".flac"}
+
+const voiceItemsPrompt = `The following is the transcript of a voice memo in which a software engineer talks about their work day.
+List every concrete piece of work they did as a short card title, one per line, starting with "- ". Leave out plans, chit-chat, and anything not done yet.
+End each title with the one tag that fits best: #feat, #bug, #plan, #docs, #review, #meeting, or #learn. Keep names of people as @mentions.
+
+Transcript:
+%s`
+
+// voiceSource turns end-of-day voice memos into items: every memo recorded
+// during the period is transcribed with Whisper, and the work mentioned in
+// the transcript is extracted by the LLM. Transcripts are cached in the state
+// directory, so each memo is only transcribed once.
+type voiceSource struct {
+	dir      string
+	apiKey   string
+	stateDir string
+}
+
+func (s *voiceSource) Name() string {
+	return "voice memos"
+}
+
+func (s *voiceSource) Items(ctx context.Context, start time.Time, end time.Time) ([]worklog.Item, error) {
+	memos, err := filesInPeriod(s.dir, audioExtensions, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list voice memos: %w", err)
+	}
+
+	client := newLLMClient(s.apiKey)
+	var items []worklog.Item
+	for _, memo := range memos {
+		name := filepath.Base(memo.path)
+		transcript, err := memo.cachedResult(s.stateDir, "transcripts", func() (string, error) {
+			if client.Client == nil {
+				return "", fmt.Errorf("transcription uses OpenAI's Whisper, which needs --provider openai")
+			}
+			if err := client.Limiter.Wait(ctx); err != nil {
+				return "", err
+			}
+			log.Printf("INFO: Transcribing %s", name)
+			resp, err := client.CreateTranscription(ctx, openai.AudioRequest{Model: openai.Whisper1, FilePath: memo.path})
+			return resp.Text, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe %s: %w", name, err)
+		}
+		if strings.TrimSpace(transcript) == "" {
+			continue
+		}
+
+		response, err := client.Complete(ctx, summarize.DefaultModel, fmt.Sprintf(voiceItemsPrompt, transcript), 500)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract items from %s: %w", name, err)
+		}
+		for _, title := range summarize.ExtractBulletPoints(response) {
+			items = append(items, worklog.NewDatedItem(sourceVoice, title, memo.day()))
+		}
+	}
+	return items, nil
+}
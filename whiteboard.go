@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ben/obsidian-worklog-gen/board"
+	"github.com/ben/obsidian-worklog-gen/summarize"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+	"github.com/sashabaranov/go-openai"
+)
+
+const sourceWhiteboard = "whiteboard"
+
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".webp", ".gif"}
+
+const whiteboardPrompt = `This is a photo of a physical Kanban board, such as a whiteboard with sticky notes.
+Transcribe every column and the cards in it, in order from left to right and top to bottom. Write column and card titles as they are written; leave out cards you can't read.
+Respond with JSON only, shaped like {"lanes": [{"title": "Done", "items": [{"title": "Card title"}]}]}.`
+
+// whiteboardSource reads the cards of columns from photos of a physical
+// board, e.g. taken after an in-person planning session, using a
+// vision-capable model. The transcribed boards are cached in the state
+// directory, so each photo is only sent once.
+type whiteboardSource struct {
+	dir      string
+	columns  []string
+	apiKey   string
+	stateDir string
+}
+
+func (s *whiteboardSource) Name() string {
+	return "board photos"
+}
+
+func (s *whiteboardSource) Items(ctx context.Context, start time.Time, end time.Time) ([]worklog.Item, error) {
+	photos, err := filesInPeriod(s.dir, imageExtensions, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list board photos: %w", err)
+	}
+
+	client := newLLMClient(s.apiKey)
+	var items []worklog.Item
+	for _, photo := range photos {
+		name := filepath.Base(photo.path)
+		response, err := photo.cachedResult(s.stateDir, "whiteboards", func() (string, error) {
+			log.Printf("INFO: Reading board photo %s", name)
+			return s.transcribeBoard(ctx, client, photo.path)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		var lanes board.StructuredBoard
+		if err := json.Unmarshal([]byte(summarize.StripCodeFence(response)), &lanes); err != nil {
+			log.Printf("WARNING: Skipping board photo %s, the model's answer is not a board: %v", name, err)
+			continue
+		}
+		for _, column := range s.columns {
+			// Handwritten column titles rarely match the configured
+			// column's capitalization.
+			for i := range lanes.Lanes {
+				if strings.EqualFold(strings.TrimSpace(lanes.Lanes[i].Title), column) {
+					lanes.Lanes[i].Title = column
+				}
+			}
+			titles, ok := lanes.ColumnTitles(column)
+			if !ok {
+				log.Printf("WARNING: No column '%s' found on board photo %s", column, name)
+				continue
+			}
+			for _, title := range titles {
+				item := worklog.NewDatedItem(sourceWhiteboard, title, photo.day())
+				if len(s.columns) > 1 {
+					item.Column = column
+				}
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+// transcribeBoard sends the photo at path to the model and returns its
+// answer, which should be the board as JSON.
+func (s *whiteboardSource) transcribeBoard(ctx context.Context, client *summarize.Client, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mediaType == "" {
+		mediaType = "image/jpeg"
+	}
+
+	return client.CompleteMessage(ctx, summarize.DefaultModel, openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleUser,
+		MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: whiteboardPrompt},
+			{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{
+				URL:    "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data),
+				Detail: openai.ImageURLDetailHigh,
+			}},
+		},
+	}, 2000)
+}
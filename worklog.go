@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+	"github.com/ben/obsidian-worklog-gen/worklog"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+func worklogFilename(outputFolder string, year int, week int, extension string) string {
+	return filepath.Join(outputFolder, fmt.Sprintf("worklog-week-%d-%d.%s", week, year, extension))
+}
+
+// worklogFile is a generated worklog found in an output folder.
+type worklogFile struct {
+	Path      string
+	Year      int
+	Week      int
+	Extension string
+}
+
+// listWorklogs returns the worklog files in folder, newest week first.
+func listWorklogs(folder string) ([]worklogFile, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worklogs: %w", err)
+	}
+
+	var files []worklogFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		extension := strings.TrimPrefix(filepath.Ext(name), ".")
+		if r, err := output.LookupRenderer(extension); err != nil || r.Extension != extension {
+			continue
+		}
+
+		var week, year int
+		if _, err := fmt.Sscanf(name, "worklog-week-%d-%d."+extension, &week, &year); err != nil {
+			continue
+		}
+		if name != filepath.Base(worklogFilename("", year, week, extension)) {
+			continue
+		}
+		files = append(files, worklogFile{Path: filepath.Join(folder, name), Year: year, Week: week, Extension: extension})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Year != files[j].Year {
+			return files[i].Year > files[j].Year
+		}
+		if files[i].Week != files[j].Week {
+			return files[i].Week > files[j].Week
+		}
+		return files[i].Extension < files[j].Extension
+	})
+	return files, nil
+}
+
+// footnoteRefPattern matches the footnote references --citations adds after
+// bullets, which are regenerated rather than kept.
+var footnoteRefPattern = regexp.MustCompile(`\s*\[\^\d+\]`)
+
+// isFootnotes reports whether a paragraph consists of footnote definitions
+// only, such as the citations at the end of a worklog.
+func isFootnotes(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !footnoteDefPattern.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+var footnoteDefPattern = regexp.MustCompile(`^\[\^\d+\]:`)
+
+// parseWorklog reads a markdown worklog, as written by renderMarkdown and
+// possibly edited by hand afterwards, back into a Document.
+func parseWorklog(content string) (*output.Document, error) {
+	source := []byte(content)
+	doc := &output.Document{}
+	root := goldmark.DefaultParser().Parse(text.NewReader(source))
+
+	var section *output.Section
+	inKeyPoints := false
+	// plainSummaries collects the stakeholder section of a dual-audience
+	// worklog, which comes before the sections it summarizes.
+	var plainSummaries map[string]string
+	inStakeholders := false
+	inComparison := false
+	// columnTitle is the last plain ### heading, which turns out to be a
+	// column once a #### section follows it.
+	columnTitle, column := "", ""
+	// appendix is the heading of the appendix being read, if any. The
+	// markdown of free-form appendices, Notes and Self-Review, is kept as
+	// written from rawStart on.
+	appendix := ""
+	rawStart := -1
+	endRaw := func(end int) {
+		raw := strings.TrimSpace(string(source[rawStart:end]))
+		if appendix == output.NotesHeading {
+			doc.Notes = raw
+		} else {
+			doc.SelfReview = raw
+		}
+		rawStart = -1
+	}
+
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		if rawStart >= 0 {
+			heading, isHeading := n.(*ast.Heading)
+			paragraph, isParagraph := n.(*ast.Paragraph)
+			switch {
+			case isHeading && heading.Level <= 3,
+				isParagraph && isFootnotes(strings.TrimSpace(blockText(paragraph, source))):
+				endRaw(lineStart(n, source))
+			default:
+				continue
+			}
+		}
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			headingText := strings.TrimSpace(string(node.Text(source)))
+			appendix = ""
+			switch node.Level {
+			case 2:
+				var week, year int
+				if _, err := fmt.Sscanf(headingText, "Week %d %d", &week, &year); err == nil {
+					doc.Week, doc.Year = week, year
+				}
+			case 3:
+				inKeyPoints = false
+				columnTitle, column = "", ""
+				if headingText == output.StakeholderHeading {
+					doc.DualAudience = true
+					doc.AIAssisted = true
+					plainSummaries = make(map[string]string)
+					inStakeholders = true
+					section = nil
+					continue
+				}
+				inStakeholders = false
+				if headingText == output.ComparisonHeading {
+					inComparison = true
+					section = nil
+					continue
+				}
+				inComparison = false
+				switch headingText {
+				case output.CollaborationHeading, output.EstimatesHeading, output.PatternsHeading, output.UsageHeading:
+					appendix = headingText
+					section = nil
+					continue
+				case output.NotesHeading, output.SelfReviewHeading:
+					appendix = headingText
+					section = nil
+					rawStart = lineEnd(node, source)
+					continue
+				}
+				columnTitle = headingText
+				doc.Sections = append(doc.Sections, output.Section{Category: strings.ToLower(headingText)})
+				section = &doc.Sections[len(doc.Sections)-1]
+			case 4:
+				inKeyPoints, inStakeholders, inComparison = false, false, false
+				if columnTitle != "" && column == "" {
+					if section != nil && isEmptySection(*section) {
+						doc.Sections = doc.Sections[:len(doc.Sections)-1]
+					}
+					column = columnTitle
+				}
+				doc.Sections = append(doc.Sections, output.Section{Category: strings.ToLower(headingText), Column: column})
+				section = &doc.Sections[len(doc.Sections)-1]
+			}
+
+		case *ast.Paragraph:
+			if inComparison {
+				doc.Comparison = strings.TrimSpace(strings.Join([]string{doc.Comparison, blockText(node, source)}, "\n\n"))
+				continue
+			}
+			if section == nil {
+				continue
+			}
+			paragraph := strings.TrimSpace(blockText(node, source))
+			if isFootnotes(paragraph) {
+				continue
+			}
+			if paragraph == "**Key Points:**" {
+				inKeyPoints = true
+				doc.AIAssisted = true
+				continue
+			}
+			if plain, ok := strings.CutPrefix(paragraph, "**"+output.PlainLanguageLabel+"**"); ok {
+				section.PlainSummary = strings.TrimSpace(plain)
+				doc.AIAssisted = true
+				continue
+			}
+			if section.Summary != "" {
+				section.Summary += "\n\n"
+			}
+			section.Summary += paragraph
+			doc.AIAssisted = true
+
+		case *ast.List:
+			if appendix != "" {
+				parseAppendix(doc, appendix, node, source)
+				continue
+			}
+			if inStakeholders {
+				for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+					itemText := strings.TrimSpace(blockText(item, source))
+					if title, plain, ok := strings.Cut(strings.TrimPrefix(itemText, "**"), ":**"); ok {
+						plainSummaries[strings.ToLower(title)] = strings.TrimSpace(plain)
+					}
+				}
+				continue
+			}
+			if section == nil {
+				continue
+			}
+			for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+				itemText := strings.TrimSpace(footnoteRefPattern.ReplaceAllString(blockText(item, source), ""))
+				if itemText == "" {
+					continue
+				}
+				if inKeyPoints {
+					section.KeyPoints = append(section.KeyPoints, itemText)
+				} else {
+					section.Items = append(section.Items, itemText)
+				}
+			}
+		}
+	}
+
+	if rawStart >= 0 {
+		endRaw(len(source))
+	}
+	if doc.Week == 0 {
+		return nil, fmt.Errorf("no '## Week N YYYY' heading found")
+	}
+
+	for i := range doc.Sections {
+		if plain, ok := plainSummaries[doc.Sections[i].Category]; ok {
+			doc.Sections[i].PlainSummary = plain
+		}
+	}
+
+	// Sections of an AI-assisted worklog are rendered from Summary and
+	// KeyPoints, so a plain list written under one belongs to the key points.
+	// A section of nothing but a list is a raw-only category, which keeps its
+	// items.
+	if doc.AIAssisted {
+		for i := range doc.Sections {
+			if doc.Sections[i].Summary == "" && len(doc.Sections[i].KeyPoints) == 0 {
+				continue
+			}
+			doc.Sections[i].KeyPoints = append(doc.Sections[i].KeyPoints, doc.Sections[i].Items...)
+			doc.Sections[i].Items = nil
+		}
+	}
+
+	return doc, nil
+}
+
+// parseAppendix reads the list of an appendix with structured content back
+// into doc. Its items are labeled, as in "**@alice**: 2 reviews".
+func parseAppendix(doc *output.Document, appendix string, list *ast.List, source []byte) {
+	var patternLines []output.PatternLine
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		first := item.FirstChild()
+		if first == nil {
+			continue
+		}
+		// Labels, such as a model, may contain colons themselves.
+		label, value, ok := strings.Cut(strings.TrimPrefix(blockText(first, source), "**"), "**:")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch appendix {
+		case output.CollaborationHeading:
+			var items []string
+			if nested, ok := first.NextSibling().(*ast.List); ok {
+				for child := nested.FirstChild(); child != nil; child = child.NextSibling() {
+					items = append(items, blockText(child, source))
+				}
+			}
+			doc.Collaboration = append(doc.Collaboration, output.ParseCollaborator(strings.TrimPrefix(label, "@"), value, items))
+		case output.EstimatesHeading:
+			if stat, ok := output.ParseEstimateStat(label, value); ok {
+				doc.Estimates = append(doc.Estimates, stat)
+			}
+		case output.PatternsHeading:
+			patternLines = append(patternLines, output.PatternLine{Label: label, Text: value})
+		case output.UsageHeading:
+			// The total of several models is added when rendering.
+			if usage, ok := output.ParseModelUsage(label, value); ok && label != "Total" {
+				doc.Usage = append(doc.Usage, usage)
+			}
+		}
+	}
+	if appendix == output.PatternsHeading {
+		doc.Patterns = output.ParsePatterns(patternLines)
+	}
+}
+
+// lineStart returns the offset of the start of the first line of a block.
+func lineStart(n ast.Node, source []byte) int {
+	for n != nil && (n.Lines() == nil || n.Lines().Len() == 0) {
+		n = n.FirstChild()
+	}
+	if n == nil {
+		return len(source)
+	}
+	start := n.Lines().At(0).Start
+	return bytes.LastIndexByte(source[:start], '\n') + 1
+}
+
+// lineEnd returns the offset after the last line of a heading.
+func lineEnd(heading *ast.Heading, source []byte) int {
+	lines := heading.Lines()
+	if lines.Len() == 0 {
+		return len(source)
+	}
+	end := lines.At(lines.Len() - 1).Stop
+	if newline := bytes.IndexByte(source[end:], '\n'); newline >= 0 {
+		return end + newline + 1
+	}
+	return len(source)
+}
+
+// isEmptySection reports whether a parsed section has no content, as is
+// the case for the heading of a column.
+func isEmptySection(section output.Section) bool {
+	return section.Summary == "" && len(section.KeyPoints) == 0 && len(section.Items) == 0 && section.PlainSummary == ""
+}
+
+const manualMarker = "<!-- manual -->"
+
+// lockedSections returns the sections of an existing markdown worklog that
+// the user marked with a <!-- manual --> comment. Their raw markdown is kept
+// in Section.Manual so regeneration can write them back untouched.
+func lockedSections(content string) []output.Section {
+	var sections []output.Section
+	var current []string
+	inFence := false
+	// A ### heading followed by #### sections is a column.
+	lastTitle, column := "", ""
+
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		raw := strings.TrimRight(strings.Join(current, "\n"), "\n")
+		current = nil
+		if !strings.Contains(raw, manualMarker) {
+			return
+		}
+
+		title := strings.TrimSpace(strings.TrimLeft(strings.SplitN(raw, "\n", 2)[0], "#"))
+		section := output.Section{Category: strings.ToLower(title)}
+		if parsed, err := parseWorklog("## Week 1 1\n\n" + raw); err == nil && len(parsed.Sections) > 0 {
+			section = parsed.Sections[0]
+		}
+		section.Column = column
+		section.Manual = raw + "\n\n"
+		sections = append(sections, section)
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(line, "#") {
+			flush()
+			switch {
+			case strings.HasPrefix(line, "### "):
+				lastTitle, column = strings.TrimSpace(strings.TrimPrefix(line, "###")), ""
+				current = []string{line}
+			case strings.HasPrefix(line, "#### "):
+				if column == "" {
+					column = lastTitle
+				}
+				current = []string{line}
+			default:
+				lastTitle, column = "", ""
+			}
+			continue
+		}
+		if current != nil {
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	return sections
+}
+
+// applyLockedSections replaces the generated sections of doc with the locked
+// ones, adding locked sections that no longer have any generated content,
+// and orders the sections by weights.
+func applyLockedSections(doc *output.Document, locked []output.Section, weights worklog.Weights) {
+	for _, lockedSection := range locked {
+		replaced := false
+		for i := range doc.Sections {
+			if doc.Sections[i].Category == lockedSection.Category && doc.Sections[i].Column == lockedSection.Column {
+				lockedSection.ItemCount = doc.Sections[i].ItemCount
+				doc.Sections[i] = lockedSection
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			doc.Sections = append(doc.Sections, lockedSection)
+		}
+	}
+
+	// Columns keep the order in which they first appear.
+	columnRank := make(map[string]int)
+	for _, section := range doc.Sections {
+		if _, ok := columnRank[section.Column]; !ok {
+			columnRank[section.Column] = len(columnRank)
+		}
+	}
+	sort.SliceStable(doc.Sections, func(i, j int) bool {
+		if a, b := columnRank[doc.Sections[i].Column], columnRank[doc.Sections[j].Column]; a != b {
+			return a < b
+		}
+		return worklog.CompareCategories(doc.Sections[i].Category, doc.Sections[j].Category, weights) < 0
+	})
+}
+
+// blockText returns the raw markdown source of a block node, including inline
+// formatting that node.Text would strip.
+func blockText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	if lines := n.Lines(); lines != nil {
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			sb.Write(segment.Value(source))
+		}
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if child.Type() == ast.TypeBlock {
+			sb.WriteString(blockText(child, source))
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
@@ -0,0 +1,60 @@
+package worklog
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// CategoryOrder is the canonical order of the built-in categories, in which
+// the sections of a worklog appear.
+var CategoryOrder = []string{
+	"features",
+	"bugs",
+	"planning/design",
+	"documentation",
+	"reviews",
+	"meetings",
+	"learning",
+	"other",
+}
+
+// CategoryRank returns the position of category in the canonical order.
+// Unknown categories sort after all known ones.
+func CategoryRank(category string) int {
+	for i, known := range CategoryOrder {
+		if known == category {
+			return i
+		}
+	}
+	return len(CategoryOrder)
+}
+
+// Weights reorder the sections of a worklog, e.g. to put the learning
+// section last: heavier categories come first. Categories of the same
+// weight, including all without one, which weigh 0, keep the canonical
+// order, and unknown categories among them are sorted alphabetically. Nil
+// weights leave the canonical order.
+type Weights map[string]int
+
+// CompareCategories orders categories by weights and the canonical order,
+// e.g. for use with slices.SortFunc.
+func CompareCategories(a string, b string, weights Weights) int {
+	if c := cmp.Compare(weights[b], weights[a]); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(CategoryRank(a), CategoryRank(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// OrderedCategories returns the keys of summaries in the order of
+// CompareCategories: by default the canonical category order, followed by
+// any unknown categories in alphabetical order.
+func OrderedCategories[T any](summaries map[string]T, weights Weights) []string {
+	return slices.SortedFunc(maps.Keys(summaries), func(a string, b string) int {
+		return CompareCategories(a, b, weights)
+	})
+}
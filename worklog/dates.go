@@ -0,0 +1,147 @@
+// Package worklog holds the types shared by every step of generating a
+// worklog: the items of completed work, their metadata, and the categories
+// they are grouped into.
+package worklog
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DateLayout is the format of the dates on cards and in file names.
+const DateLayout = "2006-01-02"
+
+var (
+	// Completion dates as written by the Tasks plugin (✅ 2024-05-03) and
+	// the Kanban plugin (@{2024-05-03}), optionally with the time of day the
+	// Kanban plugin adds to archived cards (✅ 2024-05-03 14:30) or as a time
+	// trigger (@{2024-05-03} @@{14:30}).
+	completionDatePattern = regexp.MustCompile(`(?:✅\s*|@\{)(\d{4}-\d{2}-\d{2})(?:[ T](\d{1,2}:\d{2}(?::\d{2})?)\b|\}\s*@@\{(\d{1,2}:\d{2}(?::\d{2})?)\})?`)
+	// Start dates (🛫 2024-05-01), falling back to creation dates
+	// (➕ 2024-05-01), as written by the Tasks plugin.
+	startDatePattern   = regexp.MustCompile(`🛫\s*(\d{4}-\d{2}-\d{2})`)
+	createdDatePattern = regexp.MustCompile(`➕\s*(\d{4}-\d{2}-\d{2})`)
+)
+
+func findDate(pattern *regexp.Regexp, title string) (time.Time, bool) {
+	match := pattern.FindStringSubmatch(title)
+	if match == nil {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation(DateLayout, match[1], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// CompletionDate returns the date a card was completed, if it carries one,
+// including the time of day if the card has it.
+func CompletionDate(title string) (time.Time, bool) {
+	match := completionDatePattern.FindStringSubmatch(title)
+	if match == nil {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation(DateLayout, match[1], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	clock := match[2] + match[3]
+	if clock == "" {
+		return date, true
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), true
+		}
+	}
+	return date, true
+}
+
+// StartDate returns the date work on a card started, if it carries one.
+func StartDate(title string) (time.Time, bool) {
+	if date, ok := findDate(startDatePattern, title); ok {
+		return date, true
+	}
+	return findDate(createdDatePattern, title)
+}
+
+// WorkingDays counts the weekdays from start to end, both inclusive, so a
+// card started and finished on the same day took one day.
+func WorkingDays(start time.Time, end time.Time) int {
+	days := 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			days++
+		}
+	}
+	return days
+}
+
+// WeekStart returns midnight at the start of the Monday of an ISO week.
+func WeekStart(year int, week int) time.Time {
+	// January 4th always falls into week 1.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+	return monday.AddDate(0, 0, (week-1)*7)
+}
+
+// ValidWeek reports whether an ISO year has the given week; years have 52
+// or 53 weeks.
+func ValidWeek(year int, week int) bool {
+	if week < 1 || week > 53 {
+		return false
+	}
+	startYear, startWeek := WeekStart(year, week).ISOWeek()
+	return startYear == year && startWeek == week
+}
+
+// Period is a span of whole ISO weeks that worklogs are rolled up into, such
+// as a month or a quarter. A week belongs to the period its Thursday falls
+// in, the same rule that assigns weeks to ISO years.
+type Period struct {
+	// Kind is month or quarter.
+	Kind string
+	// Name is the human-readable name, e.g. October 2026 or Q4 2026.
+	Name string
+	// Slug names the period in file names, e.g. month-2026-10.
+	Slug string
+	// Start is the first day of the period and End the day after its last.
+	Start time.Time
+	End   time.Time
+}
+
+// PeriodOf returns the month or quarter that an ISO week belongs to.
+func PeriodOf(kind string, year int, week int) (Period, error) {
+	thursday := WeekStart(year, week).AddDate(0, 0, 3)
+	switch kind {
+	case "month":
+		start := time.Date(thursday.Year(), thursday.Month(), 1, 0, 0, 0, 0, time.Local)
+		return Period{
+			Kind:  kind,
+			Name:  start.Format("January 2006"),
+			Slug:  start.Format("month-2006-01"),
+			Start: start,
+			End:   start.AddDate(0, 1, 0),
+		}, nil
+	case "quarter":
+		quarter := (int(thursday.Month())-1)/3 + 1
+		start := time.Date(thursday.Year(), time.Month(3*quarter-2), 1, 0, 0, 0, 0, time.Local)
+		return Period{
+			Kind:  kind,
+			Name:  fmt.Sprintf("Q%d %d", quarter, start.Year()),
+			Slug:  fmt.Sprintf("quarter-%d-Q%d", start.Year(), quarter),
+			Start: start,
+			End:   start.AddDate(0, 3, 0),
+		}, nil
+	}
+	return Period{}, fmt.Errorf("unsupported period '%s' (expected week, month, or quarter)", kind)
+}
+
+// Contains reports whether an ISO week belongs to the period.
+func (p Period) Contains(year int, week int) bool {
+	thursday := WeekStart(year, week).AddDate(0, 0, 3)
+	return !thursday.Before(p.Start) && thursday.Before(p.End)
+}
@@ -0,0 +1,168 @@
+package worklog
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Item is a single piece of completed work, such as a board card. Its ID is
+// derived from the source, the title, and the completion date, so the same
+// card keeps its ID across runs and can be tracked in the run history,
+// deduplicated, and traced from generated bullets.
+type Item struct {
+	ID     string
+	Title  string
+	Source string
+	// Date is the completion date, or the zero time if the item has none.
+	Date time.Time
+	// Column is the board column the item was taken from when a worklog
+	// covers several columns, and empty otherwise.
+	Column string
+	// BlockID is the Obsidian block ID the card ends with, such as abc123
+	// for "Ship release ^abc123", which is not part of the title.
+	BlockID string
+	// Links are the references in the title, such as issue keys, pull
+	// request numbers, and URLs, used to recognize the same work in
+	// different sources.
+	Links []string
+	// Merged holds the items from other sources that describe the same work
+	// and were folded into this one when deduplicating.
+	Merged []Item
+}
+
+// Item sources.
+const (
+	SourceBoard   = "board"
+	SourceFixture = "fixture"
+)
+
+// NewItem creates an item from a title, taking the completion date and block
+// ID from the title's metadata.
+func NewItem(source string, title string) Item {
+	blockID, _ := BlockID(title)
+	title = StripBlockID(title)
+	item := Item{Title: title, Source: source, BlockID: blockID, Links: ExtractLinks(title)}
+	if date, ok := CompletionDate(title); ok {
+		item.Date = date
+	}
+	item.ID = itemID(item.Source, item.Title, item.Date)
+	return item
+}
+
+// NewDatedItem creates an item completed on date, for sources whose titles
+// don't carry a completion date.
+func NewDatedItem(source string, title string, date time.Time) Item {
+	item := NewItem(source, title)
+	if item.Date.IsZero() {
+		item.Date = date
+		item.ID = itemID(item.Source, item.Title, item.Date)
+	}
+	return item
+}
+
+// itemID hashes the identifying parts of an item. Whitespace differences in
+// the title don't change the ID.
+func itemID(source string, title string, date time.Time) string {
+	day := ""
+	if !date.IsZero() {
+		day = date.Format(DateLayout)
+	}
+	sum := sha256.Sum256([]byte(source + "\x00" + strings.Join(strings.Fields(title), " ") + "\x00" + day))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// NewItems creates an item for every title.
+func NewItems(source string, titles []string) []Item {
+	items := make([]Item, 0, len(titles))
+	for _, title := range titles {
+		items = append(items, NewItem(source, title))
+	}
+	return items
+}
+
+// Titles returns the titles of items, in order.
+func Titles(items []Item) []string {
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+// Source attribution styles for raw items, see AttributedTitles.
+const (
+	AttributionNone  = ""
+	AttributionLabel = "label"
+	AttributionBadge = "badge"
+)
+
+var sourceBadges = map[string]string{
+	"board":      "📋",
+	"git":        "🔀",
+	"github":     "🔀",
+	"calendar":   "📅",
+	"voice":      "🎙️",
+	"whiteboard": "📷",
+	"browser":    "🌐",
+}
+
+// Sources returns the source of the item followed by the sources of the items
+// merged into it, without duplicates.
+func (i Item) Sources() []string {
+	sources := []string{i.Source}
+	for _, merged := range i.Merged {
+		if !slices.Contains(sources, merged.Source) {
+			sources = append(sources, merged.Source)
+		}
+	}
+	return sources
+}
+
+// ItemSources returns the distinct sources of the items in categories.
+func ItemSources(categories map[string][]Item) map[string]bool {
+	sources := make(map[string]bool)
+	for _, items := range categories {
+		for _, item := range items {
+			for _, source := range item.Sources() {
+				sources[source] = true
+			}
+		}
+	}
+	return sources
+}
+
+// AttributedTitles returns the titles of items annotated with where they came
+// from: a "(source)" label, or a small badge in front of the title. Sources
+// without a badge fall back to the label.
+func AttributedTitles(items []Item, style string) []string {
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		if style == AttributionNone {
+			titles = append(titles, item.Title)
+			continue
+		}
+
+		var badges, labels []string
+		for _, source := range item.Sources() {
+			if badge, ok := sourceBadges[source]; ok && style == AttributionBadge {
+				if !slices.Contains(badges, badge) {
+					badges = append(badges, badge)
+				}
+			} else {
+				labels = append(labels, source)
+			}
+		}
+		title := item.Title
+		if len(badges) > 0 {
+			title = strings.Join(badges, "") + " " + title
+		}
+		if len(labels) > 0 {
+			title = fmt.Sprintf("%s (%s)", title, strings.Join(labels, ", "))
+		}
+		titles = append(titles, title)
+	}
+	return titles
+}
@@ -0,0 +1,154 @@
+package worklog
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// linkPattern matches references that identify a piece of work across
+// sources: URLs, issue keys such as ABC-123, and pull request or issue
+// numbers such as #42 or owner/repo#42.
+var linkPattern = regexp.MustCompile(`https?://[^\s)>\]]+|\b[A-Z][A-Z0-9]+-\d+\b|(?:[\w.-]+/[\w.-]+)?#\d+\b`)
+
+// ExtractLinks returns the distinct references in title.
+func ExtractLinks(title string) []string {
+	var links []string
+	for _, link := range linkPattern.FindAllString(title, -1) {
+		link = strings.TrimRight(link, ".,;:")
+		if !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// ExtractTags returns the lowercased hashtags of a card title without the
+// leading '#'.
+func ExtractTags(title string) []string {
+	var tags []string
+	for _, word := range strings.Fields(title) {
+		if strings.HasPrefix(word, "#") {
+			tags = append(tags, strings.ToLower(strings.TrimPrefix(word, "#")))
+		}
+	}
+	return tags
+}
+
+// ParseDurationHours parses estimates and time spent such as "90m", "3h",
+// "2d", or "1w" into working hours (8h days, 5d weeks).
+func ParseDurationHours(duration string) (float64, bool) {
+	if len(duration) < 2 {
+		return 0, false
+	}
+
+	value, err := strconv.ParseFloat(duration[:len(duration)-1], 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+
+	switch duration[len(duration)-1] {
+	case 'm':
+		return value / 60, true
+	case 'h':
+		return value, true
+	case 'd':
+		return value * 8, true
+	case 'w':
+		return value * 40, true
+	}
+	return 0, false
+}
+
+// SpentHours sums the #spent/ tags of a card.
+func SpentHours(title string) (float64, bool) {
+	spent, found := 0.0, false
+	for _, tag := range ExtractTags(title) {
+		if duration, ok := strings.CutPrefix(tag, "spent/"); ok {
+			if hours, ok := ParseDurationHours(duration); ok {
+				spent += hours
+				found = true
+			}
+		}
+	}
+	return spent, found
+}
+
+// MentionPattern matches @name mentions. Kanban plugin metadata such as
+// @{2024-05-03} and email addresses are not mentions.
+var MentionPattern = regexp.MustCompile(`(^|[\s(*_\[])@([\p{L}\p{N}][\p{L}\p{N}._-]*)`)
+
+// ExtractMentions returns the distinct people mentioned in title, without
+// the leading @.
+func ExtractMentions(title string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+	for _, match := range MentionPattern.FindAllStringSubmatch(title, -1) {
+		name := strings.TrimRight(match[2], ".-_")
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// blockIDPattern matches the Obsidian block ID at the end of a card title,
+// such as "Ship release ^abc123". Only the last of several counts, but all
+// are stripped.
+var blockIDPattern = regexp.MustCompile(`(?:\s\^[A-Za-z0-9-]+)*\s\^([A-Za-z0-9-]+)$`)
+
+// BlockID returns the Obsidian block ID of a card, which makes the card
+// linkable as [[Board#^id]].
+func BlockID(title string) (string, bool) {
+	// Most titles have no block ID, and the pattern is slow to fail.
+	if !strings.Contains(title, "^") {
+		return "", false
+	}
+	match := blockIDPattern.FindStringSubmatch(title)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// StripBlockID removes the block ID from a card title.
+func StripBlockID(title string) string {
+	if !strings.Contains(title, "^") {
+		return title
+	}
+	return blockIDPattern.ReplaceAllString(title, "")
+}
+
+// NewBlockID derives a short block ID for a card from its title.
+func NewBlockID(title string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(title), " ")))
+	return fmt.Sprintf("%x", sum[:3])
+}
+
+// dateMetadataPattern matches the dates plugins add to cards: completion
+// dates of the Tasks plugin (✅ 2024-05-03 14:30) and the Kanban plugin
+// (@{2024-05-03} @@{14:30}), and the Tasks plugin's start, created, due, and
+// scheduled dates.
+var dateMetadataPattern = regexp.MustCompile(`(?:✅|🛫|➕|📅|⏳)\s*\d{4}-\d{2}-\d{2}(?:[ T]\d{1,2}:\d{2}(?::\d{2})?\b)?|@\{\d{4}-\d{2}-\d{2}\}|@@\{[^}]*\}`)
+
+// CleanTitle returns a card title for readers rather than tools: without
+// hashtags, plugin dates, and block ID. References such as #42 stay, since
+// they identify the work. A title that is nothing but tags keeps them.
+func CleanTitle(title string) string {
+	words := strings.Fields(dateMetadataPattern.ReplaceAllString(StripBlockID(title), " "))
+	var kept []string
+	for _, word := range words {
+		if tag, ok := strings.CutPrefix(word, "#"); ok && strings.Trim(tag, "0123456789") != "" {
+			continue
+		}
+		kept = append(kept, word)
+	}
+	if len(kept) == 0 {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(kept, " ")
+}
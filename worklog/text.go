@@ -0,0 +1,9 @@
+package worklog
+
+// Pluralize returns singular for a count of one, plural otherwise.
+func Pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
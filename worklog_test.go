@@ -0,0 +1,157 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ben/obsidian-worklog-gen/output"
+)
+
+// TestParseWorklogRoundTrip checks that publish reads a generated worklog
+// back into the document it was rendered from, so sinks receive the same
+// worklog whether it is delivered by the run or published later.
+func TestParseWorklogRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *output.Document
+	}{
+		{
+			name: "raw",
+			doc: &output.Document{Year: 2026, Week: 42, Sections: []output.Section{
+				{Category: "features", Items: []string{"Add login #feat", "Add logout"}},
+				{Category: "bugs", Items: []string{"Fix crash in [[Parser]]"}},
+			}},
+		},
+		{
+			name: "ai-assisted",
+			doc: &output.Document{Year: 2026, Week: 42, AIAssisted: true, Sections: []output.Section{
+				{Category: "features", Summary: "Shipped the login flow.", KeyPoints: []string{"Added login", "Added logout"}, PlainSummary: "People can sign in."},
+				{Category: "other", Items: []string{"Private card"}},
+			}},
+		},
+		{
+			name: "columns",
+			doc: &output.Document{Year: 2026, Week: 1, Sections: []output.Section{
+				{Category: "features", Column: "Done", Items: []string{"Add login"}},
+				{Category: "bugs", Column: "Released", Items: []string{"Fix crash"}},
+			}},
+		},
+		{
+			name: "appendices",
+			doc: &output.Document{
+				Year: 2026, Week: 42,
+				Sections: []output.Section{{Category: "reviews", Items: []string{"Review PR 42 @bob"}}},
+				Collaboration: []output.Collaborator{
+					{Name: "@bob", Counts: map[string]int{"review": 1}, Items: []string{"Review PR 42 @bob"}},
+				},
+				Estimates: []output.EstimateStat{
+					{Category: "reviews", Cards: 1, EstimatedHours: 2, ActualHours: 3},
+					{Cards: 1, EstimatedHours: 2, ActualHours: 3},
+				},
+				SelfReview: "A calm week.",
+				Notes:      "- Ask about the offsite\n- **Bold** note",
+				Usage: []output.ModelUsage{
+					{Model: "gpt-4o-mini", PromptTokens: 1200, CompletionTokens: 300, CostUSD: 0.0004, Priced: true},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := output.RenderMarkdown(tt.doc)
+			parsed, err := parseWorklog(rendered)
+			if err != nil {
+				t.Fatalf("parseWorklog: %v", err)
+			}
+			if parsed.Year != tt.doc.Year || parsed.Week != tt.doc.Week {
+				t.Errorf("parsed week %d %d, want %d %d", parsed.Week, parsed.Year, tt.doc.Week, tt.doc.Year)
+			}
+			if again := output.RenderMarkdown(parsed); again != rendered {
+				t.Errorf("rendering the parsed worklog changed it\ngot:\n%s\nwant:\n%s", again, rendered)
+			}
+		})
+	}
+}
+
+// TestParseWorklogAppendices checks that the appendices of a worklog are
+// read back into their fields rather than as categories.
+func TestParseWorklogAppendices(t *testing.T) {
+	content := "## Week 42 2026\n\n" +
+		"### Features\n\n- Add login\n\n" +
+		"### Collaboration\n\n- **@alice**: 1 pairing session\n  - Paired with @alice\n\n" +
+		"### Notes\n\nFree text\n"
+	doc, err := parseWorklog(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var categories []string
+	for _, section := range doc.Sections {
+		categories = append(categories, section.Category)
+	}
+	if want := []string{"features"}; !reflect.DeepEqual(categories, want) {
+		t.Errorf("categories = %q, want %q", categories, want)
+	}
+	if len(doc.Collaboration) != 1 || doc.Collaboration[0].Name != "alice" {
+		t.Errorf("collaboration = %+v, want alice", doc.Collaboration)
+	}
+	if doc.Notes != "Free text" {
+		t.Errorf("notes = %q, want %q", doc.Notes, "Free text")
+	}
+}
+
+// TestLockedSections checks which sections of an existing worklog are kept
+// on regeneration: those with a <!-- manual --> marker, also below a column,
+// with their text verbatim.
+func TestLockedSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []output.Section
+	}{
+		{
+			name:    "no marker",
+			content: "## Week 42 2026\n\n### Features\n\n- Add login\n",
+		},
+		{
+			name:    "section",
+			content: "## Week 42 2026\n\n### Features\n\n- Add login\n\n### Highlights\n\n<!-- manual -->\nA great week.\n",
+			want: []output.Section{{
+				Category: "highlights",
+				Summary:  "A great week.",
+				Manual:   "### Highlights\n\n<!-- manual -->\nA great week.\n\n",
+			}},
+		},
+		{
+			name:    "section of a column",
+			content: "## Week 42 2026\n\n### Done\n\n#### Bugs\n\n<!-- manual -->\n- Fix crash\n",
+			want: []output.Section{{
+				Category: "bugs",
+				Column:   "Done",
+				Items:    []string{"Fix crash"},
+				Manual:   "#### Bugs\n\n<!-- manual -->\n- Fix crash\n\n",
+			}},
+		},
+		{
+			name:    "heading in a code block",
+			content: "## Week 42 2026\n\n### Highlights\n\n<!-- manual -->\n```\n### Bugs\n```\n",
+			want: []output.Section{{
+				Category: "highlights",
+				Manual:   "### Highlights\n\n<!-- manual -->\n```\n### Bugs\n```\n\n",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lockedSections(tt.content)
+			for i := range got {
+				got[i].ItemCount = 0
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lockedSections() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}